@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"plata_currency_quotation/internal/domain/types"
+	qm "plata_currency_quotation/internal/service/quotation-manager"
+	"time"
+)
+
+type cacheEntry struct {
+	Base      types.Currency `json:"base"`
+	Quote     types.Currency `json:"quote"`
+	Rate      types.Decimal  `json:"rate"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	Provider  string         `json:"provider"`
+}
+
+// runCache - кэш живет в памяти сервиса, поэтому dump собирает его так же, как прогрев при старте: из последних
+// завершенных запросов и истории. Курсы, которые сервис получил через event bus и еще не записал, сюда не попадут
+func runCache(args []string) int {
+	if len(args) == 0 || args[0] != "dump" {
+		return usage()
+	}
+
+	flags := flag.NewFlagSet("cache dump", flag.ContinueOnError)
+	asJson := flags.Bool("json", false, "print JSON array instead of table")
+
+	if err := flags.Parse(args[1:]); err != nil || flags.NArg() > 0 {
+		return usage()
+	}
+
+	if err := bootstrap(os.Stderr); err != nil {
+		return fail("invalid config: %s", err)
+	}
+
+	db, closeDb, err := openDb()
+
+	if err != nil {
+		return fail("failed to open database: %s", err)
+	}
+
+	defer closeDb()
+
+	manager := qm.New(0, db, nil)
+
+	if err := manager.WarmUp(); err != nil {
+		return fail("failed to load quotations: %s", err)
+	}
+
+	entries := make([]cacheEntry, 0)
+
+	for _, quotation := range manager.Snapshot() {
+		entries = append(entries, cacheEntry{
+			Base:      quotation.Base,
+			Quote:     quotation.Quote,
+			Rate:      quotation.Rate,
+			UpdatedAt: quotation.UpdatedAt,
+			Provider:  quotation.Provider,
+		})
+	}
+
+	if *asJson {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(entries); err != nil {
+			return fail("failed to print quotations: %s", err)
+		}
+
+		return 0
+	}
+
+	table := newTable()
+
+	_, _ = fmt.Fprintln(table, "PAIR\tRATE\tPROVIDER\tUPDATED AT")
+
+	for _, entry := range entries {
+		_, _ = fmt.Fprintf(table, "%s/%s\t%s\t%s\t%s\n", entry.Base, entry.Quote, entry.Rate, entry.Provider, entry.UpdatedAt.Format(time.RFC3339))
+	}
+
+	if err := table.Flush(); err != nil {
+		return fail("failed to print quotations: %s", err)
+	}
+
+	return 0
+}
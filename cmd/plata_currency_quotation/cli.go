@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"plata_currency_quotation/internal/persistence/postgres"
+	"text/tabwriter"
+)
+
+const usageText = `usage: server [command]
+
+commands:
+  serve                                   start http server and background services (default)
+  migrate status | up | down | to <n>     manage database schema
+  fetch --base USD --quote EUR[,MXN]      call rate providers once, --as-of YYYY-MM-DD for historical rate
+  requests list [--status S] [--base B] [--quote Q] [--limit N]
+  requests show <id>
+  requests retry <id>                     return request to Pending with reset attempts and ttl
+  requests expire <id> | --older-than D   mark pending requests as Expired
+  cache dump [--json]                     quotations the service warms its cache with
+  config check                            validate environment without starting
+  retention run                           purge old requests once with REQUEST_RETENTION_* settings
+
+every command reads the same environment variables as serve`
+
+// dispatch возвращает код выхода: 0 - успех, 1 - ошибка выполнения, 2 - неверные аргументы
+func dispatch(command string, args []string) int {
+	switch command {
+	case "serve":
+		return serve(args)
+	case "migrate":
+		return runMigrate(args)
+	case "fetch":
+		return runFetch(args)
+	case "requests":
+		return runRequests(args)
+	case "cache":
+		return runCache(args)
+	case "config":
+		return runConfig(args)
+	case "retention":
+		return runRetention(args)
+	case "help", "-h", "--help":
+		fmt.Println(usageText)
+
+		return 0
+	default:
+		return usage()
+	}
+}
+
+func usage() int {
+	fmt.Fprintln(os.Stderr, usageText)
+
+	return 2
+}
+
+// fail печатает ошибку подкоманды и возвращает код выхода 1
+func fail(format string, args ...any) int {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+
+	return 1
+}
+
+func newTable() *tabwriter.Writer {
+	return tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+}
+
+// openDb - подключение без миграций, подкоманды не должны менять схему
+func openDb() (*postgres.Db, func(), error) {
+	db, err := postgres.Open()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	closeDb := func() {
+		if err := db.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to close database: %s\n", err)
+		}
+	}
+
+	return db, closeDb, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"plata_currency_quotation/internal/lib/config"
+	qm "plata_currency_quotation/internal/service/quotation-manager"
+)
+
+// runConfig - config check проверяет то же, что serve при старте, но без подключения к бд и провайдерам
+func runConfig(args []string) int {
+	if len(args) != 1 || args[0] != "check" {
+		return usage()
+	}
+
+	if err := bootstrap(os.Stderr); err != nil {
+		return fail("config is invalid: %s", err)
+	}
+
+	var errs []error
+
+	if _, err := newRateProviders(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if config.Instance.SubscriptionsFile != "" {
+		if _, err := qm.LoadSubscriptions(config.Instance.SubscriptionsFile); err != nil {
+			errs = append(errs, fmt.Errorf("invalid SUBSCRIPTIONS_FILE: %w", err))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fail("config is invalid: %s", err)
+	}
+
+	fmt.Println("config is valid")
+
+	return 0
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"plata_currency_quotation/internal/domain/types"
+	cc "plata_currency_quotation/internal/service/currency-conversion"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// runFetch - один поход к провайдерам мимо бд и кэша. Курсы печатаются как их отдал провайдер, до округления
+func runFetch(args []string) int {
+	flags := flag.NewFlagSet("fetch", flag.ContinueOnError)
+	base := flags.String("base", "", "base currency")
+	quotes := flags.String("quote", "", "quote currencies, comma separated")
+	asOf := flags.String("as-of", "", "date of historical rate, YYYY-MM-DD")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 || *base == "" || *quotes == "" {
+		return usage()
+	}
+
+	if err := bootstrap(os.Stderr); err != nil {
+		return fail("invalid config: %s", err)
+	}
+
+	baseCurrency := types.Currency(strings.ToUpper(*base))
+
+	if !baseCurrency.IsValid() {
+		return fail("currency %s is not enabled", baseCurrency)
+	}
+
+	var quoteCurrencies []types.Currency
+
+	for _, quote := range strings.Split(*quotes, ",") {
+		quoteCurrency := types.Currency(strings.ToUpper(strings.TrimSpace(quote)))
+
+		if !quoteCurrency.IsValid() {
+			return fail("currency %s is not enabled", quoteCurrency)
+		}
+
+		quoteCurrencies = append(quoteCurrencies, quoteCurrency)
+	}
+
+	provider, err := newRateProviders()
+
+	if err != nil {
+		return fail("failed to setup rate providers: %s", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var rates []cc.CurrencyRate
+
+	if *asOf == "" {
+		rates, err = provider.GetLatestRates(ctx, baseCurrency, quoteCurrencies)
+	} else {
+		date, parseErr := time.Parse(time.DateOnly, *asOf)
+
+		if parseErr != nil {
+			return usage()
+		}
+
+		rates, err = provider.GetRatesOn(ctx, baseCurrency, quoteCurrencies, date)
+	}
+
+	table := newTable()
+
+	_, _ = fmt.Fprintln(table, "PAIR\tRATE\tPROVIDER\tTIME")
+
+	for _, rate := range rates {
+		_, _ = fmt.Fprintf(table, "%s/%s\t%s\t%s\t%s\n", baseCurrency, rate.Currency, rate.Rate, rate.Provider, rate.Time.Format(time.RFC3339))
+	}
+
+	if flushErr := table.Flush(); flushErr != nil {
+		return fail("failed to print rates: %s", flushErr)
+	}
+
+	// курсы, которые удалось получить, уже напечатаны
+	if err != nil {
+		return fail("provider error: %s", err)
+	}
+
+	return 0
+}
@@ -1,10 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"plata_currency_quotation/internal/api"
+	"plata_currency_quotation/internal/app"
+	"plata_currency_quotation/internal/domain/types"
 	"plata_currency_quotation/internal/lib/config"
 	"plata_currency_quotation/internal/lib/env"
 	"plata_currency_quotation/internal/lib/http-server/middleware/logger"
@@ -16,8 +25,14 @@ import (
 	"plata_currency_quotation/internal/persistence"
 	"plata_currency_quotation/internal/persistence/postgres"
 	cc "plata_currency_quotation/internal/service/currency-conversion"
+	"plata_currency_quotation/internal/service/event-bus"
+	"plata_currency_quotation/internal/service/leader"
 	qm "plata_currency_quotation/internal/service/quotation-manager"
+	"plata_currency_quotation/internal/service/retention"
+	"plata_currency_quotation/internal/service/webhook"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -25,18 +40,48 @@ import (
 )
 
 func main() {
-	config.Instance = config.FromEnv()
+	command, args := "serve", os.Args[1:]
 
-	validator.RegisterValidators()
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	os.Exit(dispatch(command, args))
+}
+
+func serve(args []string) int {
+	if len(args) > 0 {
+		return usage()
+	}
+
+	if err := bootstrap(os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot start: %s\n", err)
 
-	setupLogger()
+		return 1
+	}
 
 	sl.Log.Info("starting server", slog.String("env", string(config.Instance.Env)))
 	sl.Log.Debug("debug messages are enabled")
 
-	setupServices()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	application := app.New(config.Instance.ShutdownTimeout)
+
+	setupServices(ctx, application)
+
+	services := []metrics.SetupMetricsInterface{cc.Instance, webhook.Instance, event_bus.Instance}
+
+	if leader.Instance != nil {
+		services = append(services, leader.Instance)
+	}
+
+	if retention.Instance != nil {
+		services = append(services, retention.Instance)
+	}
 
-	metrics.Run(config.Instance.MetricsIp, config.Instance.MetricsPort, cc.Instance)
+	metricsServer := metrics.NewServer(config.Instance.MetricsIp, config.Instance.MetricsPort, services...)
+	application.AddServer("metrics", metricsServer, listen(metricsServer.Addr))
 
 	router := chi.NewRouter()
 
@@ -51,33 +96,281 @@ func main() {
 
 	address := config.Instance.ServerIp + ":" + strconv.Itoa(int(config.Instance.ServerPort))
 
-	if err := http.ListenAndServe(address, router); err != nil {
-		sl.Log.Error("failed to start server", sl.Err(err))
+	application.AddServer("http", &http.Server{Addr: address, Handler: router}, listen(address))
+
+	if err := application.Run(ctx); err != nil {
+		sl.Log.Error("server stopped with error", sl.Err(err))
+
+		return 1
+	}
+
+	sl.Log.Info("server stopped")
+
+	return 0
+}
+
+// bootstrap - общая подготовка всех подкоманд: конфиг, справочник валют, валидаторы и логгер
+func bootstrap(logOutput io.Writer) error {
+	cfg, err := config.Load()
+
+	if err != nil {
+		return err
+	}
+
+	config.Instance = cfg
+
+	if err := setupCurrencies(); err != nil {
+		return err
+	}
+
+	validator.RegisterValidators()
+
+	setupLogger(logOutput)
+
+	return nil
+}
+
+func listen(address string) net.Listener {
+	listener, err := net.Listen("tcp", address)
+
+	if err != nil {
+		sl.Log.Error("failed to start server", slog.String("address", address), sl.Err(err))
 		os.Exit(1)
 	}
+
+	return listener
 }
 
-func setupServices() {
-	cc.Instance = cc.NewFrankfurterApi(config.Instance.FrankfurterApiUrl, config.Instance.OutgoingRequestTimeout)
+// setupServices запускает фоновые сервисы. Останавливаются они в обратном порядке: сначала те, что пишут в бд,
+// потом аренда лидера, последним - пул соединений. Сигнал остановки (ctx) сразу обрывает их походы наружу и паузы
+// между повторами, чтобы Stop уложился в SHUTDOWN_TIMEOUT
+func setupServices(ctx context.Context, application *app.App) {
+	provider, err := newRateProviders()
+
+	if err != nil {
+		log.Fatalf("failed to setup rate providers: %s", err)
+	}
+
+	cc.Instance = provider
 
 	persistence.Instance = postgres.New()
 
+	application.OnStop("persistence", func(_ context.Context) error {
+		return persistence.Instance.Close()
+	})
+
+	if config.Instance.LeaderElection {
+		leader.Instance = leader.New("quotation-manager", config.Instance.LeaderLeaseTtl, persistence.Instance)
+		leader.Instance.Start(ctx)
+
+		application.OnStop("leader elector", func(_ context.Context) error {
+			return leader.Instance.Stop()
+		})
+	}
+
 	qm.Instance = qm.New(
 		time.Duration(config.Instance.QuotationUpdateIntervalMilliseconds)*time.Millisecond,
 		persistence.Instance,
 		cc.Instance,
 	)
 
-	qm.Instance.Run()
+	if leader.Instance != nil {
+		qm.Instance.SetLeader(leader.Instance)
+	}
+
+	event_bus.Instance = setupEventBus()
+	event_bus.Instance.Start(ctx)
+
+	application.OnStop("event bus", app.Blocking(event_bus.Instance.Stop))
+
+	qm.Instance.SetEventBus(event_bus.Instance)
+
+	if config.Instance.EventBus == config.PostgresBus {
+		qm.Instance.SetReconcileInterval(config.Instance.QuotationReconcileInterval)
+	}
+
+	qm.Instance.SetDebounce(config.Instance.QuotationUpdateDebounce)
+	qm.Instance.SetRequestPolicy(config.Instance.QuotationRequestMaxAttempts, config.Instance.QuotationRequestTtl)
+	qm.Instance.SetPivot(config.Instance.RatePivot, config.Instance.RatePivotMode == config.PivotAlways)
+
+	if config.Instance.SubscriptionsFile != "" {
+		subscriptions, err := qm.LoadSubscriptions(config.Instance.SubscriptionsFile)
+
+		if err != nil {
+			log.Fatalf("invalid SUBSCRIPTIONS_FILE: %s", err)
+		}
+
+		qm.Instance.Subscribe(subscriptions...)
+	}
+
+	qm.Instance.Start(ctx)
+
+	application.OnStop("quotation manager", app.Blocking(qm.Instance.Stop))
+
+	webhook.Instance = webhook.New(
+		config.Instance.WebhookDispatchInterval,
+		config.Instance.OutgoingRequestTimeout,
+		webhook.Policy{
+			MaxAttempts:    config.Instance.WebhookMaxAttempts,
+			InitialBackoff: config.Instance.WebhookInitialBackoff,
+			MaxBackoff:     config.Instance.WebhookMaxBackoff,
+		},
+		persistence.Instance,
+	)
+
+	if leader.Instance != nil {
+		webhook.Instance.SetLeader(leader.Instance)
+	}
+
+	webhook.Instance.Start(ctx)
+
+	application.OnStop("webhook dispatcher", app.Blocking(webhook.Instance.Stop))
+
+	if config.Instance.RequestRetentionDays > 0 {
+		retention.Instance = newRetentionJob(persistence.Instance)
+
+		if leader.Instance != nil {
+			retention.Instance.SetLeader(leader.Instance)
+		}
+
+		retention.Instance.Start(ctx)
+
+		application.OnStop("retention job", app.Blocking(retention.Instance.Stop))
+	}
+}
+
+func newRetentionJob(db persistence.Interface) *retention.Job {
+	policy := retention.Policy{
+		Age:       time.Duration(config.Instance.RequestRetentionDays) * 24 * time.Hour,
+		BatchSize: config.Instance.RequestRetentionBatchSize,
+	}
+
+	if config.Instance.RequestRetentionMode == config.RetentionExport {
+		policy.ExportDir = config.Instance.RequestRetentionExportDir
+	}
+
+	return retention.New(config.Instance.RequestRetentionInterval, policy, db)
+}
+
+func setupEventBus() event_bus.Interface {
+	if config.Instance.EventBus == config.PostgresBus {
+		bus, err := event_bus.NewPostgres(config.Instance.PostgresDsn())
+
+		if err != nil {
+			log.Fatalf("failed to setup event bus: %s", err)
+		}
+
+		return bus
+	}
+
+	return event_bus.NewInMemory()
+}
+
+func newRateProviders() (cc.Interface, error) {
+	providers := make([]cc.NamedProvider, 0, len(config.Instance.RateProviders))
+
+	retry := cc.RetryPolicy{
+		MaxAttempts:    config.Instance.RateProviderRetryMaxAttempts,
+		InitialBackoff: config.Instance.RateProviderRetryInitialBackoff,
+		MaxBackoff:     config.Instance.RateProviderRetryMaxBackoff,
+	}
+
+	breaker := cc.BreakerPolicy{
+		FailureThreshold: config.Instance.RateProviderBreakerFailures,
+		OpenTimeout:      config.Instance.RateProviderBreakerOpenTimeout,
+	}
+
+	for _, name := range config.Instance.RateProviders {
+		var provider cc.Interface
+
+		switch name {
+		case "frankfurter":
+			provider = cc.NewFrankfurterApi(config.Instance.FrankfurterApiUrl, config.Instance.OutgoingRequestTimeout)
+		case "ecb":
+			provider = cc.NewEcbApi(config.Instance.EcbDailyUrl, config.Instance.EcbHistoryUrl, config.Instance.OutgoingRequestTimeout)
+		case "json":
+			if config.Instance.JsonProviderLatestUrl == "" {
+				return nil, errors.New("JSON_PROVIDER_LATEST_URL must be set to use json rate provider")
+			}
+
+			provider = cc.NewJsonApi(
+				config.Instance.JsonProviderLatestUrl,
+				config.Instance.JsonProviderHistoricalUrl,
+				config.Instance.JsonProviderRatesPath,
+				config.Instance.OutgoingRequestTimeout,
+			)
+		case "file":
+			if config.Instance.RatesFile == "" {
+				return nil, errors.New("RATES_FILE must be set to use file rate provider")
+			}
+
+			provider = cc.NewFileProvider(config.Instance.RatesFile)
+		case "mock":
+			provider = cc.NewMock()
+		default:
+			return nil, fmt.Errorf("unknown rate provider: %s", name)
+		}
+
+		// повторы и автомат есть только у http провайдеров
+		if resilient, ok := provider.(interface {
+			SetPolicy(retry cc.RetryPolicy, breaker cc.BreakerPolicy)
+		}); ok {
+			resilient.SetPolicy(retry, breaker)
+		}
+
+		providers = append(providers, cc.NamedProvider{Name: name, Provider: provider})
+	}
+
+	if len(providers) == 0 {
+		return nil, errors.New("RATE_PROVIDERS must not be empty")
+	}
+
+	if config.Instance.RateAggregation == config.Consensus {
+		return cc.NewConsensus(
+			config.Instance.RateConsensusTolerance,
+			config.Instance.RateConsensusMinSources,
+			config.Instance.OutgoingRequestTimeout,
+			providers...,
+		), nil
+	}
+
+	return cc.NewFailover(config.Instance.RateProviderCooldown, config.Instance.OutgoingRequestTimeout, providers...), nil
+}
+
+func setupCurrencies() error {
+	registry, err := types.NewCurrencyRegistry(config.Instance.EnabledCurrencies...)
+
+	if err != nil {
+		return fmt.Errorf("invalid ENABLED_CURRENCIES: %w", err)
+	}
+
+	types.Registry = registry
+
+	overrides := make(map[types.Currency]int32, len(config.Instance.RateScaleOverrides))
+
+	for code, scale := range config.Instance.RateScaleOverrides {
+		overrides[types.Currency(strings.ToUpper(code))] = scale
+	}
+
+	rounding, err := types.NewRoundingPolicy(config.Instance.RateExtraScale, config.Instance.RateRoundingMode, overrides)
+
+	if err != nil {
+		return fmt.Errorf("invalid rate rounding settings: %w", err)
+	}
+
+	types.Rounding = rounding
+
+	return nil
 }
 
-func setupLogger() {
+// setupLogger - подкоманды, которые печатают результат в stdout, пишут лог в stderr
+func setupLogger(output io.Writer) {
 	switch config.Instance.Env {
 	case env.Local:
-		sl.Log = slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		sl.Log = slog.New(slog.NewTextHandler(output, &slog.HandlerOptions{Level: slog.LevelDebug}))
 	case env.Dev:
-		sl.Log = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		sl.Log = slog.New(slog.NewJSONHandler(output, &slog.HandlerOptions{Level: slog.LevelDebug}))
 	case env.Preprod, env.Prod:
-		sl.Log = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+		sl.Log = slog.New(slog.NewJSONHandler(output, &slog.HandlerOptions{Level: slog.LevelInfo}))
 	}
 }
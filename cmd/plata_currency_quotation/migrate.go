@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"plata_currency_quotation/internal/lib/config"
+	"plata_currency_quotation/internal/persistence/postgres/migration"
+	"strconv"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		return usage()
+	}
+
+	if err := bootstrap(os.Stderr); err != nil {
+		return fail("invalid config: %s", err)
+	}
+
+	db, err := sql.Open("pgx", config.Instance.PostgresDsn())
+
+	if err != nil {
+		return fail("failed to open database: %s", err)
+	}
+
+	defer func() {
+		if err := db.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to close database: %s\n", err)
+		}
+	}()
+
+	migrator, err := migration.New(db)
+
+	if err != nil {
+		return fail("failed to load migrations: %s", err)
+	}
+
+	ctx := context.Background()
+
+	switch {
+	case args[0] == "status" && len(args) == 1:
+		err = printMigrationStatus(ctx, migrator)
+	case args[0] == "up" && len(args) == 1:
+		err = migrator.Up(ctx)
+	case args[0] == "down" && len(args) == 1:
+		err = migrator.Down(ctx)
+	case args[0] == "to" && len(args) == 2:
+		version, parseErr := strconv.ParseInt(args[1], 10, 64)
+
+		if parseErr != nil {
+			return usage()
+		}
+
+		err = migrator.To(ctx, version)
+	default:
+		return usage()
+	}
+
+	if err != nil {
+		return fail("migration failed: %s", err)
+	}
+
+	return 0
+}
+
+func printMigrationStatus(ctx context.Context, migrator *migration.Migrator) error {
+	states, err := migrator.Status(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	table := newTable()
+
+	_, _ = fmt.Fprintln(table, "VERSION\tNAME\tAPPLIED AT")
+
+	for _, state := range states {
+		appliedAt := "pending"
+
+		if state.AppliedAt != nil {
+			appliedAt = state.AppliedAt.Format(time.RFC3339)
+		}
+
+		_, _ = fmt.Fprintf(table, "%d\t%s\t%s\n", state.Version, state.Name, appliedAt)
+	}
+
+	return table.Flush()
+}
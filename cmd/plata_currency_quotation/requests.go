@@ -0,0 +1,245 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	qr "plata_currency_quotation/internal/domain/enity/quotation-request"
+	"plata_currency_quotation/internal/domain/types"
+	"plata_currency_quotation/internal/persistence"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// runRequests - подкоманды для разбора зависших запросов. Менеджер котировок подхватывает возвращенные в Pending
+// запросы при следующем проходе: лидер опрашивает бд раз в QUOTATION_UPDATE_INTERVAL_MILLISECONDS, одиночный
+// экземпляр без LEADER_ELECTION - при следующем запросе клиента или перезапуске
+func runRequests(args []string) int {
+	if len(args) == 0 {
+		return usage()
+	}
+
+	if err := bootstrap(os.Stderr); err != nil {
+		return fail("invalid config: %s", err)
+	}
+
+	db, closeDb, err := openDb()
+
+	if err != nil {
+		return fail("failed to open database: %s", err)
+	}
+
+	defer closeDb()
+
+	switch args[0] {
+	case "list":
+		return listRequests(db, args[1:])
+	case "show":
+		return withRequestId(args[1:], func(id uuid.UUID) int { return showRequest(db, id) })
+	case "retry":
+		return withRequestId(args[1:], func(id uuid.UUID) int { return retryRequest(db, id) })
+	case "expire":
+		return expireRequests(db, args[1:])
+	default:
+		return usage()
+	}
+}
+
+func withRequestId(args []string, run func(id uuid.UUID) int) int {
+	if len(args) != 1 {
+		return usage()
+	}
+
+	id, err := uuid.Parse(args[0])
+
+	if err != nil {
+		return fail("invalid request id: %s", err)
+	}
+
+	return run(id)
+}
+
+func listRequests(db persistence.Interface, args []string) int {
+	flags := flag.NewFlagSet("requests list", flag.ContinueOnError)
+	status := flags.String("status", "", "Pending, InProgress, Ready, Failed or Expired")
+	base := flags.String("base", "", "base currency")
+	quote := flags.String("quote", "", "quote currency")
+	limit := flags.Int("limit", 50, "max requests, newest first")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 {
+		return usage()
+	}
+
+	requests, err := db.QuotationRequestList(persistence.QuotationRequestFilter{
+		Status:        qr.Status(*status),
+		BaseCurrency:  types.Currency(strings.ToUpper(*base)),
+		QuoteCurrency: types.Currency(strings.ToUpper(*quote)),
+		Limit:         *limit,
+	})
+
+	if err != nil {
+		return fail("failed to list requests: %s", err)
+	}
+
+	table := newTable()
+
+	_, _ = fmt.Fprintln(table, "ID\tPAIR\tAS OF\tSTATUS\tATTEMPTS\tCREATED AT\tERROR")
+
+	for _, request := range requests {
+		asOf, lastError := "-", "-"
+
+		if request.AsOf != nil {
+			asOf = request.AsOf.Format(time.DateOnly)
+		}
+
+		if failure := request.LastError(); failure != nil {
+			lastError = failure.Code
+		}
+
+		_, _ = fmt.Fprintf(
+			table, "%s\t%s/%s\t%s\t%s\t%d\t%s\t%s\n",
+			request.Id, request.BaseCurrency, request.QuoteCurrency, asOf, request.Status, request.Attempts,
+			request.CreatedAt.Format(time.RFC3339), lastError,
+		)
+	}
+
+	if err := table.Flush(); err != nil {
+		return fail("failed to print requests: %s", err)
+	}
+
+	return 0
+}
+
+// showRequest - секрет вебхука не печатается
+func showRequest(db persistence.Interface, id uuid.UUID) int {
+	request, err := db.QuotationRequestGetById(id)
+
+	if err != nil {
+		return fail("failed to get request: %s", err)
+	}
+
+	if request == nil {
+		return fail("no request with id %s", id)
+	}
+
+	optional := func(value *string) string {
+		if value == nil {
+			return "-"
+		}
+
+		return *value
+	}
+
+	optionalTime := func(value *time.Time, layout string) string {
+		if value == nil {
+			return "-"
+		}
+
+		return value.Format(layout)
+	}
+
+	rate := "-"
+
+	if request.Rate != nil {
+		rate = request.Rate.String()
+	}
+
+	batchId := "-"
+
+	if request.BatchId != nil {
+		batchId = request.BatchId.String()
+	}
+
+	callbackSecret := "-"
+
+	if request.CallbackSecret != nil {
+		callbackSecret = "set"
+	}
+
+	table := newTable()
+
+	for _, row := range [][2]string{
+		{"id", request.Id.String()},
+		{"idempotency key", request.IdempotencyKey.String()},
+		{"pair", string(request.BaseCurrency + "/" + request.QuoteCurrency)},
+		{"as of", optionalTime(request.AsOf, time.DateOnly)},
+		{"status", string(request.Status)},
+		{"attempts", fmt.Sprint(request.Attempts)},
+		{"error code", optional(request.ErrorCode)},
+		{"error message", optional(request.ErrorMessage)},
+		{"created at", request.CreatedAt.Format(time.RFC3339)},
+		{"completed at", optionalTime(request.CompletedAt, time.RFC3339)},
+		{"rate", rate},
+		{"provider", optional(request.Provider)},
+		{"batch id", batchId},
+		{"callback url", optional(request.CallbackUrl)},
+		{"callback secret", callbackSecret},
+	} {
+		_, _ = fmt.Fprintf(table, "%s\t%s\n", row[0], row[1])
+	}
+
+	if err := table.Flush(); err != nil {
+		return fail("failed to print request: %s", err)
+	}
+
+	return 0
+}
+
+func retryRequest(db persistence.Interface, id uuid.UUID) int {
+	retried, err := db.QuotationRequestRetry(id)
+
+	if err != nil {
+		return fail("failed to retry request: %s", err)
+	}
+
+	if !retried {
+		return fail("request %s does not exist or is already Ready", id)
+	}
+
+	fmt.Printf("request %s is Pending again\n", id)
+
+	return 0
+}
+
+func expireRequests(db persistence.Interface, args []string) int {
+	flags := flag.NewFlagSet("requests expire", flag.ContinueOnError)
+	olderThan := flags.Duration("older-than", 0, "expire every pending request older than this")
+
+	if err := flags.Parse(args); err != nil {
+		return usage()
+	}
+
+	if *olderThan > 0 {
+		if flags.NArg() > 0 {
+			return usage()
+		}
+
+		expired, err := db.QuotationRequestExpire(time.Now().Add(-*olderThan))
+
+		if err != nil {
+			return fail("failed to expire requests: %s", err)
+		}
+
+		fmt.Printf("%d requests expired\n", expired)
+
+		return 0
+	}
+
+	return withRequestId(flags.Args(), func(id uuid.UUID) int {
+		expired, err := db.QuotationRequestExpireById(id, "expired manually")
+
+		if err != nil {
+			return fail("failed to expire request: %s", err)
+		}
+
+		if !expired {
+			return fail("request %s does not exist or is not pending", id)
+		}
+
+		fmt.Printf("request %s expired\n", id)
+
+		return 0
+	})
+}
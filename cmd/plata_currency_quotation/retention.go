@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"plata_currency_quotation/internal/lib/config"
+	"syscall"
+)
+
+// runRetention - тот же проход, что делает сервис раз в REQUEST_RETENTION_INTERVAL. Можно запускать рядом с
+// работающим сервисом: занятые строки пропускаются. Прерванный проход оставляет удаленными уже закоммиченные пачки
+func runRetention(args []string) int {
+	if len(args) != 1 || args[0] != "run" {
+		return usage()
+	}
+
+	if err := bootstrap(os.Stderr); err != nil {
+		return fail("invalid config: %s", err)
+	}
+
+	if config.Instance.RequestRetentionDays == 0 {
+		return fail("REQUEST_RETENTION_DAYS is not set, nothing to purge")
+	}
+
+	db, closeDb, err := openDb()
+
+	if err != nil {
+		return fail("failed to open database: %s", err)
+	}
+
+	defer closeDb()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	purged, err := newRetentionJob(db).Run(ctx)
+
+	if err != nil {
+		return fail("retention stopped after %d requests: %s", purged, err)
+	}
+
+	fmt.Printf("%d requests purged\n", purged)
+
+	return 0
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// validEnv - минимальное окружение, с которым проходит config.Load. До бд и провайдеров тесты не доходят
+func validEnv(t *testing.T) {
+	for key, value := range map[string]string{
+		"ENV":                                    "local",
+		"QUOTATION_UPDATE_INTERVAL_MILLISECONDS": "1000",
+		"DB_HOST":                                "localhost",
+		"DB_USER":                                "test",
+		"DB_PASSWORD":                            "test",
+		"DB_NAME":                                "test",
+		"DB_PORT":                                "5432",
+		"DB_SSL_MODE":                            "disable",
+		"SERVER_IP":                              "127.0.0.1",
+		"SERVER_PORT":                            "8080",
+		"METRICS_IP":                             "127.0.0.1",
+		"METRICS_PORT":                           "9090",
+		"OUTGOING_REQUEST_TIMEOUT":               "1s",
+		"INCOMING_REQUEST_TIMEOUT":               "1s",
+		"FRANKFURTER_API_URL":                    "http://localhost",
+		"REQUEST_RETENTION_DAYS":                 "0",
+	} {
+		t.Setenv(key, value)
+	}
+}
+
+func Test_DispatchArguments(t *testing.T) {
+	validEnv(t)
+
+	tests := []struct {
+		name    string
+		command string
+		args    []string
+		code    int
+	}{
+		{"help", "help", nil, 0},
+		{"unknown command", "unknown", nil, 2},
+		{"serve with args", "serve", []string{"extra"}, 2},
+		{"migrate without subcommand", "migrate", nil, 2},
+		{"fetch without quote", "fetch", []string{"--base", "USD"}, 2},
+		{"fetch without base", "fetch", []string{"--quote", "EUR"}, 2},
+		{"fetch unknown flag", "fetch", []string{"--base", "USD", "--quote", "EUR", "--foo"}, 2},
+		{"fetch positional arg", "fetch", []string{"--base", "USD", "--quote", "EUR", "extra"}, 2},
+		{"fetch disabled base", "fetch", []string{"--base", "XXX", "--quote", "EUR"}, 1},
+		{"fetch disabled quote", "fetch", []string{"--base", "USD", "--quote", "EUR,XXX"}, 1},
+		{"requests without subcommand", "requests", nil, 2},
+		{"cache without subcommand", "cache", nil, 2},
+		{"cache unknown subcommand", "cache", []string{"load"}, 2},
+		{"cache dump positional arg", "cache", []string{"dump", "extra"}, 2},
+		{"config without subcommand", "config", nil, 2},
+		{"config check with args", "config", []string{"check", "extra"}, 2},
+		{"config check", "config", []string{"check"}, 0},
+		{"retention without subcommand", "retention", nil, 2},
+		{"retention run without days", "retention", []string{"run"}, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.code, dispatch(test.command, test.args))
+		})
+	}
+}
+
+func Test_DispatchInvalidConfig(t *testing.T) {
+	validEnv(t)
+	t.Setenv("ENV", "unknown")
+
+	assert.Equal(t, 1, dispatch("config", []string{"check"}))
+	assert.Equal(t, 1, dispatch("migrate", []string{"up"}))
+	assert.Equal(t, 1, dispatch("requests", []string{"list"}))
+}
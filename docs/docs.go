@@ -15,9 +15,120 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/api/v1/convert": {
+            "get": {
+                "description": "Converts amount using the last cached quotation, see ` + "`" + `GET /api/v1/quotation/last-requested` + "`" + `. Result is rounded to minor units of ` + "`" + `to` + "`" + `. Returns ` + "`" + `409` + "`" + ` if the cached rate is older than server setting ` + "`" + `QUOTATION_MAX_AGE` + "`" + `, request an update with ` + "`" + `POST /api/v1/quotation/update-request` + "`" + ` in this case",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Convert"
+                ],
+                "summary": "Convert amount",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Source Currency",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Target Currency",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Non-negative decimal amount, e.g. ` + "`" + `1234.56` + "`" + `",
+                        "name": "amount",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/convert.ConvertResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Quotation not found",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Quotation is too old",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Converts up to 100 amounts like ` + "`" + `GET /api/v1/convert` + "`" + `. Items are processed independently, failed ones contain ` + "`" + `error` + "`" + ` instead of ` + "`" + `result` + "`" + `",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Convert"
+                ],
+                "summary": "Convert amounts in batch",
+                "parameters": [
+                    {
+                        "description": "Amounts to convert",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/convert.ConvertBatchBody"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/convert.ConvertBatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/api/v1/currency/list": {
             "get": {
-                "description": "Returns list of supported currency codes in [ISO 4217](https://en.wikipedia.org/wiki/ISO_4217) format",
+                "description": "Returns list of enabled currencies with [ISO 4217](https://en.wikipedia.org/wiki/ISO_4217) metadata: alpha code, numeric code, minor units, name and active flag",
                 "produces": [
                     "application/json"
                 ],
@@ -41,6 +152,130 @@ const docTemplate = `{
                 }
             }
         },
+        "/api/v1/quotation": {
+            "post": {
+                "description": "Creates a quotation update request like ` + "`" + `POST /api/v1/quotation/update-request` + "`" + ` and blocks until it is completed or ` + "`" + `wait` + "`" + ` runs out. On timeout returns status ` + "`" + `NotReady` + "`" + ` and request Id, poll ` + "`" + `GET /api/v1/quotation/update-request/{id}` + "`" + ` in this case",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Quotation"
+                ],
+                "summary": "Request quotation update and wait for the result",
+                "parameters": [
+                    {
+                        "description": "Quotation request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/quotation.RequestQuotationUpdateBody"
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Max wait in milliseconds. Defaults to and is capped by server setting ` + "`" + `MAX_QUOTATION_WAIT` + "`" + `",
+                        "name": "wait",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include per-provider breakdown of aggregated rate",
+                        "name": "detail",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/quotation.RequestQuotationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/quotation/history": {
+            "get": {
+                "description": "Returns every rate fetched for base and quote currencies in ` + "`" + `[from, to)` + "`" + `. Without ` + "`" + `interval` + "`" + ` returns raw points, with ` + "`" + `interval` + "`" + ` returns OHLC buckets aligned to UTC. Empty buckets are skipped",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Quotation"
+                ],
+                "summary": "Get quotation history",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Base Currency",
+                        "name": "base",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Quote Currency",
+                        "name": "quote",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC 3339 timestamp, defaults to ` + "`" + `to` + "`" + ` minus 24 hours",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC 3339 timestamp, defaults to now",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Bucket size as Go duration, e.g. ` + "`" + `15m` + "`" + `, ` + "`" + `1h` + "`" + `, ` + "`" + `24h` + "`" + `",
+                        "name": "interval",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/quotation.GetQuotationHistoryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/api/v1/quotation/last-requested": {
             "get": {
                 "description": "Retrieves last requested quotation by base and quote currencies. Use [ISO 4217](https://en.wikipedia.org/wiki/ISO_4217) currency code. List of supported currencies - ` + "`" + `GET /api/v1/currency/list` + "`" + `. Returns ` + "`" + `404 Quotation not found` + "`" + ` if quotation wasn't requested at least once, use ` + "`" + `POST /api/v1/update-request` + "`" + ` in this case",
@@ -65,6 +300,12 @@ const docTemplate = `{
                         "name": "quote",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include per-provider breakdown of aggregated rate",
+                        "name": "detail",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -95,9 +336,53 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/quotation/update-request": {
+        "/api/v1/quotation/snapshot": {
+            "get": {
+                "description": "Returns every quotation that was requested at least once, like ` + "`" + `GET /api/v1/quotation/last-requested` + "`" + ` for each pair. Ordered by base and quote currency. ` + "`" + `stale` + "`" + ` marks rates older than server setting ` + "`" + `QUOTATION_MAX_AGE` + "`" + `",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Quotation"
+                ],
+                "summary": "Get all cached quotations",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only pairs with this base currency",
+                        "name": "base",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only pairs with this quote currency",
+                        "name": "quote",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/quotation.GetSnapshotResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            },
             "post": {
-                "description": "Creates a quotation update request. Use [ISO 4217](https://en.wikipedia.org/wiki/ISO_4217) currency code. List of supported currencies - ` + "`" + `GET /api/v1/currency/list` + "`" + `. Returns request Id.",
+                "description": "Returns cached quotations for up to 500 pairs. Pairs that were never requested are returned in ` + "`" + `missing` + "`" + `",
                 "consumes": [
                     "application/json"
                 ],
@@ -107,15 +392,15 @@ const docTemplate = `{
                 "tags": [
                     "Quotation"
                 ],
-                "summary": "Request quotation update",
+                "summary": "Get cached quotations by list of pairs",
                 "parameters": [
                     {
-                        "description": "Quotation request",
+                        "description": "Pairs",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/quotation.RequestQuotationUpdateBody"
+                            "$ref": "#/definitions/quotation.GetSnapshotBody"
                         }
                     }
                 ],
@@ -123,7 +408,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/quotation.RequestQuotationUpdateResponse"
+                            "$ref": "#/definitions/quotation.GetSnapshotByPairsResponse"
                         }
                     },
                     "400": {
@@ -141,30 +426,175 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/quotation/update-request/{id}": {
-            "get": {
-                "description": "Retrieves a quotation by request Id. If request is not proceeded yet, returns status ` + "`" + `NotReady` + "`" + `. If request is completed, returns status ` + "`" + `Ready` + "`" + ` and fields ` + "`" + `rate` + "`" + ` and ` + "`" + `updatedAt` + "`" + `.",
+        "/api/v1/quotation/update-request": {
+            "post": {
+                "description": "Creates a quotation update request. Use [ISO 4217](https://en.wikipedia.org/wiki/ISO_4217) currency code. List of supported currencies - ` + "`" + `GET /api/v1/currency/list` + "`" + `. Returns request Id.\nIf ` + "`" + `callbackUrl` + "`" + ` is set, the result is POSTed there once the request is ` + "`" + `Ready` + "`" + `, ` + "`" + `Failed` + "`" + ` or ` + "`" + `Expired` + "`" + `. Body: ` + "`" + `requestId` + "`" + `, ` + "`" + `baseCurrency` + "`" + `, ` + "`" + `quoteCurrency` + "`" + `, ` + "`" + `status` + "`" + `, ` + "`" + `rate` + "`" + ` and ` + "`" + `updatedAt` + "`" + ` for ` + "`" + `Ready` + "`" + `, ` + "`" + `error` + "`" + ` with ` + "`" + `code` + "`" + ` and ` + "`" + `message` + "`" + ` for ` + "`" + `Failed` + "`" + ` and ` + "`" + `Expired` + "`" + `. Headers: ` + "`" + `X-Webhook-Id` + "`" + `, ` + "`" + `X-Webhook-Timestamp` + "`" + ` (unix seconds) and ` + "`" + `X-Webhook-Signature` + "`" + ` = ` + "`" + `sha256=` + "`" + ` + hex HMAC-SHA256 of ` + "`" + `\u003ctimestamp\u003e.\u003cbody\u003e` + "`" + ` with ` + "`" + `callbackSecret` + "`" + ` as a key. Failed deliveries are retried with exponential backoff.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "Quotation"
                 ],
-                "summary": "Get quotation by request Id",
+                "summary": "Request quotation update",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Quotation ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Quotation request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/quotation.RequestQuotationUpdateBody"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/quotation.GetQuotationByRequestIdResponse"
+                            "$ref": "#/definitions/quotation.RequestQuotationUpdateResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/quotation/update-request-batch": {
+            "post": {
+                "description": "Creates quotation update requests for up to 100 pairs under one idempotency key, atomically. Returns batch Id, poll ` + "`" + `GET /api/v1/quotation/update-request-batch/{id}` + "`" + ` for per-pair status.\nRepeating the call with the same ` + "`" + `idempotencyKey` + "`" + ` returns the same batch, pairs of the repeated call are ignored.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Quotation"
+                ],
+                "summary": "Request quotation update for several pairs",
+                "parameters": [
+                    {
+                        "description": "Quotation batch request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/quotation.RequestQuotationBatchBody"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/quotation.RequestQuotationBatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/quotation/update-request-batch/{id}": {
+            "get": {
+                "description": "Retrieves per-pair status of a batch created with ` + "`" + `POST /api/v1/quotation/update-request-batch` + "`" + `. Items are ordered by base and quote currency",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Quotation"
+                ],
+                "summary": "Get quotation batch by Id",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Batch ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/quotation.GetQuotationBatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No batch with such id",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/quotation/update-request/{id}": {
+            "get": {
+                "description": "Retrieves a quotation by request Id. If request is not proceeded yet, returns status ` + "`" + `NotReady` + "`" + `. If request is completed, returns status ` + "`" + `Ready` + "`" + ` and fields ` + "`" + `rate` + "`" + ` and ` + "`" + `updatedAt` + "`" + `. If providers failed on every attempt, returns status ` + "`" + `Failed` + "`" + `, if request was not completed in time - ` + "`" + `Expired` + "`" + `, both with field ` + "`" + `error` + "`" + `. For historical requests ` + "`" + `asOf` + "`" + ` is returned as well and ` + "`" + `updatedAt` + "`" + ` is the date the rate was published for.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Quotation"
+                ],
+                "summary": "Get quotation by request Id",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Quotation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include per-provider breakdown of aggregated rate",
+                        "name": "detail",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/quotation.GetQuotationByRequestIdResponse"
                         }
                     },
                     "400": {
@@ -178,37 +608,508 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/response.ErrorResponse"
                         }
-                    },
-                    "500": {
-                        "description": "Internal server error",
-                        "schema": {
-                            "$ref": "#/definitions/response.ErrorResponse"
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/livez": {
+            "get": {
+                "description": "Returns ` + "`" + `200` + "`" + ` while the process is serving http",
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Returns ` + "`" + `503` + "`" + ` until the quotation cache is warmed up from the database after start",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "503": {
+                        "description": "Not ready",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "convert.ConvertBatchBody": {
+            "type": "object",
+            "required": [
+                "items"
+            ],
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "maxItems": 100,
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/convert.ConvertBatchItemBody"
+                    }
+                }
+            }
+        },
+        "convert.ConvertBatchItemBody": {
+            "type": "object",
+            "required": [
+                "amount",
+                "from",
+                "to"
+            ],
+            "properties": {
+                "amount": {
+                    "description": "Number or string, e.g. ` + "`" + `1234.56` + "`" + ` or ` + "`" + `\"1234.56\"` + "`" + `",
+                    "type": "string",
+                    "format": "decimal",
+                    "example": "1234.56"
+                },
+                "from": {
+                    "type": "string",
+                    "example": "USD"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "MXN"
+                }
+            }
+        },
+        "convert.ConvertBatchItemResponse": {
+            "description": "either ` + "`" + `result` + "`" + ` or ` + "`" + `error` + "`" + ` is presented",
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "Quotation was not requested yet"
+                },
+                "result": {
+                    "$ref": "#/definitions/convert.ConvertResponse"
+                }
+            }
+        },
+        "convert.ConvertBatchResponse": {
+            "type": "object",
+            "required": [
+                "items"
+            ],
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/convert.ConvertBatchItemResponse"
+                    }
+                }
+            }
+        },
+        "convert.ConvertResponse": {
+            "type": "object",
+            "required": [
+                "amount",
+                "from",
+                "rate",
+                "result",
+                "to",
+                "updatedAt"
+            ],
+            "properties": {
+                "amount": {
+                    "type": "string",
+                    "format": "decimal",
+                    "example": "1234.56"
+                },
+                "from": {
+                    "type": "string",
+                    "example": "USD"
+                },
+                "rate": {
+                    "type": "string",
+                    "format": "decimal",
+                    "example": "20.125000"
+                },
+                "result": {
+                    "description": "Converted amount rounded to minor units of ` + "`" + `to` + "`" + `",
+                    "type": "string",
+                    "format": "decimal",
+                    "example": "24845.52"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "MXN"
+                },
+                "updatedAt": {
+                    "description": "Unix timestamp in milliseconds of the rate used",
+                    "type": "integer",
+                    "format": "int64",
+                    "example": 1694613600000
+                }
+            }
+        },
+        "quotation.BatchItemDto": {
+            "description": "fields ` + "`" + `rate` + "`" + `, ` + "`" + `updatedAt` + "`" + ` and ` + "`" + `provider` + "`" + ` are only presented when status is ` + "`" + `Ready` + "`" + `, ` + "`" + `error` + "`" + ` - when status is ` + "`" + `Failed` + "`" + ` or ` + "`" + `Expired` + "`" + `",
+            "type": "object",
+            "required": [
+                "baseCurrency",
+                "quoteCurrency",
+                "requestId",
+                "status"
+            ],
+            "properties": {
+                "asOf": {
+                    "description": "Only presented for historical requests",
+                    "type": "string",
+                    "format": "date",
+                    "example": "2025-03-31"
+                },
+                "baseCurrency": {
+                    "type": "string",
+                    "example": "USD"
+                },
+                "error": {
+                    "$ref": "#/definitions/quotation.RequestErrorDto"
+                },
+                "provider": {
+                    "type": "string",
+                    "example": "frankfurter"
+                },
+                "quoteCurrency": {
+                    "type": "string",
+                    "example": "MXN"
+                },
+                "rate": {
+                    "type": "string",
+                    "format": "decimal",
+                    "example": "123.45"
+                },
+                "requestId": {
+                    "type": "string",
+                    "format": "uuid"
+                },
+                "status": {
+                    "$ref": "#/definitions/quotation.RequestStatus"
+                },
+                "updatedAt": {
+                    "description": "Unix timestamp in milliseconds",
+                    "type": "integer",
+                    "format": "int64",
+                    "example": 1694613600000
+                }
+            }
+        },
+        "quotation.CurrencyDto": {
+            "type": "object",
+            "required": [
+                "active",
+                "code",
+                "name",
+                "numericCode"
+            ],
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "code": {
+                    "type": "string",
+                    "example": "USD"
+                },
+                "minorUnits": {
+                    "description": "Absent for codes without minor units, e.g. ` + "`" + `XAU` + "`" + `",
+                    "type": "integer",
+                    "example": 2
+                },
+                "name": {
+                    "type": "string",
+                    "example": "US Dollar"
+                },
+                "numericCode": {
+                    "type": "string",
+                    "example": "840"
+                }
+            }
+        },
+        "quotation.CurrencyPairDto": {
+            "type": "object",
+            "required": [
+                "baseCurrency",
+                "quoteCurrency"
+            ],
+            "properties": {
+                "baseCurrency": {
+                    "$ref": "#/definitions/types.Currency"
+                },
+                "quoteCurrency": {
+                    "$ref": "#/definitions/types.Currency"
+                }
+            }
+        },
+        "quotation.GetCurrencyListResponse": {
+            "type": "object",
+            "required": [
+                "currencies"
+            ],
+            "properties": {
+                "currencies": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/quotation.CurrencyDto"
+                    }
+                }
+            }
+        },
+        "quotation.GetQuotationBatchResponse": {
+            "description": "status is ` + "`" + `NotReady` + "`" + ` until every pair is completed. Then it is ` + "`" + `Ready` + "`" + ` if every pair got a rate, ` + "`" + `Failed` + "`" + ` if none did and ` + "`" + `PartiallyFailed` + "`" + ` otherwise. ` + "`" + `completed` + "`" + ` counts ` + "`" + `Failed` + "`" + ` and ` + "`" + `Expired` + "`" + ` pairs as well, ` + "`" + `failed` + "`" + ` counts only them",
+            "type": "object",
+            "required": [
+                "batchId",
+                "completed",
+                "failed",
+                "items",
+                "status",
+                "total"
+            ],
+            "properties": {
+                "batchId": {
+                    "type": "string",
+                    "format": "uuid"
+                },
+                "completed": {
+                    "type": "integer",
+                    "example": 39
+                },
+                "failed": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/quotation.BatchItemDto"
+                    }
+                },
+                "status": {
+                    "$ref": "#/definitions/quotation.RequestStatus"
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 40
+                }
+            }
+        },
+        "quotation.GetQuotationByRequestIdResponse": {
+            "description": "fields ` + "`" + `rate` + "`" + ` and ` + "`" + `updatedAt` + "`" + ` are only presented when status is ` + "`" + `Ready` + "`" + `, ` + "`" + `error` + "`" + ` - when status is ` + "`" + `Failed` + "`" + ` or ` + "`" + `Expired` + "`" + `",
+            "type": "object",
+            "required": [
+                "status"
+            ],
+            "properties": {
+                "asOf": {
+                    "description": "Only presented for historical requests",
+                    "type": "string",
+                    "format": "date",
+                    "example": "2025-03-31"
+                },
+                "attempts": {
+                    "description": "How many times the rate was requested from providers",
+                    "type": "integer",
+                    "example": 1
+                },
+                "detail": {
+                    "description": "Only presented with ` + "`" + `detail=true` + "`" + ` when rates are aggregated across providers",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/quotation.RateDetailDto"
+                        }
+                    ]
+                },
+                "error": {
+                    "$ref": "#/definitions/quotation.RequestErrorDto"
+                },
+                "provider": {
+                    "description": "Rate provider that served the quotation",
+                    "type": "string",
+                    "example": "frankfurter"
+                },
+                "rate": {
+                    "type": "string",
+                    "format": "decimal",
+                    "example": "123.45"
+                },
+                "status": {
+                    "$ref": "#/definitions/quotation.RequestStatus"
+                },
+                "updatedAt": {
+                    "description": "Unix timestamp in milliseconds",
+                    "type": "integer",
+                    "format": "int64",
+                    "example": 1694613600000
+                }
+            }
+        },
+        "quotation.GetQuotationHistoryResponse": {
+            "description": "` + "`" + `points` + "`" + ` are filled when ` + "`" + `interval` + "`" + ` is not set, ` + "`" + `buckets` + "`" + ` otherwise. The other one is ` + "`" + `null` + "`" + `",
+            "type": "object",
+            "properties": {
+                "buckets": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/quotation.HistoryBucketDto"
+                    }
+                },
+                "points": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/quotation.HistoryPointDto"
+                    }
+                }
+            }
+        },
+        "quotation.GetQuotationResponse": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "description": "Only presented with ` + "`" + `detail=true` + "`" + ` when rates are aggregated across providers",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/quotation.RateDetailDto"
                         }
+                    ]
+                },
+                "rate": {
+                    "type": "string",
+                    "format": "decimal",
+                    "example": "123.45"
+                },
+                "updatedAt": {
+                    "description": "Unix timestamp in milliseconds",
+                    "type": "integer",
+                    "format": "int64",
+                    "example": 1694613600000
+                }
+            }
+        },
+        "quotation.GetSnapshotBody": {
+            "type": "object",
+            "required": [
+                "pairs"
+            ],
+            "properties": {
+                "pairs": {
+                    "type": "array",
+                    "maxItems": 500,
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/quotation.CurrencyPairDto"
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "quotation.GetCurrencyListResponse": {
+        },
+        "quotation.GetSnapshotByPairsResponse": {
             "type": "object",
             "required": [
-                "currencies"
+                "found",
+                "missing"
             ],
             "properties": {
-                "currencies": {
+                "found": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/quotation.SnapshotItemDto"
+                    }
+                },
+                "missing": {
+                    "description": "Pairs that were never requested, use ` + "`" + `POST /api/v1/quotation/update-request` + "`" + `",
                     "type": "array",
                     "items": {
-                        "type": "string"
+                        "$ref": "#/definitions/quotation.CurrencyPairDto"
                     }
                 }
             }
         },
-        "quotation.GetQuotationByRequestIdResponse": {
-            "description": "fields ` + "`" + `price` + "`" + ` and ` + "`" + `timestamp` + "`" + ` are only presented when status is ` + "`" + `Ready` + "`" + `",
+        "quotation.GetSnapshotResponse": {
             "type": "object",
             "required": [
-                "status"
+                "items"
+            ],
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/quotation.SnapshotItemDto"
+                    }
+                }
+            }
+        },
+        "quotation.HistoryBucketDto": {
+            "type": "object",
+            "required": [
+                "close",
+                "count",
+                "high",
+                "low",
+                "open",
+                "start"
+            ],
+            "properties": {
+                "close": {
+                    "type": "string",
+                    "format": "decimal",
+                    "example": "123.45"
+                },
+                "count": {
+                    "description": "Number of points in bucket",
+                    "type": "integer",
+                    "example": 12
+                },
+                "high": {
+                    "type": "string",
+                    "format": "decimal",
+                    "example": "123.45"
+                },
+                "low": {
+                    "type": "string",
+                    "format": "decimal",
+                    "example": "123.45"
+                },
+                "open": {
+                    "type": "string",
+                    "format": "decimal",
+                    "example": "123.45"
+                },
+                "start": {
+                    "description": "Unix timestamp in milliseconds, bucket start",
+                    "type": "integer",
+                    "format": "int64",
+                    "example": 1694613600000
+                }
+            }
+        },
+        "quotation.HistoryPointDto": {
+            "type": "object",
+            "required": [
+                "rate",
+                "timestamp"
             ],
             "properties": {
                 "rate": {
@@ -216,30 +1117,203 @@ const docTemplate = `{
                     "format": "decimal",
                     "example": "123.45"
                 },
-                "status": {
-                    "$ref": "#/definitions/quotation.RequestStatus"
+                "timestamp": {
+                    "description": "Unix timestamp in milliseconds",
+                    "type": "integer",
+                    "format": "int64",
+                    "example": 1694613600000
+                }
+            }
+        },
+        "quotation.RateDetailDto": {
+            "type": "object",
+            "required": [
+                "confidence",
+                "sources"
+            ],
+            "properties": {
+                "confidence": {
+                    "description": "Share of queried providers whose rate contributed to the median, 0..1",
+                    "type": "number",
+                    "example": 0.67
+                },
+                "sources": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/quotation.RateSourceDto"
+                    }
+                },
+                "synthetic": {
+                    "description": "Present if the rate was derived through a pivot currency or inverted instead of being fetched directly",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/quotation.SyntheticRateDto"
+                        }
+                    ]
+                }
+            }
+        },
+        "quotation.RateLegDto": {
+            "type": "object",
+            "required": [
+                "base",
+                "inverted",
+                "provider",
+                "quote",
+                "rate",
+                "updatedAt"
+            ],
+            "properties": {
+                "base": {
+                    "type": "string",
+                    "example": "EUR"
+                },
+                "inverted": {
+                    "description": "True if the leg enters the formula as 1/rate",
+                    "type": "boolean"
+                },
+                "provider": {
+                    "type": "string",
+                    "example": "ecb"
+                },
+                "quote": {
+                    "type": "string",
+                    "example": "USD"
+                },
+                "rate": {
+                    "type": "string",
+                    "format": "decimal",
+                    "example": "1.0812"
                 },
                 "updatedAt": {
                     "description": "Unix timestamp in milliseconds",
                     "type": "integer",
                     "format": "int64",
-                    "example": 1694613600
+                    "example": 1694613600000
                 }
             }
         },
-        "quotation.GetQuotationResponse": {
+        "quotation.RateSourceDto": {
+            "type": "object",
+            "required": [
+                "accepted",
+                "provider"
+            ],
+            "properties": {
+                "accepted": {
+                    "description": "False for outliers rejected by tolerance and failed providers",
+                    "type": "boolean"
+                },
+                "error": {
+                    "type": "string",
+                    "example": "rate not found"
+                },
+                "provider": {
+                    "type": "string",
+                    "example": "frankfurter"
+                },
+                "rate": {
+                    "description": "Absent if provider failed",
+                    "type": "string",
+                    "format": "decimal",
+                    "example": "123.45"
+                }
+            }
+        },
+        "quotation.RequestErrorDto": {
+            "description": "why the request ended up ` + "`" + `Failed` + "`" + ` or ` + "`" + `Expired` + "`" + `",
+            "type": "object",
+            "required": [
+                "code",
+                "message"
+            ],
+            "properties": {
+                "code": {
+                    "description": "One of ` + "`" + `rate_not_found` + "`" + `, ` + "`" + `provider_timeout` + "`" + `, ` + "`" + `provider_unavailable` + "`" + `, ` + "`" + `provider_error` + "`" + `, ` + "`" + `expired` + "`" + `",
+                    "type": "string",
+                    "example": "rate_not_found"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "rate not found for USD/XAU"
+                }
+            }
+        },
+        "quotation.RequestQuotationBatchBody": {
+            "type": "object",
+            "required": [
+                "idempotencyKey",
+                "pairs"
+            ],
+            "properties": {
+                "asOf": {
+                    "description": "Optional. Date of historical rate for all pairs. Latest rates are requested when not set",
+                    "type": "string",
+                    "format": "date",
+                    "example": "2025-03-31"
+                },
+                "idempotencyKey": {
+                    "type": "string",
+                    "format": "uuid"
+                },
+                "pairs": {
+                    "type": "array",
+                    "maxItems": 100,
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/quotation.CurrencyPairDto"
+                    }
+                }
+            }
+        },
+        "quotation.RequestQuotationBatchResponse": {
+            "type": "object",
+            "required": [
+                "batchId"
+            ],
+            "properties": {
+                "batchId": {
+                    "type": "string",
+                    "format": "uuid"
+                }
+            }
+        },
+        "quotation.RequestQuotationResponse": {
+            "description": "fields ` + "`" + `rate` + "`" + ` and ` + "`" + `updatedAt` + "`" + ` are only presented when status is ` + "`" + `Ready` + "`" + `, ` + "`" + `error` + "`" + ` - when status is ` + "`" + `Failed` + "`" + ` or ` + "`" + `Expired` + "`" + `",
             "type": "object",
+            "required": [
+                "requestId",
+                "status"
+            ],
             "properties": {
+                "detail": {
+                    "description": "Only presented with ` + "`" + `detail=true` + "`" + ` when rates are aggregated across providers",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/quotation.RateDetailDto"
+                        }
+                    ]
+                },
+                "error": {
+                    "$ref": "#/definitions/quotation.RequestErrorDto"
+                },
                 "rate": {
                     "type": "string",
                     "format": "decimal",
                     "example": "123.45"
                 },
+                "requestId": {
+                    "type": "string",
+                    "format": "uuid"
+                },
+                "status": {
+                    "$ref": "#/definitions/quotation.RequestStatus"
+                },
                 "updatedAt": {
                     "description": "Unix timestamp in milliseconds",
                     "type": "integer",
                     "format": "int64",
-                    "example": 1694613600
+                    "example": 1694613600000
                 }
             }
         },
@@ -251,9 +1325,23 @@ const docTemplate = `{
                 "quoteCurrency"
             ],
             "properties": {
+                "asOf": {
+                    "description": "Optional. Date of historical rate, e.g. for month-end accounting. Latest rate is requested when not set",
+                    "type": "string",
+                    "format": "date",
+                    "example": "2025-03-31"
+                },
                 "baseCurrency": {
                     "$ref": "#/definitions/types.Currency"
                 },
+                "callbackSecret": {
+                    "type": "string"
+                },
+                "callbackUrl": {
+                    "description": "Optional. When set, final result (` + "`" + `Ready` + "`" + `, ` + "`" + `Failed` + "`" + ` or ` + "`" + `Expired` + "`" + `) is POSTed to this url, signed with ` + "`" + `callbackSecret` + "`" + `",
+                    "type": "string",
+                    "example": "https://example.com/quotation-hook"
+                },
                 "idempotencyKey": {
                     "type": "string",
                     "format": "uuid"
@@ -279,13 +1367,78 @@ const docTemplate = `{
             "type": "string",
             "enum": [
                 "Ready",
-                "NotReady"
+                "NotReady",
+                "Failed",
+                "Expired",
+                "PartiallyFailed"
             ],
             "x-enum-varnames": [
                 "Ready",
-                "NotReady"
+                "NotReady",
+                "Failed",
+                "Expired",
+                "PartiallyFailed"
             ]
         },
+        "quotation.SnapshotItemDto": {
+            "type": "object",
+            "required": [
+                "baseCurrency",
+                "quoteCurrency",
+                "rate",
+                "stale",
+                "updatedAt"
+            ],
+            "properties": {
+                "baseCurrency": {
+                    "type": "string",
+                    "example": "USD"
+                },
+                "provider": {
+                    "type": "string",
+                    "example": "frankfurter"
+                },
+                "quoteCurrency": {
+                    "type": "string",
+                    "example": "MXN"
+                },
+                "rate": {
+                    "type": "string",
+                    "format": "decimal",
+                    "example": "123.45"
+                },
+                "stale": {
+                    "description": "True if the rate is older than server setting ` + "`" + `QUOTATION_MAX_AGE` + "`" + `",
+                    "type": "boolean"
+                },
+                "updatedAt": {
+                    "description": "Unix timestamp in milliseconds",
+                    "type": "integer",
+                    "format": "int64",
+                    "example": 1694613600000
+                }
+            }
+        },
+        "quotation.SyntheticRateDto": {
+            "description": "rate = (pivot/quote) / (pivot/base), or 1 / (pivot/base) if quote is the pivot. Without pivot the rate is the inverse 1 / (quote/base) of the single leg. Division is carried to 16 decimal places before the usual rounding",
+            "type": "object",
+            "required": [
+                "legs"
+            ],
+            "properties": {
+                "legs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/quotation.RateLegDto"
+                    }
+                },
+                "pivot": {
+                    "description": "Empty for the inverse rate",
+                    "type": "string",
+                    "example": "EUR"
+                }
+            }
+        },
         "response.ErrorResponse": {
             "type": "object",
             "properties": {
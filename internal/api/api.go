@@ -3,6 +3,8 @@ package api
 import (
 	"log/slog"
 	"net/http"
+	"plata_currency_quotation/internal/api/convert"
+	"plata_currency_quotation/internal/api/health"
 	"plata_currency_quotation/internal/api/quotation"
 	"plata_currency_quotation/internal/lib/config"
 	"plata_currency_quotation/internal/lib/env"
@@ -25,8 +27,11 @@ const (
 func RegisterRoutes(router *chi.Mux, log *slog.Logger) {
 	router.Route("/api", func(router chi.Router) {
 		quotation.RegisterRoutes(router, log)
+		convert.RegisterRoutes(router, log)
 	})
 
+	health.RegisterRoutes(router, log)
+
 	if config.Instance.Env != env.Prod {
 		handler := httpSwagger.WrapHandler
 
@@ -0,0 +1,37 @@
+package convert
+
+import "plata_currency_quotation/internal/domain/types"
+
+const MaxBatchItems = 100
+
+type ConvertResponse struct {
+	From   types.Currency `json:"from" example:"USD" swaggertype:"string" binding:"required"`
+	To     types.Currency `json:"to" example:"MXN" swaggertype:"string" binding:"required"`
+	Amount types.Decimal  `json:"amount" example:"1234.56" swaggertype:"string" format:"decimal" binding:"required"`
+	// Converted amount rounded to minor units of `to`
+	Result types.Decimal `json:"result" example:"24845.52" swaggertype:"string" format:"decimal" binding:"required"`
+	Rate   types.Decimal `json:"rate" example:"20.125000" swaggertype:"string" format:"decimal" binding:"required"`
+	// Unix timestamp in milliseconds of the rate used
+	UpdatedAt int64 `json:"updatedAt" example:"1694613600000" swaggertype:"integer" format:"int64" binding:"required"`
+}
+
+type ConvertBatchItemBody struct {
+	From types.Currency `json:"from" example:"USD" swaggertype:"string" validate:"required,enum"`
+	To   types.Currency `json:"to" example:"MXN" swaggertype:"string" validate:"required,enum"`
+	// Number or string, e.g. `1234.56` or `"1234.56"`
+	Amount *types.Decimal `json:"amount" example:"1234.56" swaggertype:"string" format:"decimal" validate:"required"`
+}
+
+type ConvertBatchBody struct {
+	Items []ConvertBatchItemBody `json:"items" validate:"required,min=1,max=100,dive"`
+}
+
+// @Description either `result` or `error` is presented
+type ConvertBatchItemResponse struct {
+	Result *ConvertResponse `json:"result,omitempty"`
+	Error  string           `json:"error,omitempty" example:"Quotation was not requested yet"`
+}
+
+type ConvertBatchResponse struct {
+	Items []ConvertBatchItemResponse `json:"items" binding:"required"`
+}
@@ -0,0 +1,159 @@
+package convert
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	qr "plata_currency_quotation/internal/domain/enity/quotation-request"
+	"plata_currency_quotation/internal/domain/types"
+	"plata_currency_quotation/internal/lib/config"
+	"plata_currency_quotation/internal/lib/http-server/response"
+	"plata_currency_quotation/internal/lib/logger/sl"
+	"plata_currency_quotation/internal/lib/validator"
+	qry "plata_currency_quotation/internal/usecase/query"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func RegisterRoutes(router chi.Router, log *slog.Logger) {
+	// /v1 уже смонтирован пакетом quotation, второй Route на тот же префикс chi не допускает
+	router.Get("/v1/convert", convert(log))
+	router.Post("/v1/convert", convertBatch(log))
+}
+
+// @Summary Convert amount
+// @Description Converts amount using the last cached quotation, see `GET /api/v1/quotation/last-requested`. Result is rounded to minor units of `to`. Returns `409` if the cached rate is older than server setting `QUOTATION_MAX_AGE`, request an update with `POST /api/v1/quotation/update-request` in this case
+// @Tags Convert
+// @Produce json
+// @Param from query string true "Source Currency"
+// @Param to query string true "Target Currency"
+// @Param amount query string true "Non-negative decimal amount, e.g. `1234.56`"
+// @Success 200 {object} ConvertResponse
+// @Failure 400 {object} response.ErrorResponse "Validation error"
+// @Failure 404 {object} response.ErrorResponse "Quotation not found"
+// @Failure 409 {object} response.ErrorResponse "Quotation is too old"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /api/v1/convert [get]
+func convert(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+
+		from := types.Currency(params.Get("from"))
+		to := types.Currency(params.Get("to"))
+
+		log = log.With(sl.TraceId(r.Context()))
+
+		if !from.IsValid() {
+			response.Error(w, http.StatusBadRequest, "Invalid from currency", log)
+
+			return
+		}
+
+		if !to.IsValid() {
+			response.Error(w, http.StatusBadRequest, "Invalid to currency", log)
+
+			return
+		}
+
+		amount, err := types.ParseDecimal(params.Get("amount"))
+
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "Invalid amount. Should be decimal number, e.g. 1234.56", log)
+
+			return
+		}
+
+		result, status, message := run(r, log, from, to, amount)
+
+		if result == nil {
+			response.Error(w, status, message, log)
+
+			return
+		}
+
+		response.Ok(w, log, result)
+	}
+}
+
+// @Summary Convert amounts in batch
+// @Description Converts up to 100 amounts like `GET /api/v1/convert`. Items are processed independently, failed ones contain `error` instead of `result`
+// @Tags Convert
+// @Accept json
+// @Produce json
+// @Param request body ConvertBatchBody true "Amounts to convert"
+// @Success 200 {object} ConvertBatchResponse
+// @Failure 400 {object} response.ErrorResponse "Validation error"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /api/v1/convert [post]
+func convertBatch(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request ConvertBatchBody
+
+		log = log.With(sl.TraceId(r.Context()))
+
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			response.Error(w, http.StatusBadRequest, err.Error(), log)
+
+			return
+		}
+
+		if err := validator.Struct(request); err != nil {
+			response.Error(w, http.StatusBadRequest, err.Error(), log)
+
+			return
+		}
+
+		items := make([]ConvertBatchItemResponse, 0, len(request.Items))
+
+		for _, item := range request.Items {
+			result, status, message := run(r, log, item.From, item.To, *item.Amount)
+
+			if status == http.StatusInternalServerError {
+				response.Error(w, status, message, log)
+
+				return
+			}
+
+			items = append(items, ConvertBatchItemResponse{Result: result, Error: message})
+		}
+
+		response.Ok(w, log, ConvertBatchResponse{Items: items})
+	}
+}
+
+// run возвращает либо результат, либо http статус и сообщение об ошибке
+func run(r *http.Request, log *slog.Logger, from types.Currency, to types.Currency, amount types.Decimal) (*ConvertResponse, int, string) {
+	query := qry.ConvertAmount{
+		From:   from,
+		To:     to,
+		Amount: amount,
+		MaxAge: config.Instance.QuotationMaxAge,
+	}
+
+	result, err := query.Run(r.Context(), log)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, qr.ErrSameCurrency):
+			return nil, http.StatusBadRequest, "Currencies can't be same"
+		case errors.Is(err, qry.ErrNegativeAmount):
+			return nil, http.StatusBadRequest, "Amount can't be negative"
+		case errors.Is(err, qry.ErrNoQuotationData):
+			return nil, http.StatusNotFound, "Quotation was not requested yet"
+		case errors.Is(err, qry.ErrQuotationTooOld):
+			return nil, http.StatusConflict, "Quotation is too old, request an update"
+		default:
+			return nil, http.StatusInternalServerError, "Something went wrong"
+		}
+	}
+
+	return &ConvertResponse{
+		From:      from,
+		To:        to,
+		Amount:    amount,
+		Result:    result.Result,
+		Rate:      result.Rate,
+		UpdatedAt: result.UpdatedAt.UnixMilli(),
+	}, http.StatusOK, ""
+}
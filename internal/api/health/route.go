@@ -0,0 +1,45 @@
+package health
+
+import (
+	"log/slog"
+	"net/http"
+	"plata_currency_quotation/internal/lib/http-server/response"
+	qm "plata_currency_quotation/internal/service/quotation-manager"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func RegisterRoutes(router chi.Router, log *slog.Logger) {
+	router.Get("/livez", live(log))
+	router.Get("/readyz", ready(log))
+}
+
+// @Summary Liveness probe
+// @Description Returns `200` while the process is serving http
+// @Tags Health
+// @Success 200
+// @Router /livez [get]
+func live(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response.Ok(w, log, nil)
+	}
+}
+
+// @Summary Readiness probe
+// @Description Returns `503` until the quotation cache is warmed up from the database after start
+// @Tags Health
+// @Produce json
+// @Success 200
+// @Failure 503 {object} response.ErrorResponse "Not ready"
+// @Router /readyz [get]
+func ready(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !qm.Instance.Ready() {
+			response.Error(w, http.StatusServiceUnavailable, "Quotation cache is warming up", log)
+
+			return
+		}
+
+		response.Ok(w, log, nil)
+	}
+}
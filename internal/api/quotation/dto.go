@@ -10,29 +10,143 @@ type RequestQuotationUpdateBody struct {
 	BaseCurrency   types.Currency `json:"baseCurrency" validate:"required,enum"`
 	QuoteCurrency  types.Currency `json:"quoteCurrency" validate:"required,enum"`
 	IdempotencyKey uuid.UUID      `json:"idempotencyKey" format:"uuid" validate:"required,uuid"`
+	// Optional. When set, final result (`Ready`, `Failed` or `Expired`) is POSTed to this url, signed with `callbackSecret`
+	CallbackUrl    string `json:"callbackUrl,omitempty" example:"https://example.com/quotation-hook" validate:"omitempty,http_url"`
+	CallbackSecret string `json:"callbackSecret,omitempty" validate:"required_with=CallbackUrl"`
+	// Optional. Date of historical rate, e.g. for month-end accounting. Latest rate is requested when not set
+	AsOf string `json:"asOf,omitempty" example:"2025-03-31" format:"date" validate:"omitempty,datetime=2006-01-02"`
 }
 
+const AsOfLayout = "2006-01-02"
+
 type RequestQuotationUpdateResponse struct {
 	RequestId uuid.UUID `json:"requestId" swaggertype:"string" format:"uuid" binding:"required"`
 }
 
+type CurrencyPairDto struct {
+	BaseCurrency  types.Currency `json:"baseCurrency" validate:"required,enum"`
+	QuoteCurrency types.Currency `json:"quoteCurrency" validate:"required,enum"`
+}
+
+type RequestQuotationBatchBody struct {
+	IdempotencyKey uuid.UUID         `json:"idempotencyKey" format:"uuid" validate:"required,uuid"`
+	Pairs          []CurrencyPairDto `json:"pairs" validate:"required,min=1,max=100,dive"`
+	// Optional. Date of historical rate for all pairs. Latest rates are requested when not set
+	AsOf string `json:"asOf,omitempty" example:"2025-03-31" format:"date" validate:"omitempty,datetime=2006-01-02"`
+}
+
+type RequestQuotationBatchResponse struct {
+	BatchId uuid.UUID `json:"batchId" swaggertype:"string" format:"uuid" binding:"required"`
+}
+
+// @Description status is `NotReady` until every pair is completed. Then it is `Ready` if every pair got a rate, `Failed` if none did and `PartiallyFailed` otherwise. `completed` counts `Failed` and `Expired` pairs as well, `failed` counts only them
+type GetQuotationBatchResponse struct {
+	BatchId   uuid.UUID      `json:"batchId" swaggertype:"string" format:"uuid" binding:"required"`
+	Status    RequestStatus  `json:"status" binding:"required"`
+	Completed int            `json:"completed" example:"39" binding:"required"`
+	Failed    int            `json:"failed" example:"1" binding:"required"`
+	Total     int            `json:"total" example:"40" binding:"required"`
+	Items     []BatchItemDto `json:"items" binding:"required"`
+}
+
+// @Description fields `rate`, `updatedAt` and `provider` are only presented when status is `Ready`, `error` - when status is `Failed` or `Expired`
+type BatchItemDto struct {
+	RequestId     uuid.UUID      `json:"requestId" swaggertype:"string" format:"uuid" binding:"required"`
+	BaseCurrency  types.Currency `json:"baseCurrency" example:"USD" swaggertype:"string" binding:"required"`
+	QuoteCurrency types.Currency `json:"quoteCurrency" example:"MXN" swaggertype:"string" binding:"required"`
+	Status        RequestStatus  `json:"status" binding:"required"`
+	Rate          *types.Decimal `json:"rate,omitempty" example:"123.45" swaggertype:"string" format:"decimal"`
+	// Unix timestamp in milliseconds
+	UpdatedAt int64  `json:"updatedAt,omitempty" example:"1694613600000" swaggertype:"integer" format:"int64"`
+	Provider  string `json:"provider,omitempty" example:"frankfurter"`
+	// Only presented for historical requests
+	AsOf  string           `json:"asOf,omitempty" example:"2025-03-31" format:"date"`
+	Error *RequestErrorDto `json:"error,omitempty"`
+}
+
+type SnapshotItemDto struct {
+	BaseCurrency  types.Currency `json:"baseCurrency" example:"USD" swaggertype:"string" binding:"required"`
+	QuoteCurrency types.Currency `json:"quoteCurrency" example:"MXN" swaggertype:"string" binding:"required"`
+	Rate          types.Decimal  `json:"rate" example:"123.45" swaggertype:"string" format:"decimal" binding:"required"`
+	// Unix timestamp in milliseconds
+	UpdatedAt int64  `json:"updatedAt" example:"1694613600000" swaggertype:"integer" format:"int64" binding:"required"`
+	Provider  string `json:"provider,omitempty" example:"frankfurter"`
+	// True if the rate is older than server setting `QUOTATION_MAX_AGE`
+	Stale bool `json:"stale" binding:"required"`
+}
+
+type GetSnapshotResponse struct {
+	Items []SnapshotItemDto `json:"items" binding:"required"`
+}
+
+type GetSnapshotBody struct {
+	Pairs []CurrencyPairDto `json:"pairs" validate:"required,min=1,max=500,dive"`
+}
+
+type GetSnapshotByPairsResponse struct {
+	Found []SnapshotItemDto `json:"found" binding:"required"`
+	// Pairs that were never requested, use `POST /api/v1/quotation/update-request`
+	Missing []CurrencyPairDto `json:"missing" binding:"required"`
+}
+
+type CurrencyDto struct {
+	Code        types.Currency `json:"code" example:"USD" swaggertype:"string" binding:"required"`
+	NumericCode string         `json:"numericCode" example:"840" binding:"required"`
+	// Absent for codes without minor units, e.g. `XAU`
+	MinorUnits *int   `json:"minorUnits,omitempty" example:"2"`
+	Name       string `json:"name" example:"US Dollar" binding:"required"`
+	Active     bool   `json:"active" binding:"required"`
+}
+
+// @Description fields `rate` and `updatedAt` are only presented when status is `Ready`, `error` - when status is `Failed` or `Expired`
+type RequestQuotationResponse struct {
+	RequestId uuid.UUID      `json:"requestId" swaggertype:"string" format:"uuid" binding:"required"`
+	Status    RequestStatus  `json:"status" binding:"required"`
+	Rate      *types.Decimal `json:"rate,omitempty" example:"123.45" swaggertype:"string" format:"decimal"`
+	// Unix timestamp in milliseconds
+	UpdatedAt int64 `json:"updatedAt,omitempty" example:"1694613600000" swaggertype:"integer" format:"int64"`
+	// Only presented with `detail=true` when rates are aggregated across providers
+	Detail *RateDetailDto   `json:"detail,omitempty"`
+	Error  *RequestErrorDto `json:"error,omitempty"`
+}
+
 type GetCurrencyListResponse struct {
-	Currencies []types.Currency `json:"currencies" swaggertype:"array,string" binding:"required"`
+	Currencies []CurrencyDto `json:"currencies" binding:"required"`
 }
 
 type RequestStatus string
 
+// NotReady - запрос еще ждет курса (Pending или InProgress). PartiallyFailed - только у пачки
 const (
-	Ready    RequestStatus = "Ready"
-	NotReady RequestStatus = "NotReady"
+	Ready           RequestStatus = "Ready"
+	NotReady        RequestStatus = "NotReady"
+	Failed          RequestStatus = "Failed"
+	Expired         RequestStatus = "Expired"
+	PartiallyFailed RequestStatus = "PartiallyFailed"
 )
 
-// @Description fields `price` and `timestamp` are only presented when status is `Ready`
+// @Description why the request ended up `Failed` or `Expired`
+type RequestErrorDto struct {
+	// One of `rate_not_found`, `provider_timeout`, `provider_unavailable`, `provider_error`, `expired`
+	Code    string `json:"code" example:"rate_not_found" binding:"required"`
+	Message string `json:"message" example:"rate not found for USD/XAU" binding:"required"`
+}
+
+// @Description fields `rate` and `updatedAt` are only presented when status is `Ready`, `error` - when status is `Failed` or `Expired`
 type GetQuotationByRequestIdResponse struct {
-	Status RequestStatus `json:"status" binding:"required"`
-	Rate   string        `json:"rate" example:"123.45" swaggertype:"string" format:"decimal"`
+	Status RequestStatus  `json:"status" binding:"required"`
+	Rate   *types.Decimal `json:"rate,omitempty" example:"123.45" swaggertype:"string" format:"decimal"`
 	// Unix timestamp in milliseconds
-	UpdatedAt int64 `json:"updatedAt" example:"1694613600" swaggertype:"integer" format:"int64"`
+	UpdatedAt int64 `json:"updatedAt,omitempty" example:"1694613600000" swaggertype:"integer" format:"int64"`
+	// How many times the rate was requested from providers
+	Attempts int              `json:"attempts" example:"1"`
+	Error    *RequestErrorDto `json:"error,omitempty"`
+	// Only presented for historical requests
+	AsOf string `json:"asOf,omitempty" example:"2025-03-31" format:"date"`
+	// Rate provider that served the quotation
+	Provider string `json:"provider,omitempty" example:"frankfurter"`
+	// Only presented with `detail=true` when rates are aggregated across providers
+	Detail *RateDetailDto `json:"detail,omitempty"`
 }
 
 type GetQuotationByRequestIdResponseNotReady struct {
@@ -40,7 +154,69 @@ type GetQuotationByRequestIdResponseNotReady struct {
 }
 
 type GetQuotationResponse struct {
-	Rate string `json:"rate" example:"123.45" swaggertype:"string" format:"decimal"`
+	Rate types.Decimal `json:"rate" example:"123.45" swaggertype:"string" format:"decimal"`
+	// Unix timestamp in milliseconds
+	UpdatedAt int64 `json:"updatedAt" example:"1694613600000" swaggertype:"integer" format:"int64"`
+	// Only presented with `detail=true` when rates are aggregated across providers
+	Detail *RateDetailDto `json:"detail,omitempty"`
+}
+
+type RateDetailDto struct {
+	// Share of queried providers whose rate contributed to the median, 0..1
+	Confidence float64         `json:"confidence" example:"0.67" binding:"required"`
+	Sources    []RateSourceDto `json:"sources" binding:"required"`
+	// Present if the rate was derived through a pivot currency or inverted instead of being fetched directly
+	Synthetic *SyntheticRateDto `json:"synthetic,omitempty"`
+}
+
+// @Description rate = (pivot/quote) / (pivot/base), or 1 / (pivot/base) if quote is the pivot.
+// @Description Without pivot the rate is the inverse 1 / (quote/base) of the single leg.
+// @Description Division is carried to 16 decimal places before the usual rounding
+type SyntheticRateDto struct {
+	// Empty for the inverse rate
+	Pivot types.Currency `json:"pivot,omitempty" example:"EUR" swaggertype:"string"`
+	Legs  []RateLegDto   `json:"legs" binding:"required"`
+}
+
+type RateLegDto struct {
+	Base  types.Currency `json:"base" example:"EUR" swaggertype:"string" binding:"required"`
+	Quote types.Currency `json:"quote" example:"USD" swaggertype:"string" binding:"required"`
+	Rate  types.Decimal  `json:"rate" example:"1.0812" swaggertype:"string" format:"decimal" binding:"required"`
 	// Unix timestamp in milliseconds
-	UpdatedAt int64 `json:"updatedAt" example:"1694613600" swaggertype:"integer" format:"int64"`
+	UpdatedAt int64  `json:"updatedAt" example:"1694613600000" swaggertype:"integer" format:"int64" binding:"required"`
+	Provider  string `json:"provider" example:"ecb" binding:"required"`
+	// True if the leg enters the formula as 1/rate
+	Inverted bool `json:"inverted" binding:"required"`
+}
+
+type RateSourceDto struct {
+	Provider string `json:"provider" example:"frankfurter" binding:"required"`
+	// Absent if provider failed
+	Rate *types.Decimal `json:"rate,omitempty" example:"123.45" swaggertype:"string" format:"decimal"`
+	// False for outliers rejected by tolerance and failed providers
+	Accepted bool   `json:"accepted" binding:"required"`
+	Error    string `json:"error,omitempty" example:"rate not found"`
+}
+
+type HistoryPointDto struct {
+	Rate types.Decimal `json:"rate" example:"123.45" swaggertype:"string" format:"decimal" binding:"required"`
+	// Unix timestamp in milliseconds
+	Timestamp int64 `json:"timestamp" example:"1694613600000" swaggertype:"integer" format:"int64" binding:"required"`
+}
+
+type HistoryBucketDto struct {
+	// Unix timestamp in milliseconds, bucket start
+	Start int64         `json:"start" example:"1694613600000" swaggertype:"integer" format:"int64" binding:"required"`
+	Open  types.Decimal `json:"open" example:"123.45" swaggertype:"string" format:"decimal" binding:"required"`
+	High  types.Decimal `json:"high" example:"123.45" swaggertype:"string" format:"decimal" binding:"required"`
+	Low   types.Decimal `json:"low" example:"123.45" swaggertype:"string" format:"decimal" binding:"required"`
+	Close types.Decimal `json:"close" example:"123.45" swaggertype:"string" format:"decimal" binding:"required"`
+	// Number of points in bucket
+	Count int `json:"count" example:"12" binding:"required"`
+}
+
+// @Description `points` are filled when `interval` is not set, `buckets` otherwise. The other one is `null`
+type GetQuotationHistoryResponse struct {
+	Points  []HistoryPointDto  `json:"points"`
+	Buckets []HistoryBucketDto `json:"buckets"`
 }
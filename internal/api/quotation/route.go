@@ -5,13 +5,17 @@ import (
 	"errors"
 	"log/slog"
 	"net/http"
+	qb "plata_currency_quotation/internal/domain/enity/quotation-batch"
 	qr "plata_currency_quotation/internal/domain/enity/quotation-request"
 	"plata_currency_quotation/internal/domain/types"
+	"plata_currency_quotation/internal/lib/config"
 	"plata_currency_quotation/internal/lib/http-server/response"
 	"plata_currency_quotation/internal/lib/logger/sl"
 	"plata_currency_quotation/internal/lib/validator"
 	"plata_currency_quotation/internal/usecase/command"
 	qry "plata_currency_quotation/internal/usecase/query"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -19,15 +23,21 @@ import (
 
 func RegisterRoutes(router chi.Router, log *slog.Logger) {
 	router.Route("/v1", func(router chi.Router) {
+		router.Post("/quotation", requestQuotation(log))
 		router.Post("/quotation/update-request", requestQuotationUpdate(log))
 		router.Get("/quotation/update-request/{id}", getQuotationByRequestId(log))
+		router.Post("/quotation/update-request-batch", requestQuotationBatchUpdate(log))
+		router.Get("/quotation/update-request-batch/{id}", getQuotationBatch(log))
 		router.Get("/quotation/last-requested", getQuotation(log))
+		router.Get("/quotation/snapshot", getSnapshot(log))
+		router.Post("/quotation/snapshot", getSnapshotByPairs(log))
+		router.Get("/quotation/history", getQuotationHistory(log))
 		router.Get("/currency/list", getCurrencyList(log))
 	})
 }
 
 // @Summary Get list of supported currencies
-// @Description Returns list of supported currency codes in [ISO 4217](https://en.wikipedia.org/wiki/ISO_4217) format
+// @Description Returns list of enabled currencies with [ISO 4217](https://en.wikipedia.org/wiki/ISO_4217) metadata: alpha code, numeric code, minor units, name and active flag
 // @Tags Currency
 // @Produce json
 // @Success 200 {object} GetCurrencyListResponse
@@ -37,12 +47,36 @@ func getCurrencyList(log *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		log = log.With(sl.TraceId(r.Context()))
 
-		response.Ok(w, log, GetCurrencyListResponse{Currencies: types.AllCurrencies()})
+		enabled := types.Registry.Enabled()
+		currencies := make([]CurrencyDto, 0, len(enabled))
+
+		for _, info := range enabled {
+			currencies = append(currencies, toCurrencyDto(info))
+		}
+
+		response.Ok(w, log, GetCurrencyListResponse{Currencies: currencies})
 	}
 }
 
+func toCurrencyDto(info types.CurrencyInfo) CurrencyDto {
+	dto := CurrencyDto{
+		Code:        info.Code,
+		NumericCode: info.NumericCode,
+		Name:        info.Name,
+		Active:      info.Active,
+	}
+
+	if info.MinorUnits != types.MinorUnitsNotApplicable {
+		minorUnits := info.MinorUnits
+		dto.MinorUnits = &minorUnits
+	}
+
+	return dto
+}
+
 // @Summary Request quotation update
 // @Description Creates a quotation update request. Use [ISO 4217](https://en.wikipedia.org/wiki/ISO_4217) currency code. List of supported currencies - `GET /api/v1/currency/list`. Returns request Id.
+// @Description If `callbackUrl` is set, the result is POSTed there once the request is `Ready`, `Failed` or `Expired`. Body: `requestId`, `baseCurrency`, `quoteCurrency`, `status`, `rate` and `updatedAt` for `Ready`, `error` with `code` and `message` for `Failed` and `Expired`. Headers: `X-Webhook-Id`, `X-Webhook-Timestamp` (unix seconds) and `X-Webhook-Signature` = `sha256=` + hex HMAC-SHA256 of `<timestamp>.<body>` with `callbackSecret` as a key. Failed deliveries are retried with exponential backoff.
 // @Tags Quotation
 // @Accept json
 // @Produce json
@@ -69,11 +103,7 @@ func requestQuotationUpdate(log *slog.Logger) http.HandlerFunc {
 			return
 		}
 
-		command := cmd.UpdateQuotation{
-			BaseCurrency:   request.BaseCurrency,
-			QuoteCurrency:  request.QuoteCurrency,
-			IdempotencyKey: request.IdempotencyKey,
-		}
+		command := toUpdateQuotationCommand(request)
 
 		result, err := command.Execute(r.Context(), log)
 
@@ -81,6 +111,8 @@ func requestQuotationUpdate(log *slog.Logger) http.HandlerFunc {
 			switch {
 			case errors.Is(err, qr.ErrSameCurrency):
 				response.Error(w, http.StatusBadRequest, "Currencies can't be same", log)
+			case errors.Is(err, qr.ErrAsOfInFuture):
+				response.Error(w, http.StatusBadRequest, "AsOf can't be in the future", log)
 			default:
 				response.Error(w, http.StatusInternalServerError, "Something went wrong", log)
 			}
@@ -92,11 +124,277 @@ func requestQuotationUpdate(log *slog.Logger) http.HandlerFunc {
 	}
 }
 
+// @Summary Request quotation update and wait for the result
+// @Description Creates a quotation update request like `POST /api/v1/quotation/update-request` and blocks until it is completed or `wait` runs out. On timeout returns status `NotReady` and request Id, poll `GET /api/v1/quotation/update-request/{id}` in this case
+// @Tags Quotation
+// @Accept json
+// @Produce json
+// @Param request body RequestQuotationUpdateBody true "Quotation request"
+// @Param wait query integer false "Max wait in milliseconds. Defaults to and is capped by server setting `MAX_QUOTATION_WAIT`"
+// @Param detail query boolean false "Include per-provider breakdown of aggregated rate"
+// @Success 200 {object} RequestQuotationResponse
+// @Failure 400 {object} response.ErrorResponse "Validation error"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /api/v1/quotation [post]
+func requestQuotation(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request RequestQuotationUpdateBody
+
+		log = log.With(sl.TraceId(r.Context()))
+
+		wait := config.Instance.MaxQuotationWait
+
+		if rawWait := r.URL.Query().Get("wait"); rawWait != "" {
+			milliseconds, err := strconv.ParseInt(rawWait, 10, 64)
+
+			if err != nil || milliseconds < 0 {
+				response.Error(w, http.StatusBadRequest, "Invalid wait. Should be non-negative number of milliseconds", log)
+
+				return
+			}
+
+			wait = min(wait, time.Duration(milliseconds)*time.Millisecond)
+		}
+
+		detail, err := parseDetailFlag(r)
+
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "Invalid detail. Should be boolean", log)
+
+			return
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			response.Error(w, http.StatusBadRequest, err.Error(), log)
+
+			return
+		}
+
+		if err := validator.Struct(request); err != nil {
+			response.Error(w, http.StatusBadRequest, err.Error(), log)
+
+			return
+		}
+
+		command := cmd.UpdateQuotationAndWait{
+			UpdateQuotation: toUpdateQuotationCommand(request),
+			Wait:            wait,
+		}
+
+		result, err := command.Execute(r.Context(), log)
+
+		if err != nil {
+			switch {
+			case errors.Is(err, qr.ErrSameCurrency):
+				response.Error(w, http.StatusBadRequest, "Currencies can't be same", log)
+			case errors.Is(err, qr.ErrAsOfInFuture):
+				response.Error(w, http.StatusBadRequest, "AsOf can't be in the future", log)
+			default:
+				response.Error(w, http.StatusInternalServerError, "Something went wrong", log)
+			}
+
+			return
+		}
+
+		if !result.Ready {
+			response.Ok(w, log, RequestQuotationResponse{
+				RequestId: result.Id,
+				Status:    toRequestStatus(result.Status),
+				Error:     toRequestErrorDto(result.Error),
+			})
+
+			return
+		}
+
+		quotation := RequestQuotationResponse{
+			RequestId: result.Id,
+			Status:    Ready,
+			Rate:      &result.Rate,
+			UpdatedAt: result.UpdatedAt,
+		}
+
+		if detail {
+			quotation.Detail = toRateDetailDto(result.Detail)
+		}
+
+		response.Ok(w, log, quotation)
+	}
+}
+
+// toUpdateQuotationCommand ожидает провалидированный запрос
+func toUpdateQuotationCommand(request RequestQuotationUpdateBody) cmd.UpdateQuotation {
+	command := cmd.UpdateQuotation{
+		BaseCurrency:   request.BaseCurrency,
+		QuoteCurrency:  request.QuoteCurrency,
+		IdempotencyKey: request.IdempotencyKey,
+		CallbackUrl:    request.CallbackUrl,
+		CallbackSecret: request.CallbackSecret,
+	}
+
+	if request.AsOf != "" {
+		asOf, _ := time.Parse(AsOfLayout, request.AsOf)
+		command.AsOf = &asOf
+	}
+
+	return command
+}
+
+// @Summary Request quotation update for several pairs
+// @Description Creates quotation update requests for up to 100 pairs under one idempotency key, atomically. Returns batch Id, poll `GET /api/v1/quotation/update-request-batch/{id}` for per-pair status.
+// @Description Repeating the call with the same `idempotencyKey` returns the same batch, pairs of the repeated call are ignored.
+// @Tags Quotation
+// @Accept json
+// @Produce json
+// @Param request body RequestQuotationBatchBody true "Quotation batch request"
+// @Success 200 {object} RequestQuotationBatchResponse
+// @Failure 400 {object} response.ErrorResponse "Validation error"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /api/v1/quotation/update-request-batch [post]
+func requestQuotationBatchUpdate(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request RequestQuotationBatchBody
+
+		log = log.With(sl.TraceId(r.Context()))
+
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			response.Error(w, http.StatusBadRequest, err.Error(), log)
+
+			return
+		}
+
+		if err := validator.Struct(request); err != nil {
+			response.Error(w, http.StatusBadRequest, err.Error(), log)
+
+			return
+		}
+
+		command := cmd.UpdateQuotationBatch{IdempotencyKey: request.IdempotencyKey}
+
+		for _, pair := range request.Pairs {
+			command.Pairs = append(command.Pairs, [2]types.Currency{pair.BaseCurrency, pair.QuoteCurrency})
+		}
+
+		if request.AsOf != "" {
+			asOf, _ := time.Parse(AsOfLayout, request.AsOf)
+			command.AsOf = &asOf
+		}
+
+		result, err := command.Execute(r.Context(), log)
+
+		if err != nil {
+			switch {
+			case errors.Is(err, qr.ErrSameCurrency):
+				response.Error(w, http.StatusBadRequest, "Currencies can't be same", log)
+			case errors.Is(err, qr.ErrAsOfInFuture):
+				response.Error(w, http.StatusBadRequest, "AsOf can't be in the future", log)
+			case errors.Is(err, qb.ErrDuplicatePair):
+				response.Error(w, http.StatusBadRequest, "Pairs can't repeat", log)
+			case errors.Is(err, qb.ErrEmptyBatch):
+				response.Error(w, http.StatusBadRequest, "Pairs can't be empty", log)
+			default:
+				response.Error(w, http.StatusInternalServerError, "Something went wrong", log)
+			}
+
+			return
+		}
+
+		response.Ok(w, log, RequestQuotationBatchResponse{BatchId: result.Id})
+	}
+}
+
+// @Summary Get quotation batch by Id
+// @Description Retrieves per-pair status of a batch created with `POST /api/v1/quotation/update-request-batch`. Items are ordered by base and quote currency
+// @Tags Quotation
+// @Produce json
+// @Param id path string true "Batch ID"
+// @Success 200 {object} GetQuotationBatchResponse
+// @Failure 400 {object} response.ErrorResponse "Validation error"
+// @Failure 404 {object} response.ErrorResponse "No batch with such id"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /api/v1/quotation/update-request-batch/{id} [get]
+func getQuotationBatch(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+
+		log = log.With(sl.TraceId(r.Context()))
+
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "Invalid id format. Should be uuid", log)
+
+			return
+		}
+
+		query := qry.GetQuotationBatch{Id: id}
+
+		batch, err := query.Run(r.Context(), log)
+
+		if err != nil {
+			switch {
+			case errors.Is(err, qry.ErrNoBatchWithSuchId):
+				response.Error(w, http.StatusNotFound, "No batch with such id", log)
+			default:
+				response.Error(w, http.StatusInternalServerError, "Something went wrong", log)
+			}
+
+			return
+		}
+
+		result := GetQuotationBatchResponse{
+			BatchId:   batch.Id,
+			Status:    NotReady,
+			Completed: batch.Completed(),
+			Failed:    batch.Failed(),
+			Total:     len(batch.Requests),
+			Items:     make([]BatchItemDto, 0, len(batch.Requests)),
+		}
+
+		switch {
+		case result.Completed < result.Total:
+		case result.Failed == 0:
+			result.Status = Ready
+		case result.Failed == result.Total:
+			result.Status = Failed
+		default:
+			result.Status = PartiallyFailed
+		}
+
+		for _, request := range batch.Requests {
+			item := BatchItemDto{
+				RequestId:     request.Id,
+				BaseCurrency:  request.BaseCurrency,
+				QuoteCurrency: request.QuoteCurrency,
+				Status:        toRequestStatus(request.Status),
+			}
+
+			switch request.Status {
+			case qr.Failed, qr.Expired:
+				item.Error = toRequestErrorDto(request.LastError())
+			case qr.Ready:
+				item.Rate = request.Rate
+				item.UpdatedAt = request.CompletedAt.UnixMilli()
+
+				if request.Provider != nil {
+					item.Provider = *request.Provider
+				}
+			}
+
+			if request.AsOf != nil {
+				item.AsOf = request.AsOf.Format(AsOfLayout)
+			}
+
+			result.Items = append(result.Items, item)
+		}
+
+		response.Ok(w, log, result)
+	}
+}
+
 // @Summary Get quotation by request Id
-// @Description Retrieves a quotation by request Id. If request is not proceeded yet, returns status `NotReady`. If request is completed, returns status `Ready` and fields `rate` and `updatedAt`.
+// @Description Retrieves a quotation by request Id. If request is not proceeded yet, returns status `NotReady`. If request is completed, returns status `Ready` and fields `rate` and `updatedAt`. If providers failed on every attempt, returns status `Failed`, if request was not completed in time - `Expired`, both with field `error`. For historical requests `asOf` is returned as well and `updatedAt` is the date the rate was published for.
 // @Tags Quotation
 // @Produce json
 // @Param id path string true "Quotation ID"
+// @Param detail query boolean false "Include per-provider breakdown of aggregated rate"
 // @Success 200 {object} GetQuotationByRequestIdResponse
 // @Failure 400 {object} response.ErrorResponse "Validation error"
 // @Failure 404 {object} response.ErrorResponse "No request with such id"
@@ -114,6 +412,14 @@ func getQuotationByRequestId(log *slog.Logger) http.HandlerFunc {
 			return
 		}
 
+		detail, err := parseDetailFlag(r)
+
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "Invalid detail. Should be boolean", log)
+
+			return
+		}
+
 		query := qry.GetQuotationByRequestId{
 			Id: id,
 		}
@@ -133,7 +439,139 @@ func getQuotationByRequestId(log *slog.Logger) http.HandlerFunc {
 			return
 		}
 
-		response.Ok(w, log, GetQuotationByRequestIdResponse{Rate: result.Rate, Status: Ready, UpdatedAt: result.UpdatedAt})
+		if result.Status != qr.Ready {
+			response.Ok(w, log, GetQuotationByRequestIdResponse{
+				Status:   toRequestStatus(result.Status),
+				Attempts: result.Attempts,
+				Error:    toRequestErrorDto(result.Error),
+			})
+
+			return
+		}
+
+		quotation := GetQuotationByRequestIdResponse{
+			Rate:      &result.Rate,
+			Status:    Ready,
+			UpdatedAt: result.UpdatedAt,
+			Attempts:  result.Attempts,
+			Provider:  result.Provider,
+		}
+
+		if result.AsOf != nil {
+			quotation.AsOf = result.AsOf.Format(AsOfLayout)
+		}
+
+		if detail {
+			quotation.Detail = toRateDetailDto(result.Detail)
+		}
+
+		response.Ok(w, log, quotation)
+	}
+}
+
+// @Summary Get all cached quotations
+// @Description Returns every quotation that was requested at least once, like `GET /api/v1/quotation/last-requested` for each pair. Ordered by base and quote currency. `stale` marks rates older than server setting `QUOTATION_MAX_AGE`
+// @Tags Quotation
+// @Produce json
+// @Param base query string false "Only pairs with this base currency"
+// @Param quote query string false "Only pairs with this quote currency"
+// @Success 200 {object} GetSnapshotResponse
+// @Failure 400 {object} response.ErrorResponse "Validation error"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /api/v1/quotation/snapshot [get]
+func getSnapshot(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		base := types.Currency(r.URL.Query().Get("base"))
+		quote := types.Currency(r.URL.Query().Get("quote"))
+
+		log = log.With(sl.TraceId(r.Context()))
+
+		if base != "" && !base.IsValid() {
+			response.Error(w, http.StatusBadRequest, "Invalid base currency", log)
+
+			return
+		}
+
+		if quote != "" && !quote.IsValid() {
+			response.Error(w, http.StatusBadRequest, "Invalid quote currency", log)
+
+			return
+		}
+
+		query := qry.GetQuotationSnapshot{Base: base, Quote: quote, MaxAge: config.Instance.QuotationMaxAge}
+
+		entries := query.Run(r.Context(), log)
+		result := GetSnapshotResponse{Items: make([]SnapshotItemDto, 0, len(entries))}
+
+		for _, entry := range entries {
+			result.Items = append(result.Items, toSnapshotItemDto(entry))
+		}
+
+		response.Ok(w, log, result)
+	}
+}
+
+// @Summary Get cached quotations by list of pairs
+// @Description Returns cached quotations for up to 500 pairs. Pairs that were never requested are returned in `missing`
+// @Tags Quotation
+// @Accept json
+// @Produce json
+// @Param request body GetSnapshotBody true "Pairs"
+// @Success 200 {object} GetSnapshotByPairsResponse
+// @Failure 400 {object} response.ErrorResponse "Validation error"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /api/v1/quotation/snapshot [post]
+func getSnapshotByPairs(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request GetSnapshotBody
+
+		log = log.With(sl.TraceId(r.Context()))
+
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			response.Error(w, http.StatusBadRequest, err.Error(), log)
+
+			return
+		}
+
+		if err := validator.Struct(request); err != nil {
+			response.Error(w, http.StatusBadRequest, err.Error(), log)
+
+			return
+		}
+
+		query := qry.GetQuotations{MaxAge: config.Instance.QuotationMaxAge}
+
+		for _, pair := range request.Pairs {
+			query.Pairs = append(query.Pairs, [2]types.Currency{pair.BaseCurrency, pair.QuoteCurrency})
+		}
+
+		found := query.Run(r.Context(), log)
+
+		result := GetSnapshotByPairsResponse{
+			Found:   make([]SnapshotItemDto, 0, len(found.Found)),
+			Missing: make([]CurrencyPairDto, 0, len(found.Missing)),
+		}
+
+		for _, entry := range found.Found {
+			result.Found = append(result.Found, toSnapshotItemDto(entry))
+		}
+
+		for _, pair := range found.Missing {
+			result.Missing = append(result.Missing, CurrencyPairDto{BaseCurrency: pair[0], QuoteCurrency: pair[1]})
+		}
+
+		response.Ok(w, log, result)
+	}
+}
+
+func toSnapshotItemDto(entry qry.SnapshotEntry) SnapshotItemDto {
+	return SnapshotItemDto{
+		BaseCurrency:  entry.Base,
+		QuoteCurrency: entry.Quote,
+		Rate:          entry.Rate,
+		UpdatedAt:     entry.UpdatedAt.UnixMilli(),
+		Provider:      entry.Provider,
+		Stale:         entry.Stale,
 	}
 }
 
@@ -143,6 +581,7 @@ func getQuotationByRequestId(log *slog.Logger) http.HandlerFunc {
 // @Produce json
 // @Param base query string true "Base Currency"
 // @Param quote query string true "Quote Currency"
+// @Param detail query boolean false "Include per-provider breakdown of aggregated rate"
 // @Success 200 {object} GetQuotationResponse
 // @Failure 400 {object} response.ErrorResponse "Validation error"
 // @Failure 404 {object} response.ErrorResponse "Quotation not found"
@@ -167,12 +606,20 @@ func getQuotation(log *slog.Logger) http.HandlerFunc {
 			return
 		}
 
+		detail, err := parseDetailFlag(r)
+
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "Invalid detail. Should be boolean", log)
+
+			return
+		}
+
 		var query = qry.GetQuotation{
 			Base:  base,
 			Quote: quote,
 		}
 
-		var quotation, err = query.Run(r.Context(), log)
+		quotation, err := query.Run(r.Context(), log)
 
 		if err != nil {
 			switch {
@@ -187,6 +634,212 @@ func getQuotation(log *slog.Logger) http.HandlerFunc {
 			return
 		}
 
-		response.Ok(w, log, GetQuotationResponse{Rate: quotation.Rate, UpdatedAt: quotation.UpdatedAt.UnixMilli()})
+		result := GetQuotationResponse{Rate: quotation.Rate, UpdatedAt: quotation.UpdatedAt.UnixMilli()}
+
+		if detail {
+			result.Detail = toRateDetailDto(quotation.Detail)
+		}
+
+		response.Ok(w, log, result)
+	}
+}
+
+func parseDetailFlag(r *http.Request) (bool, error) {
+	raw := r.URL.Query().Get("detail")
+
+	if raw == "" {
+		return false, nil
+	}
+
+	return strconv.ParseBool(raw)
+}
+
+func toRateDetailDto(detail *types.RateDetail) *RateDetailDto {
+	if detail == nil {
+		return nil
+	}
+
+	sources := make([]RateSourceDto, 0, len(detail.Sources))
+
+	for _, source := range detail.Sources {
+		sources = append(sources, RateSourceDto{
+			Provider: source.Provider,
+			Rate:     source.Rate,
+			Accepted: source.Accepted,
+			Error:    source.Error,
+		})
+	}
+
+	result := &RateDetailDto{Confidence: detail.Confidence, Sources: sources}
+
+	if detail.Synthetic != nil {
+		legs := make([]RateLegDto, 0, len(detail.Synthetic.Legs))
+
+		for _, leg := range detail.Synthetic.Legs {
+			legs = append(legs, RateLegDto{
+				Base:      leg.Base,
+				Quote:     leg.Quote,
+				Rate:      leg.Rate,
+				UpdatedAt: leg.UpdatedAt.UnixMilli(),
+				Provider:  leg.Provider,
+				Inverted:  leg.Inverted,
+			})
+		}
+
+		result.Synthetic = &SyntheticRateDto{Pivot: detail.Synthetic.Pivot, Legs: legs}
+	}
+
+	return result
+}
+
+// toRequestStatus - Pending и InProgress для клиента неразличимы
+func toRequestStatus(status qr.Status) RequestStatus {
+	switch status {
+	case qr.Ready:
+		return Ready
+	case qr.Failed:
+		return Failed
+	case qr.Expired:
+		return Expired
+	default:
+		return NotReady
+	}
+}
+
+func toRequestErrorDto(failure *qr.Failure) *RequestErrorDto {
+	if failure == nil {
+		return nil
+	}
+
+	return &RequestErrorDto{Code: failure.Code, Message: failure.Message}
+}
+
+// @Summary Get quotation history
+// @Description Returns every rate fetched for base and quote currencies in `[from, to)`. Without `interval` returns raw points, with `interval` returns OHLC buckets aligned to UTC. Empty buckets are skipped
+// @Tags Quotation
+// @Produce json
+// @Param base query string true "Base Currency"
+// @Param quote query string true "Quote Currency"
+// @Param from query string false "RFC 3339 timestamp, defaults to `to` minus 24 hours"
+// @Param to query string false "RFC 3339 timestamp, defaults to now"
+// @Param interval query string false "Bucket size as Go duration, e.g. `15m`, `1h`, `24h`"
+// @Success 200 {object} GetQuotationHistoryResponse
+// @Failure 400 {object} response.ErrorResponse "Validation error"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /api/v1/quotation/history [get]
+func getQuotationHistory(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+
+		base := types.Currency(params.Get("base"))
+		quote := types.Currency(params.Get("quote"))
+
+		log = log.With(sl.TraceId(r.Context()))
+
+		if !base.IsValid() {
+			response.Error(w, http.StatusBadRequest, "Invalid base currency", log)
+
+			return
+		}
+
+		if !quote.IsValid() {
+			response.Error(w, http.StatusBadRequest, "Invalid quote currency", log)
+
+			return
+		}
+
+		to := time.Now()
+
+		if rawTo := params.Get("to"); rawTo != "" {
+			parsed, err := time.Parse(time.RFC3339, rawTo)
+
+			if err != nil {
+				response.Error(w, http.StatusBadRequest, "Invalid to. Should be RFC 3339 timestamp", log)
+
+				return
+			}
+
+			to = parsed
+		}
+
+		from := to.Add(-24 * time.Hour)
+
+		if rawFrom := params.Get("from"); rawFrom != "" {
+			parsed, err := time.Parse(time.RFC3339, rawFrom)
+
+			if err != nil {
+				response.Error(w, http.StatusBadRequest, "Invalid from. Should be RFC 3339 timestamp", log)
+
+				return
+			}
+
+			from = parsed
+		}
+
+		var interval time.Duration
+
+		if rawInterval := params.Get("interval"); rawInterval != "" {
+			parsed, err := time.ParseDuration(rawInterval)
+
+			if err != nil || parsed < time.Second {
+				response.Error(w, http.StatusBadRequest, "Invalid interval. Should be duration not less than 1s, e.g. 15m", log)
+
+				return
+			}
+
+			interval = parsed
+		}
+
+		query := qry.GetQuotationHistory{
+			Base:     base,
+			Quote:    quote,
+			From:     from,
+			To:       to,
+			Interval: interval,
+		}
+
+		result, err := query.Run(r.Context(), log)
+
+		if err != nil {
+			switch {
+			case errors.Is(err, qr.ErrSameCurrency):
+				response.Error(w, http.StatusBadRequest, "Currencies can't be same", log)
+			case errors.Is(err, qry.ErrInvalidHistoryRange):
+				response.Error(w, http.StatusBadRequest, "From should be before to", log)
+			case errors.Is(err, qry.ErrHistoryRangeTooLarge):
+				response.Error(w, http.StatusBadRequest, "Too many records in range, narrow it down", log)
+			default:
+				response.Error(w, http.StatusInternalServerError, "Something went wrong", log)
+			}
+
+			return
+		}
+
+		if interval == 0 {
+			points := make([]HistoryPointDto, 0, len(result.Points))
+
+			for _, point := range result.Points {
+				points = append(points, HistoryPointDto{Rate: point.Rate, Timestamp: point.Timestamp.UnixMilli()})
+			}
+
+			response.Ok(w, log, GetQuotationHistoryResponse{Points: points})
+
+			return
+		}
+
+		buckets := make([]HistoryBucketDto, 0, len(result.Buckets))
+
+		for _, bucket := range result.Buckets {
+			buckets = append(buckets, HistoryBucketDto{
+				Start: bucket.Start.UnixMilli(),
+				Open:  bucket.Open,
+				High:  bucket.High,
+				Low:   bucket.Low,
+				Close: bucket.Close,
+				Count: bucket.Count,
+			})
+		}
+
+		response.Ok(w, log, GetQuotationHistoryResponse{Buckets: buckets})
 	}
 }
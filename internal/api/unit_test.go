@@ -0,0 +1,125 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	qb "plata_currency_quotation/internal/domain/enity/quotation-batch"
+	qr "plata_currency_quotation/internal/domain/enity/quotation-request"
+	"plata_currency_quotation/internal/domain/types"
+	"plata_currency_quotation/internal/lib/config"
+	"plata_currency_quotation/internal/lib/env"
+	"plata_currency_quotation/internal/lib/http-server/middleware/trace-id"
+	"plata_currency_quotation/internal/persistence"
+	"plata_currency_quotation/internal/persistence/inmemory"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// chi собирает дерево при регистрации, конфликт префиксов паникует сразу, а не на запросе
+func Test_RegisterRoutes(t *testing.T) {
+	config.Instance = &config.Config{Env: env.Local}
+
+	router := chi.NewRouter()
+
+	assert.NotPanics(t, func() {
+		RegisterRoutes(router, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	})
+
+	routes := make(map[string]bool)
+
+	err := chi.Walk(router, func(method string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routes[method+" "+route] = true
+
+		return nil
+	})
+	assert.NoError(t, err)
+
+	for _, route := range []string{
+		"POST /api/v1/quotation",
+		"POST /api/v1/quotation/update-request",
+		"GET /api/v1/quotation/update-request/{id}",
+		"GET /api/v1/quotation/last-requested",
+		"GET /api/v1/currency/list",
+		"GET /api/v1/convert",
+		"POST /api/v1/convert",
+	} {
+		assert.True(t, routes[route], route)
+	}
+}
+
+func Test_GetQuotationBatchStatus(t *testing.T) {
+	config.Instance = &config.Config{Env: env.Local}
+	persistence.Instance = inmemory.New()
+
+	router := chi.NewRouter()
+	router.Use(trace_id.New())
+	RegisterRoutes(router, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	pairs := [][2]types.Currency{{types.USD, types.EUR}, {types.USD, types.MXN}, {types.EUR, types.MXN}}
+
+	request := func(status qr.Status, pair [2]types.Currency) qr.QuotationRequest {
+		request, err := qr.New(pair[0], pair[1], uuid.New())
+		assert.NoError(t, err)
+
+		request.Status = status
+
+		if status == qr.Ready {
+			rate, completedAt := types.MustParseDecimal("1.25"), time.Now()
+			request.Rate, request.CompletedAt = &rate, &completedAt
+		}
+
+		return request
+	}
+
+	getStatus := func(statuses ...qr.Status) (string, int) {
+		batch := qb.New(uuid.New())
+
+		for i, status := range statuses {
+			assert.NoError(t, batch.Add(request(status, pairs[i])))
+		}
+
+		assert.NoError(t, persistence.Instance.QuotationBatchCreateOrGetByIdempotencyKey(&batch))
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/quotation/update-request-batch/"+batch.Id.String(), nil))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		var body struct {
+			Status string `json:"status"`
+			Failed int    `json:"failed"`
+		}
+
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+
+		return body.Status, body.Failed
+	}
+
+	tests := []struct {
+		name     string
+		statuses []qr.Status
+		status   string
+		failed   int
+	}{
+		{"in progress", []qr.Status{qr.Ready, qr.Pending, qr.Failed}, "NotReady", 1},
+		{"all ready", []qr.Status{qr.Ready, qr.Ready}, "Ready", 0},
+		{"partially failed", []qr.Status{qr.Ready, qr.Failed, qr.Expired}, "PartiallyFailed", 2},
+		{"all failed", []qr.Status{qr.Failed, qr.Expired}, "Failed", 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, failed := getStatus(test.statuses...)
+
+			assert.Equal(t, test.status, status)
+			assert.Equal(t, test.failed, failed)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"plata_currency_quotation/internal/lib/logger/sl"
+	"sync"
+	"time"
+)
+
+// App - жизненный цикл процесса: http серверы и фоновые сервисы. При остановке сначала серверы дожидаются
+// начатых запросов, потом фоновые сервисы останавливаются в обратном порядке регистрации. На все вместе - grace
+type App struct {
+	grace    time.Duration
+	servers  []server
+	stoppers []stopper
+	logger   *slog.Logger
+}
+
+type server struct {
+	name     string
+	server   *http.Server
+	listener net.Listener
+}
+
+type stopper struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+func New(grace time.Duration) *App {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})).With(
+		"component", "app",
+	)
+
+	return &App{grace: grace, logger: logger}
+}
+
+// AddServer - listener открывается заранее, чтобы занятый порт был ошибкой запуска, а не остановки
+func (a *App) AddServer(name string, srv *http.Server, listener net.Listener) {
+	a.servers = append(a.servers, server{name: name, server: srv, listener: listener})
+}
+
+// OnStop - stop должен вернуться по отмене ctx, даже если не успел закончить
+func (a *App) OnStop(name string, stop func(ctx context.Context) error) {
+	a.stoppers = append(a.stoppers, stopper{name: name, stop: stop})
+}
+
+// Blocking - для Stop, которые ждут до конца: по отмене ctx перестаем ждать, сама остановка продолжается в фоне
+func Blocking(stop func()) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		done := make(chan struct{})
+
+		go func() {
+			stop()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Run обслуживает серверы до отмены ctx или падения одного из них, затем останавливает все
+func (a *App) Run(ctx context.Context) error {
+	failed := make(chan error, len(a.servers))
+
+	for _, s := range a.servers {
+		a.logger.Info("server is listening", slog.String("server", s.name), slog.String("address", s.listener.Addr().String()))
+
+		go func() {
+			if err := s.server.Serve(s.listener); !errors.Is(err, http.ErrServerClosed) {
+				failed <- fmt.Errorf("%s server: %w", s.name, err)
+			}
+		}()
+	}
+
+	var runErr error
+
+	select {
+	case <-ctx.Done():
+		a.logger.Info("shutting down", slog.String("grace", a.grace.String()))
+	case runErr = <-failed:
+		a.logger.Error("server failed, shutting down", sl.Err(runErr))
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.grace)
+	defer cancel()
+
+	return errors.Join(runErr, a.shutdown(shutdownCtx))
+}
+
+func (a *App) shutdown(ctx context.Context) error {
+	var errs []error
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, s := range a.servers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if err := s.server.Shutdown(ctx); err != nil {
+				mutex.Lock()
+				errs = append(errs, fmt.Errorf("failed to shutdown %s server: %w", s.name, err))
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	for i := len(a.stoppers) - 1; i >= 0; i-- {
+		s := a.stoppers[i]
+
+		if err := s.stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop %s: %w", s.name, err))
+
+			continue
+		}
+
+		a.logger.Info("stopped", slog.String("service", s.name))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,163 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"plata_currency_quotation/internal/api"
+	"plata_currency_quotation/internal/api/quotation"
+	"plata_currency_quotation/internal/domain/types"
+	"plata_currency_quotation/internal/lib/config"
+	"plata_currency_quotation/internal/lib/env"
+	"plata_currency_quotation/internal/lib/http-server/middleware/trace-id"
+	"plata_currency_quotation/internal/lib/validator"
+	"plata_currency_quotation/internal/persistence"
+	"plata_currency_quotation/internal/persistence/inmemory"
+	cc "plata_currency_quotation/internal/service/currency-conversion"
+	qm "plata_currency_quotation/internal/service/quotation-manager"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingProvider держит поход за курсами, пока не закрыт release
+type blockingProvider struct {
+	cc.Interface
+	entered chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (b *blockingProvider) GetLatestRates(ctx context.Context, base types.Currency, quotes []types.Currency) ([]cc.CurrencyRate, error) {
+	b.once.Do(func() { close(b.entered) })
+	<-b.release
+
+	return b.Interface.GetLatestRates(ctx, base, quotes)
+}
+
+func Test_GracefulShutdown(t *testing.T) {
+	config.Instance = &config.Config{Env: env.Prod, MaxQuotationWait: 5 * time.Second, QuotationMaxAge: time.Hour}
+	validator.RegisterValidators()
+
+	db := inmemory.New()
+	provider := &blockingProvider{Interface: cc.NewMock(), entered: make(chan struct{}), release: make(chan struct{})}
+
+	persistence.Instance = db
+	cc.Instance = provider
+	qm.Instance = qm.New(time.Duration(10)*time.Millisecond, db, provider)
+	qm.Instance.Start(context.Background())
+
+	var mutex sync.Mutex
+	var stopped []string
+
+	record := func(name string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		stopped = append(stopped, name)
+	}
+
+	router := chi.NewRouter()
+	router.Use(trace_id.New())
+	api.RegisterRoutes(router, slog.New(slog.DiscardHandler))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	application := New(5 * time.Second)
+	application.AddServer("http", &http.Server{Handler: router}, listener)
+	application.OnStop("persistence", func(_ context.Context) error {
+		record("persistence")
+
+		return db.Close()
+	})
+	application.OnStop("quotation manager", Blocking(func() {
+		qm.Instance.Stop()
+		record("quotation manager")
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := make(chan error, 1)
+
+	go func() {
+		finished <- application.Run(ctx)
+	}()
+
+	type result struct {
+		status int
+		body   quotation.RequestQuotationResponse
+		err    error
+	}
+
+	responded := make(chan result, 1)
+
+	go func() {
+		body, _ := json.Marshal(quotation.RequestQuotationUpdateBody{
+			BaseCurrency:   types.USD,
+			QuoteCurrency:  types.MXN,
+			IdempotencyKey: uuid.New(),
+		})
+
+		resp, err := http.Post("http://"+listener.Addr().String()+"/api/v1/quotation", "application/json", bytes.NewReader(body))
+
+		if err != nil {
+			responded <- result{err: err}
+
+			return
+		}
+
+		defer resp.Body.Close()
+
+		var decoded quotation.RequestQuotationResponse
+		err = json.NewDecoder(resp.Body).Decode(&decoded)
+
+		responded <- result{status: resp.StatusCode, body: decoded, err: err}
+	}()
+
+	// запрос принят и менеджер уже ходит к провайдеру - в этот момент приходит SIGTERM
+	select {
+	case <-provider.entered:
+	case served := <-responded:
+		t.Fatalf("request was answered before reaching provider: %d %v", served.status, served.err)
+	}
+
+	cancel()
+
+	select {
+	case <-finished:
+		t.Fatal("app stopped before in-flight request was served")
+	case <-responded:
+		t.Fatal("request was answered before provider returned")
+	default:
+	}
+
+	close(provider.release)
+
+	served := <-responded
+
+	assert.NoError(t, served.err)
+	assert.Equal(t, http.StatusOK, served.status)
+	assert.Equal(t, quotation.Ready, served.body.Status)
+
+	assert.NoError(t, <-finished)
+
+	stored, err := db.QuotationRequestGetById(served.body.RequestId)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, stored.CompletedAt)
+
+	// бд закрывается только после того, как менеджер дописал результат
+	assert.Equal(t, []string{"quotation manager", "persistence"}, stopped)
+
+	// новые соединения после остановки не принимаются
+	_, err = net.Dial("tcp", listener.Addr().String())
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,10 @@
+package lease
+
+import "time"
+
+// Lease - аренда роли. Держатель продлевает ее раньше ExpiresAt, иначе роль может захватить другой экземпляр
+type Lease struct {
+	Name      string    `gorm:"type:varchar(64);primaryKey"`
+	Holder    string    `gorm:"type:varchar(128);not null"`
+	ExpiresAt time.Time `gorm:"type:timestamp;not null"`
+}
@@ -0,0 +1,77 @@
+package quotation_batch
+
+import (
+	"errors"
+	qr "plata_currency_quotation/internal/domain/enity/quotation-request"
+	"plata_currency_quotation/internal/domain/types"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrEmptyBatch = errors.New("batch must contain at least one pair")
+
+var ErrDuplicatePair = errors.New("batch contains duplicate pair")
+
+// QuotationBatch - несколько запросов котировок под одним ключом идемпотентности.
+// Пачка и все ее запросы создаются в одной транзакции
+type QuotationBatch struct {
+	Id             uuid.UUID             `gorm:"type:uuid;primaryKey"`
+	IdempotencyKey uuid.UUID             `gorm:"type:uuid;uniqueIndex;not null"`
+	CreatedAt      time.Time             `gorm:"type:timestamp;not null"`
+	Requests       []qr.QuotationRequest `gorm:"foreignKey:BatchId"`
+}
+
+func New(idempotencyKey uuid.UUID) QuotationBatch {
+	return QuotationBatch{
+		Id:             uuid.New(),
+		IdempotencyKey: idempotencyKey,
+		CreatedAt:      time.Now(),
+	}
+}
+
+// RequestIdempotencyKey - ключ запроса пары внутри пачки. Выводится из ключа пачки, поэтому повтор пачки
+// дает те же ключи и не пересекается с ключами одиночных запросов
+func RequestIdempotencyKey(batchKey uuid.UUID, base types.Currency, quote types.Currency) uuid.UUID {
+	return uuid.NewSHA1(batchKey, []byte(base+"/"+quote))
+}
+
+func (b *QuotationBatch) Add(request qr.QuotationRequest) error {
+	for _, existing := range b.Requests {
+		if existing.BaseCurrency == request.BaseCurrency && existing.QuoteCurrency == request.QuoteCurrency {
+			return ErrDuplicatePair
+		}
+	}
+
+	id := b.Id
+	request.BatchId = &id
+	b.Requests = append(b.Requests, request)
+
+	return nil
+}
+
+// Completed - сколько запросов пачки уже в конечном статусе, включая Failed и Expired
+func (b *QuotationBatch) Completed() int {
+	completed := 0
+
+	for _, request := range b.Requests {
+		if request.Status.IsFinished() {
+			completed++
+		}
+	}
+
+	return completed
+}
+
+// Failed - сколько запросов пачки закончились без курса: Failed или Expired
+func (b *QuotationBatch) Failed() int {
+	failed := 0
+
+	for _, request := range b.Requests {
+		if request.Status == qr.Failed || request.Status == qr.Expired {
+			failed++
+		}
+	}
+
+	return failed
+}
@@ -0,0 +1,32 @@
+package quotation_history
+
+import (
+	"plata_currency_quotation/internal/domain/types"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type QuotationHistory struct {
+	Id            uuid.UUID      `gorm:"type:uuid;primaryKey"`
+	BaseCurrency  types.Currency `gorm:"type:varchar(3);not null;index:idx_quotation_history_pair_time,priority:1"`
+	QuoteCurrency types.Currency `gorm:"type:varchar(3);not null;index:idx_quotation_history_pair_time,priority:2"`
+	Rate          types.Decimal  `gorm:"type:numeric;not null"`
+	FetchedAt     time.Time      `gorm:"type:timestamp;not null;index:idx_quotation_history_pair_time,priority:3"`
+	Provider      string         `gorm:"type:varchar(32);not null;default:''"`
+}
+
+func (QuotationHistory) TableName() string {
+	return "quotation_history"
+}
+
+func New(baseCurrency types.Currency, quoteCurrency types.Currency, quotation types.QuotationInfo) QuotationHistory {
+	return QuotationHistory{
+		Id:            uuid.New(),
+		BaseCurrency:  baseCurrency,
+		QuoteCurrency: quoteCurrency,
+		Rate:          quotation.Rate,
+		FetchedAt:     quotation.UpdatedAt,
+		Provider:      quotation.Provider,
+	}
+}
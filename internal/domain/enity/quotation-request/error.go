@@ -3,3 +3,5 @@ package quotation_request
 import "errors"
 
 var ErrSameCurrency = errors.New("base and quote currency cannot be the same")
+
+var ErrAsOfInFuture = errors.New("as of date cannot be in the future")
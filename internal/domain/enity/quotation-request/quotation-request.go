@@ -7,14 +7,34 @@ import (
 	"github.com/google/uuid"
 )
 
+// QuotationRequest - запрос в конечном статусе не меняется. Частичный индекс idx_quotation_requests_open
+// (base, quote, created_at) содержит только Pending и InProgress запросы последнего курса
 type QuotationRequest struct {
 	Id             uuid.UUID      `gorm:"type:uuid;primaryKey"`
 	IdempotencyKey uuid.UUID      `gorm:"type:uuid;uniqueIndex;not null"`
-	CreatedAt      time.Time      `gorm:"type:timestamp;not null"`
-	BaseCurrency   types.Currency `gorm:"type:varchar(3);not null"`
-	QuoteCurrency  types.Currency `gorm:"type:varchar(3);not null"`
+	CreatedAt      time.Time      `gorm:"type:timestamp;not null;index:idx_quotation_requests_open,priority:3"`
+	BaseCurrency   types.Currency `gorm:"type:varchar(3);not null;index:idx_quotation_requests_open,priority:1,where:as_of is null and (status = 'Pending' or status = 'InProgress')"`
+	QuoteCurrency  types.Currency `gorm:"type:varchar(3);not null;index:idx_quotation_requests_open,priority:2"`
 	CompletedAt    *time.Time     `gorm:"type:timestamp"`
-	Rate           *string        `gorm:"type:text"`
+	Rate           *types.Decimal `gorm:"type:numeric"`
+	CallbackUrl    *string        `gorm:"type:text"`
+	CallbackSecret *string        `gorm:"type:text"`
+	// AsOf - дата для исторического курса, nil - последний курс. Завершенные исторические запросы не меняются
+	AsOf *time.Time `gorm:"type:date"`
+	// Provider - какой провайдер отдал курс
+	Provider *string `gorm:"type:varchar(32)"`
+	// Detail - разбивка по источникам при агрегации нескольких провайдеров
+	Detail *types.RateDetail `gorm:"type:jsonb"`
+	// BatchId - пачка, в составе которой создан запрос, nil для одиночных
+	BatchId *uuid.UUID `gorm:"type:uuid;index"`
+	Status  Status     `gorm:"type:varchar(16);not null;default:'Pending'"`
+	// Attempts - сколько раз ходили к провайдеру за курсом
+	Attempts int `gorm:"not null;default:0"`
+	// AttemptStartedAt - когда запрос вернули в Pending вручную, ttl отсчитывается от него. nil - от CreatedAt
+	AttemptStartedAt *time.Time `gorm:"type:timestamp"`
+	// ErrorCode, ErrorMessage - последняя неудачная попытка или причина Expired
+	ErrorCode    *string `gorm:"type:varchar(32)"`
+	ErrorMessage *string `gorm:"type:text"`
 }
 
 func New(baseCurrency types.Currency, quoteCurrency types.Currency, idempotencyKey uuid.UUID) (QuotationRequest, error) {
@@ -28,7 +48,26 @@ func New(baseCurrency types.Currency, quoteCurrency types.Currency, idempotencyK
 		CreatedAt:      time.Now(),
 		BaseCurrency:   baseCurrency,
 		QuoteCurrency:  quoteCurrency,
+		Status:         Pending,
 		CompletedAt:    nil,
 		Rate:           nil,
 	}, nil
 }
+
+func NewHistorical(baseCurrency types.Currency, quoteCurrency types.Currency, idempotencyKey uuid.UUID, asOf time.Time) (QuotationRequest, error) {
+	request, err := New(baseCurrency, quoteCurrency, idempotencyKey)
+
+	if err != nil {
+		return QuotationRequest{}, err
+	}
+
+	date := time.Date(asOf.Year(), asOf.Month(), asOf.Day(), 0, 0, 0, 0, time.UTC)
+
+	if date.After(time.Now().UTC()) {
+		return QuotationRequest{}, ErrAsOfInFuture
+	}
+
+	request.AsOf = &date
+
+	return request, nil
+}
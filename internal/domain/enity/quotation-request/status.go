@@ -0,0 +1,59 @@
+package quotation_request
+
+import "time"
+
+// Status - Pending -> InProgress -> Ready. Неудачная попытка возвращает запрос в Pending, после последней
+// попытки - Failed. Незавершенный запрос старше ttl становится Expired. Ready, Failed и Expired не меняются
+type Status string
+
+const (
+	Pending    Status = "Pending"
+	InProgress Status = "InProgress"
+	Ready      Status = "Ready"
+	Failed     Status = "Failed"
+	Expired    Status = "Expired"
+)
+
+// Коды последней ошибки запроса
+const (
+	ErrorRateNotFound        = "rate_not_found"
+	ErrorProviderTimeout     = "provider_timeout"
+	ErrorProviderUnavailable = "provider_unavailable"
+	ErrorProvider            = "provider_error"
+	ErrorExpired             = "expired"
+)
+
+// Failure - почему не удалась последняя попытка
+type Failure struct {
+	Code    string
+	Message string
+}
+
+// IsFinished - запрос больше не обрабатывается
+func (s Status) IsFinished() bool {
+	return s == Ready || s == Failed || s == Expired
+}
+
+// LastError - nil, если попыток с ошибкой не было
+func (r *QuotationRequest) LastError() *Failure {
+	if r.ErrorCode == nil {
+		return nil
+	}
+
+	failure := Failure{Code: *r.ErrorCode}
+
+	if r.ErrorMessage != nil {
+		failure.Message = *r.ErrorMessage
+	}
+
+	return &failure
+}
+
+// TtlStartedAt - с какого момента отсчитывается ttl незавершенного запроса
+func (r *QuotationRequest) TtlStartedAt() time.Time {
+	if r.AttemptStartedAt != nil {
+		return *r.AttemptStartedAt
+	}
+
+	return r.CreatedAt
+}
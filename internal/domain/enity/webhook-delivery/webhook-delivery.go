@@ -0,0 +1,62 @@
+package webhook_delivery
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Status string
+
+const (
+	Pending    Status = "Pending"
+	Delivered  Status = "Delivered"
+	DeadLetter Status = "DeadLetter"
+)
+
+type WebhookDelivery struct {
+	Id            uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	RequestId     uuid.UUID  `gorm:"type:uuid;index;not null"`
+	Url           string     `gorm:"type:text;not null"`
+	Secret        string     `gorm:"type:text;not null"`
+	Status        Status     `gorm:"type:varchar(16);index:idx_webhook_deliveries_due,priority:1;not null"`
+	Attempts      int        `gorm:"not null;default:0"`
+	NextAttemptAt time.Time  `gorm:"type:timestamp;index:idx_webhook_deliveries_due,priority:2;not null"`
+	LastError     *string    `gorm:"type:text"`
+	CreatedAt     time.Time  `gorm:"type:timestamp;not null"`
+	DeliveredAt   *time.Time `gorm:"type:timestamp"`
+}
+
+func New(requestId uuid.UUID, url string, secret string, now time.Time) WebhookDelivery {
+	return WebhookDelivery{
+		Id:            uuid.New(),
+		RequestId:     requestId,
+		Url:           url,
+		Secret:        secret,
+		Status:        Pending,
+		Attempts:      0,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+}
+
+func (d *WebhookDelivery) MarkDelivered(now time.Time) {
+	d.Status = Delivered
+	d.Attempts++
+	d.LastError = nil
+	d.DeliveredAt = &now
+}
+
+// MarkFailed планирует следующую попытку, либо переводит в DeadLetter, если попытки закончились
+func (d *WebhookDelivery) MarkFailed(reason string, now time.Time, maxAttempts int, backoff time.Duration) {
+	d.Attempts++
+	d.LastError = &reason
+
+	if d.Attempts >= maxAttempts {
+		d.Status = DeadLetter
+
+		return
+	}
+
+	d.NextAttemptAt = now.Add(backoff)
+}
@@ -0,0 +1,198 @@
+package types
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//go:embed iso4217.csv
+var iso4217Csv string
+
+var (
+	ErrUnknownCurrency   = errors.New("unknown currency code")
+	ErrWithdrawnCurrency = errors.New("currency is withdrawn")
+)
+
+// MinorUnitsNotApplicable - для кодов без разменной единицы (XAU, XDR и т.д.), в ISO 4217 это "N.A."
+const MinorUnitsNotApplicable = -1
+
+type CurrencyInfo struct {
+	Code        Currency
+	NumericCode string
+	MinorUnits  int
+	Name        string
+	Active      bool
+}
+
+var catalogue = mustParseCatalogue(iso4217Csv)
+
+// Registry - валюты, включенные для сервиса. По умолчанию USD, EUR, MXN, в main перезаписывается из конфига
+var Registry = mustNewCurrencyRegistry(USD, EUR, MXN)
+
+type CurrencyRegistry struct {
+	enabled map[Currency]CurrencyInfo
+	ordered []CurrencyInfo
+}
+
+func NewCurrencyRegistry(codes ...Currency) (*CurrencyRegistry, error) {
+	registry := CurrencyRegistry{
+		enabled: make(map[Currency]CurrencyInfo, len(codes)),
+		ordered: make([]CurrencyInfo, 0, len(codes)),
+	}
+
+	for _, code := range codes {
+		code = Currency(strings.ToUpper(strings.TrimSpace(string(code))))
+
+		info, exists := catalogue.byCode[code]
+
+		if !exists {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownCurrency, code)
+		}
+
+		if !info.Active {
+			return nil, fmt.Errorf("%w: %s", ErrWithdrawnCurrency, code)
+		}
+
+		if _, duplicate := registry.enabled[code]; duplicate {
+			continue
+		}
+
+		registry.enabled[code] = info
+		registry.ordered = append(registry.ordered, info)
+	}
+
+	return &registry, nil
+}
+
+func mustNewCurrencyRegistry(codes ...Currency) *CurrencyRegistry {
+	registry, err := NewCurrencyRegistry(codes...)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return registry
+}
+
+func (r *CurrencyRegistry) IsEnabled(c Currency) bool {
+	_, exists := r.enabled[c]
+
+	return exists
+}
+
+func (r *CurrencyRegistry) Get(c Currency) (CurrencyInfo, bool) {
+	info, exists := r.enabled[c]
+
+	return info, exists
+}
+
+func (r *CurrencyRegistry) Enabled() []CurrencyInfo {
+	result := make([]CurrencyInfo, len(r.ordered))
+	copy(result, r.ordered)
+
+	return result
+}
+
+func (r *CurrencyRegistry) Codes() []Currency {
+	result := make([]Currency, 0, len(r.ordered))
+
+	for _, info := range r.ordered {
+		result = append(result, info.Code)
+	}
+
+	return result
+}
+
+// LookupCurrency ищет по всему справочнику ISO 4217, включая выключенные и выведенные из оборота валюты
+func LookupCurrency(c Currency) (CurrencyInfo, bool) {
+	info, exists := catalogue.byCode[c]
+
+	return info, exists
+}
+
+func Catalogue() []CurrencyInfo {
+	result := make([]CurrencyInfo, len(catalogue.ordered))
+	copy(result, catalogue.ordered)
+
+	return result
+}
+
+type currencyCatalogue struct {
+	byCode  map[Currency]CurrencyInfo
+	ordered []CurrencyInfo
+}
+
+func mustParseCatalogue(data string) currencyCatalogue {
+	records, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+
+	if err != nil {
+		panic(fmt.Errorf("failed to parse ISO 4217 table: %w", err))
+	}
+
+	result := currencyCatalogue{
+		byCode:  make(map[Currency]CurrencyInfo, len(records)),
+		ordered: make([]CurrencyInfo, 0, len(records)),
+	}
+
+	// первая строка - заголовок
+	for i, record := range records[1:] {
+		info, err := parseCatalogueRecord(record)
+
+		if err != nil {
+			panic(fmt.Errorf("failed to parse ISO 4217 table, line %d: %w", i+2, err))
+		}
+
+		if _, duplicate := result.byCode[info.Code]; duplicate {
+			panic(fmt.Errorf("failed to parse ISO 4217 table, line %d: duplicate code %s", i+2, info.Code))
+		}
+
+		result.byCode[info.Code] = info
+		result.ordered = append(result.ordered, info)
+	}
+
+	return result
+}
+
+func parseCatalogueRecord(record []string) (CurrencyInfo, error) {
+	if len(record) != 5 {
+		return CurrencyInfo{}, fmt.Errorf("expected 5 columns, got %d", len(record))
+	}
+
+	code, numeric, minorUnits, name, status := record[0], record[1], record[2], record[3], record[4]
+
+	if len(code) != 3 || len(numeric) != 3 {
+		return CurrencyInfo{}, fmt.Errorf("invalid code %q/%q", code, numeric)
+	}
+
+	info := CurrencyInfo{
+		Code:        Currency(code),
+		NumericCode: numeric,
+		MinorUnits:  MinorUnitsNotApplicable,
+		Name:        name,
+	}
+
+	if minorUnits != "" {
+		units, err := strconv.Atoi(minorUnits)
+
+		if err != nil {
+			return CurrencyInfo{}, fmt.Errorf("invalid minor units %q: %w", minorUnits, err)
+		}
+
+		info.MinorUnits = units
+	}
+
+	switch status {
+	case "active":
+		info.Active = true
+	case "withdrawn":
+		info.Active = false
+	default:
+		return CurrencyInfo{}, fmt.Errorf("invalid status %q", status)
+	}
+
+	return info, nil
+}
@@ -10,18 +10,12 @@ const (
 	MXN Currency = "MXN"
 )
 
-func AllCurrencies() []Currency {
-	return []Currency{USD, EUR, MXN}
-}
-
 func (c Currency) IsValid() bool {
-	switch c {
-	case USD, EUR, MXN:
-		return true
+	return Registry.IsEnabled(c)
+}
 
-	default:
-		return false
-	}
+func (c Currency) Info() (CurrencyInfo, bool) {
+	return LookupCurrency(c)
 }
 
 func (c Currency) MarshalJSON() ([]byte, error) {
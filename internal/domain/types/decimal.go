@@ -0,0 +1,139 @@
+package types
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrInvalidDecimal = errors.New("invalid decimal")
+
+// Decimal - точное десятичное число для курсов. Масштаб (число знаков после запятой) сохраняется:
+// "20.10" остается "20.10", экспоненциальная запись на выходе не используется
+type Decimal struct {
+	value decimal.Decimal
+}
+
+func ParseDecimal(value string) (Decimal, error) {
+	parsed, err := decimal.NewFromString(value)
+
+	if err != nil {
+		return Decimal{}, fmt.Errorf("%w %q", ErrInvalidDecimal, value)
+	}
+
+	return Decimal{value: parsed}, nil
+}
+
+func MustParseDecimal(value string) Decimal {
+	parsed, err := ParseDecimal(value)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return parsed
+}
+
+func NewDecimalFromInt(value int64) Decimal {
+	return Decimal{value: decimal.NewFromInt(value)}
+}
+
+func (d Decimal) String() string {
+	return d.value.StringFixed(d.Scale())
+}
+
+// Scale - число знаков после запятой
+func (d Decimal) Scale() int32 {
+	return max(-d.value.Exponent(), 0)
+}
+
+func (d Decimal) IsZero() bool {
+	return d.value.IsZero()
+}
+
+func (d Decimal) Sign() int {
+	return d.value.Sign()
+}
+
+func (d Decimal) Cmp(other Decimal) int {
+	return d.value.Cmp(other.value)
+}
+
+func (d Decimal) Equal(other Decimal) bool {
+	return d.value.Equal(other.value)
+}
+
+func (d Decimal) Add(other Decimal) Decimal {
+	return Decimal{value: d.value.Add(other.value)}
+}
+
+func (d Decimal) Sub(other Decimal) Decimal {
+	return Decimal{value: d.value.Sub(other.value)}
+}
+
+func (d Decimal) Mul(other Decimal) Decimal {
+	return Decimal{value: d.value.Mul(other.value)}
+}
+
+// Div делит с округлением half up до scale знаков
+func (d Decimal) Div(other Decimal, scale int32) Decimal {
+	return Decimal{value: d.value.DivRound(other.value, scale)}
+}
+
+func (d Decimal) Abs() Decimal {
+	return Decimal{value: d.value.Abs()}
+}
+
+func (d Decimal) Float64() float64 {
+	value, _ := d.value.Float64()
+
+	return value
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + d.String() + `"`), nil
+}
+
+// UnmarshalJSON принимает как строку, так и число. Число разбирается из исходного текста, без float64
+// UnmarshalJSON - null, как и у стандартных типов, ничего не меняет
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	parsed, err := ParseDecimal(string(bytes.Trim(data, `"`)))
+
+	if err != nil {
+		return err
+	}
+
+	*d = parsed
+
+	return nil
+}
+
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+func (d *Decimal) Scan(value any) error {
+	switch v := value.(type) {
+	case []byte:
+		return d.UnmarshalJSON(v)
+	case string:
+		return d.UnmarshalJSON([]byte(v))
+	case int64:
+		*d = NewDecimalFromInt(v)
+
+		return nil
+	case float64:
+		*d = Decimal{value: decimal.NewFromFloat(v)}
+
+		return nil
+	default:
+		return fmt.Errorf("%w: unsupported type %T", ErrInvalidDecimal, value)
+	}
+}
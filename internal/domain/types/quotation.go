@@ -1,8 +1,66 @@
 package types
 
-import "time"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
 
 type QuotationInfo struct {
-	Rate      string
+	Rate      Decimal
 	UpdatedAt time.Time
+	// Provider - какой провайдер отдал курс
+	Provider string
+	// Detail - разбивка по источникам, только при агрегации нескольких провайдеров
+	Detail *RateDetail
+}
+
+// RateDetail - как получен агрегированный курс
+type RateDetail struct {
+	// Confidence - доля опрошенных провайдеров, чьи курсы вошли в медиану, от 0 до 1
+	Confidence float64      `json:"confidence"`
+	Sources    []RateSource `json:"sources"`
+	// Synthetic - курс не получен от провайдера напрямую, а выведен через pivot валюту
+	Synthetic *SyntheticRate `json:"synthetic,omitempty"`
+}
+
+// SyntheticRate - из каких курсов выведен синтетический курс. Pivot пустой у обратного курса 1 / (QUOTE/BASE)
+type SyntheticRate struct {
+	Pivot Currency  `json:"pivot,omitempty"`
+	Legs  []RateLeg `json:"legs"`
+}
+
+// RateLeg - курс, полученный от провайдера. Inverted - в формуле участвует 1/Rate
+type RateLeg struct {
+	Base      Currency  `json:"base"`
+	Quote     Currency  `json:"quote"`
+	Rate      Decimal   `json:"rate"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Provider  string    `json:"provider"`
+	Inverted  bool      `json:"inverted"`
+}
+
+type RateSource struct {
+	Provider string `json:"provider"`
+	// Rate пустой, если провайдер не ответил
+	Rate *Decimal `json:"rate,omitempty"`
+	// Accepted - курс попал в медиану, а не отброшен как выброс
+	Accepted bool   `json:"accepted"`
+	Error    string `json:"error,omitempty"`
+}
+
+func (d RateDetail) Value() (driver.Value, error) {
+	return json.Marshal(d)
+}
+
+func (d *RateDetail) Scan(value any) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, d)
+	case string:
+		return json.Unmarshal([]byte(v), d)
+	default:
+		return fmt.Errorf("unsupported rate detail type %T", value)
+	}
 }
@@ -0,0 +1,87 @@
+package types
+
+import "fmt"
+
+type RoundingMode string
+
+const (
+	RoundHalfUp   RoundingMode = "half_up"
+	RoundHalfEven RoundingMode = "half_even"
+	RoundDown     RoundingMode = "down"
+)
+
+func (m RoundingMode) IsValid() bool {
+	switch m {
+	case RoundHalfUp, RoundHalfEven, RoundDown:
+		return true
+	}
+
+	return false
+}
+
+// RoundingPolicy - масштаб курса определяется котируемой валютой: ее minor units плюс ExtraScale.
+// Overrides задают масштаб явно, например для валют без minor units вроде XAU
+type RoundingPolicy struct {
+	ExtraScale int32
+	Mode       RoundingMode
+	Overrides  map[Currency]int32
+}
+
+var Rounding = RoundingPolicy{ExtraScale: 4, Mode: RoundHalfEven}
+
+func NewRoundingPolicy(extraScale int32, mode RoundingMode, overrides map[Currency]int32) (RoundingPolicy, error) {
+	if extraScale < 0 {
+		return RoundingPolicy{}, fmt.Errorf("extra scale must not be negative, got %d", extraScale)
+	}
+
+	if !mode.IsValid() {
+		return RoundingPolicy{}, fmt.Errorf("unknown rounding mode %q", mode)
+	}
+
+	for currency, scale := range overrides {
+		if scale < 0 {
+			return RoundingPolicy{}, fmt.Errorf("scale for %s must not be negative, got %d", currency, scale)
+		}
+	}
+
+	return RoundingPolicy{ExtraScale: extraScale, Mode: mode, Overrides: overrides}, nil
+}
+
+func (p RoundingPolicy) Scale(quote Currency) int32 {
+	if scale, exists := p.Overrides[quote]; exists {
+		return scale
+	}
+
+	minorUnits := 0
+
+	if info, exists := LookupCurrency(quote); exists && info.MinorUnits != MinorUnitsNotApplicable {
+		minorUnits = info.MinorUnits
+	}
+
+	return int32(minorUnits) + p.ExtraScale
+}
+
+// Apply приводит курс base/quote к масштабу quote. Результат всегда ровно Scale знаков после запятой
+func (p RoundingPolicy) Apply(quote Currency, rate Decimal) Decimal {
+	return p.round(rate, p.Scale(quote))
+}
+
+// ApplyAmount округляет сумму до minor units валюты. Для валют без minor units (XAU) используется масштаб курса
+func (p RoundingPolicy) ApplyAmount(currency Currency, amount Decimal) Decimal {
+	if info, exists := LookupCurrency(currency); exists && info.MinorUnits != MinorUnitsNotApplicable {
+		return p.round(amount, int32(info.MinorUnits))
+	}
+
+	return p.round(amount, p.Scale(currency))
+}
+
+func (p RoundingPolicy) round(value Decimal, scale int32) Decimal {
+	switch p.Mode {
+	case RoundHalfUp:
+		return Decimal{value: value.value.Round(scale)}
+	case RoundDown:
+		return Decimal{value: value.value.Truncate(scale).Round(scale)}
+	default:
+		return Decimal{value: value.value.RoundBank(scale)}
+	}
+}
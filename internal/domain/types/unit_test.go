@@ -0,0 +1,142 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CatalogueLoaded(t *testing.T) {
+	usd, exists := LookupCurrency(USD)
+
+	assert.True(t, exists)
+	assert.Equal(t, "840", usd.NumericCode)
+	assert.Equal(t, 2, usd.MinorUnits)
+	assert.Equal(t, "US Dollar", usd.Name)
+	assert.True(t, usd.Active)
+
+	all, exists := LookupCurrency("ALL")
+
+	assert.True(t, exists)
+	assert.Equal(t, "008", all.NumericCode)
+
+	jpy, _ := LookupCurrency("JPY")
+	assert.Equal(t, 0, jpy.MinorUnits)
+
+	xau, _ := LookupCurrency("XAU")
+	assert.Equal(t, MinorUnitsNotApplicable, xau.MinorUnits)
+
+	dem, exists := LookupCurrency("DEM")
+
+	assert.True(t, exists)
+	assert.False(t, dem.Active)
+
+	_, exists = LookupCurrency("ABC")
+	assert.False(t, exists)
+}
+
+func Test_RegistryEnabledSubset(t *testing.T) {
+	registry, err := NewCurrencyRegistry("GBP", "usd", " JPY", "GBP")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Currency{"GBP", USD, "JPY"}, registry.Codes())
+
+	assert.True(t, registry.IsEnabled("GBP"))
+	assert.False(t, registry.IsEnabled(EUR))
+
+	info, exists := registry.Get("JPY")
+
+	assert.True(t, exists)
+	assert.Equal(t, "392", info.NumericCode)
+}
+
+func Test_RegistryRejectsUnknownAndWithdrawn(t *testing.T) {
+	_, err := NewCurrencyRegistry(USD, "ABC")
+	assert.ErrorIs(t, err, ErrUnknownCurrency)
+
+	_, err = NewCurrencyRegistry(USD, "DEM")
+	assert.ErrorIs(t, err, ErrWithdrawnCurrency)
+}
+
+func Test_CurrencyIsValidUsesRegistry(t *testing.T) {
+	previous := Registry
+	defer func() { Registry = previous }()
+
+	assert.True(t, EUR.IsValid())
+	assert.False(t, Currency("GBP").IsValid())
+
+	Registry = mustNewCurrencyRegistry("GBP")
+
+	assert.False(t, EUR.IsValid())
+	assert.True(t, Currency("GBP").IsValid())
+}
+
+func Test_DecimalKeepsScale(t *testing.T) {
+	rate := MustParseDecimal("20.10")
+
+	assert.Equal(t, "20.10", rate.String())
+	assert.Equal(t, int32(2), rate.Scale())
+	assert.Equal(t, "0.00001", MustParseDecimal("1e-05").String())
+
+	_, err := ParseDecimal("20,1")
+	assert.ErrorIs(t, err, ErrInvalidDecimal)
+}
+
+func Test_DecimalJson(t *testing.T) {
+	var parsed struct {
+		Number Decimal `json:"number"`
+		String Decimal `json:"string"`
+	}
+
+	err := json.Unmarshal([]byte(`{"number":20.123456789012345678901,"string":"0.10"}`), &parsed)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "20.123456789012345678901", parsed.Number.String())
+	assert.Equal(t, "0.10", parsed.String.String())
+
+	encoded, err := json.Marshal(parsed)
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"number":"20.123456789012345678901","string":"0.10"}`, string(encoded))
+}
+
+func Test_DecimalJsonNull(t *testing.T) {
+	var parsed struct {
+		Rate     Decimal  `json:"rate"`
+		Optional *Decimal `json:"optional"`
+	}
+	parsed.Rate = MustParseDecimal("1.5")
+
+	err := json.Unmarshal([]byte(`{"rate":null,"optional":null}`), &parsed)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "1.5", parsed.Rate.String())
+	assert.Nil(t, parsed.Optional)
+}
+
+func Test_RoundingPolicy(t *testing.T) {
+	policy, err := NewRoundingPolicy(2, RoundHalfEven, map[Currency]int32{"XAU": 8})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(4), policy.Scale(USD))
+	assert.Equal(t, int32(2), policy.Scale("JPY"))
+	assert.Equal(t, int32(8), policy.Scale("XAU"))
+
+	assert.Equal(t, "20.1000", policy.Apply(MXN, MustParseDecimal("20.1")).String())
+	assert.Equal(t, "0.12", policy.Apply("JPY", MustParseDecimal("0.125")).String())
+	assert.Equal(t, "0.00030000", policy.Apply("XAU", MustParseDecimal("0.0003")).String())
+
+	policy.Mode = RoundHalfUp
+	assert.Equal(t, "0.13", policy.Apply("JPY", MustParseDecimal("0.125")).String())
+
+	policy.Mode = RoundDown
+	assert.Equal(t, "0.12", policy.Apply("JPY", MustParseDecimal("0.129")).String())
+
+	assert.Equal(t, "1234", policy.ApplyAmount("JPY", MustParseDecimal("1234.5")).String())
+	assert.Equal(t, "12.34", policy.ApplyAmount(USD, MustParseDecimal("12.345")).String())
+	assert.Equal(t, "0.00030000", policy.ApplyAmount("XAU", MustParseDecimal("0.0003")).String())
+
+	_, err = NewRoundingPolicy(2, "ceil", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,139 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock - источник времени для фоновых циклов, в тестах подменяется на Fake
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+type Real struct{}
+
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+func (Real) NewTimer(d time.Duration) Timer {
+	return realTimer{timer: time.NewTimer(d)}
+}
+
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t realTimer) Stop() bool {
+	return t.timer.Stop()
+}
+
+// Fake - время двигается только через Advance. BlockUntil позволяет дождаться, пока код под тестом
+// заведет таймеры, без sleep в тестах
+type Fake struct {
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock *Fake
+	at    time.Time
+	ch    chan time.Time
+}
+
+func NewFake(now time.Time) *Fake {
+	fake := &Fake{now: now}
+	fake.cond = sync.NewCond(&fake.mutex)
+
+	return fake
+}
+
+func (f *Fake) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.now
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	timer := &fakeTimer{clock: f, at: f.now.Add(d), ch: make(chan time.Time, 1)}
+
+	if d <= 0 {
+		timer.ch <- f.now
+
+		return timer
+	}
+
+	f.timers = append(f.timers, timer)
+	f.cond.Broadcast()
+
+	return timer
+}
+
+// Advance сдвигает время и срабатывает таймеры, срок которых наступил
+func (f *Fake) Advance(d time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.now = f.now.Add(d)
+
+	pending := f.timers[:0]
+
+	for _, timer := range f.timers {
+		if timer.at.After(f.now) {
+			pending = append(pending, timer)
+
+			continue
+		}
+
+		timer.ch <- f.now
+	}
+
+	f.timers = pending
+	f.cond.Broadcast()
+}
+
+// BlockUntil ждет, пока не будет ровно n заведенных и еще не сработавших таймеров
+func (f *Fake) BlockUntil(n int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for len(f.timers) != n {
+		f.cond.Wait()
+	}
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+
+	for i, timer := range t.clock.timers {
+		if timer == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			t.clock.cond.Broadcast()
+
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,7 +1,9 @@
 package config
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"plata_currency_quotation/internal/domain/types"
 	"plata_currency_quotation/internal/lib/env"
 	"time"
 
@@ -10,10 +12,52 @@ import (
 
 var Instance *Config
 
+type RateAggregation string
+
+const (
+	Failover  RateAggregation = "failover"
+	Consensus RateAggregation = "consensus"
+)
+
+type PivotMode string
+
+const (
+	// PivotFallback - через pivot только пары, которые провайдеры не отдали напрямую
+	PivotFallback PivotMode = "fallback"
+	// PivotAlways - все пары через pivot, прямые запросы только если вывести не удалось
+	PivotAlways PivotMode = "always"
+)
+
+type EventBus string
+
+const (
+	InMemoryBus EventBus = "inmemory"
+	PostgresBus EventBus = "postgres"
+)
+
+type RetentionMode string
+
+const (
+	RetentionDelete RetentionMode = "delete"
+	// RetentionExport - перед удалением запросы дописываются NDJSON файлом в RequestRetentionExportDir
+	RetentionExport RetentionMode = "export"
+)
+
 type Config struct {
 	Env env.Environment `env:"ENV" env-required:"true"`
 
-	QuotationUpdateIntervalMilliseconds int64 `env:"QUOTATION_UPDATE_INTERVAL_MILLISECONDS" env-required:"true"`
+	QuotationUpdateIntervalMilliseconds int64         `env:"QUOTATION_UPDATE_INTERVAL_MILLISECONDS" env-required:"true"`
+	QuotationUpdateDebounce             time.Duration `env:"QUOTATION_UPDATE_DEBOUNCE" env-default:"10ms"`
+	// QuotationRequestMaxAttempts - 0 без ограничения, тогда запрос завершит только QuotationRequestTtl
+	QuotationRequestMaxAttempts int           `env:"QUOTATION_REQUEST_MAX_ATTEMPTS" env-default:"5"`
+	QuotationRequestTtl         time.Duration `env:"QUOTATION_REQUEST_TTL" env-default:"10m"`
+
+	// RequestRetentionDays - запросы в конечном статусе старше стольких дней удаляются, 0 - хранятся всегда
+	RequestRetentionDays      int           `env:"REQUEST_RETENTION_DAYS" env-default:"0"`
+	RequestRetentionMode      RetentionMode `env:"REQUEST_RETENTION_MODE" env-default:"delete"`
+	RequestRetentionExportDir string        `env:"REQUEST_RETENTION_EXPORT_DIR"`
+	RequestRetentionBatchSize int           `env:"REQUEST_RETENTION_BATCH_SIZE" env-default:"500"`
+	RequestRetentionInterval  time.Duration `env:"REQUEST_RETENTION_INTERVAL" env-default:"1h"`
 
 	DbHost     string `env:"DB_HOST" env-required:"true"`
 	DbUser     string `env:"DB_USER" env-required:"true"`
@@ -21,11 +65,16 @@ type Config struct {
 	DbName     string `env:"DB_NAME" env-required:"true"`
 	DbPort     uint16 `env:"DB_PORT" env-required:"true"`
 	DbSslMode  string `env:"DB_SSL_MODE" env-required:"true"`
+	// DbAutoMigrate - применять миграции при старте. Если выключено, при отстающей схеме сервис не стартует
+	DbAutoMigrate bool `env:"DB_AUTO_MIGRATE" env-default:"true"`
 
 	ServerIp               string        `env:"SERVER_IP" env-required:"true"`
 	ServerPort             uint16        `env:"SERVER_PORT" env-required:"true"`
 	OutgoingRequestTimeout time.Duration `env:"OUTGOING_REQUEST_TIMEOUT" env-required:"true"`
 	IncomingRequestTimeout time.Duration `env:"INCOMING_REQUEST_TIMEOUT" env-required:"true"`
+	MaxQuotationWait       time.Duration `env:"MAX_QUOTATION_WAIT" env-default:"10s"`
+	QuotationMaxAge        time.Duration `env:"QUOTATION_MAX_AGE" env-default:"96h"`
+	ShutdownTimeout        time.Duration `env:"SHUTDOWN_TIMEOUT" env-default:"30s"`
 
 	SwaggerUser     string `env:"SWAGGER_USER"`
 	SwaggerPassword string `env:"SWAGGER_PASSWORD"`
@@ -34,28 +83,136 @@ type Config struct {
 	MetricsIp   string `env:"METRICS_IP" env-required:"true"`
 
 	FrankfurterApiUrl string `env:"FRANKFURTER_API_URL" env-required:"true"`
+
+	EcbDailyUrl   string `env:"ECB_DAILY_URL" env-default:"https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"`
+	EcbHistoryUrl string `env:"ECB_HISTORY_URL" env-default:"https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist-90d.xml"`
+
+	JsonProviderLatestUrl     string `env:"JSON_PROVIDER_LATEST_URL"`
+	JsonProviderHistoricalUrl string `env:"JSON_PROVIDER_HISTORICAL_URL"`
+	JsonProviderRatesPath     string `env:"JSON_PROVIDER_RATES_PATH" env-default:"rates"`
+
+	RatesFile string `env:"RATES_FILE"`
+
+	// RateProviders - провайдеры курсов в порядке приоритета
+	RateProviders        []string      `env:"RATE_PROVIDERS" env-default:"frankfurter"`
+	RateProviderCooldown time.Duration `env:"RATE_PROVIDER_COOLDOWN" env-default:"30s"`
+	// повторы и автомат для http провайдеров, считаются отдельно по каждому провайдеру
+	RateProviderRetryMaxAttempts    int           `env:"RATE_PROVIDER_RETRY_MAX_ATTEMPTS" env-default:"3"`
+	RateProviderRetryInitialBackoff time.Duration `env:"RATE_PROVIDER_RETRY_INITIAL_BACKOFF" env-default:"200ms"`
+	RateProviderRetryMaxBackoff     time.Duration `env:"RATE_PROVIDER_RETRY_MAX_BACKOFF" env-default:"5s"`
+	RateProviderBreakerFailures     int           `env:"RATE_PROVIDER_BREAKER_FAILURES" env-default:"5"`
+	RateProviderBreakerOpenTimeout  time.Duration `env:"RATE_PROVIDER_BREAKER_OPEN_TIMEOUT" env-default:"30s"`
+	// RateAggregation - failover: первый ответивший по приоритету, consensus: медиана по всем провайдерам
+	RateAggregation         RateAggregation `env:"RATE_AGGREGATION" env-default:"failover"`
+	RateConsensusTolerance  float64         `env:"RATE_CONSENSUS_TOLERANCE" env-default:"0.01"`
+	RateConsensusMinSources int             `env:"RATE_CONSENSUS_MIN_SOURCES" env-default:"1"`
+	// RatePivot - валюта для кросс-курсов (EUR или USD), пусто - без триангуляции
+	RatePivot     types.Currency `env:"RATE_PIVOT"`
+	RatePivotMode PivotMode      `env:"RATE_PIVOT_MODE" env-default:"fallback"`
+
+	WebhookDispatchInterval time.Duration `env:"WEBHOOK_DISPATCH_INTERVAL" env-default:"1s"`
+	WebhookMaxAttempts      int           `env:"WEBHOOK_MAX_ATTEMPTS" env-default:"8"`
+	WebhookInitialBackoff   time.Duration `env:"WEBHOOK_INITIAL_BACKOFF" env-default:"5s"`
+	WebhookMaxBackoff       time.Duration `env:"WEBHOOK_MAX_BACKOFF" env-default:"10m"`
+
+	// LeaderElection - несколько реплик: к провайдерам ходит и вебхуки шлет только держатель аренды в бд
+	LeaderElection bool          `env:"LEADER_ELECTION" env-default:"false"`
+	LeaderLeaseTtl time.Duration `env:"LEADER_LEASE_TTL" env-default:"15s"`
+
+	// EventBus - как реплики узнают о новых курсах друг друга, postgres - LISTEN/NOTIFY
+	EventBus EventBus `env:"EVENT_BUS" env-default:"inmemory"`
+	// QuotationReconcileInterval - с EventBus = postgres реплика, которая не лидер, перечитывает кэш из бд так редко
+	QuotationReconcileInterval time.Duration `env:"QUOTATION_RECONCILE_INTERVAL" env-default:"5m"`
+
+	// SubscriptionsFile - JSON с парами, которые обновляются сами по себе, см. README
+	SubscriptionsFile string `env:"SUBSCRIPTIONS_FILE"`
+
+	EnabledCurrencies []types.Currency `env:"ENABLED_CURRENCIES" env-default:"USD,EUR,MXN"`
+
+	// Масштаб курса - minor units котируемой валюты плюс RateExtraScale, RateScaleOverrides задают его явно
+	RateExtraScale     int32              `env:"RATE_EXTRA_SCALE" env-default:"4"`
+	RateRoundingMode   types.RoundingMode `env:"RATE_ROUNDING_MODE" env-default:"half_even"`
+	RateScaleOverrides map[string]int32   `env:"RATE_SCALE_OVERRIDES"`
 }
 
-func FromEnv() *Config {
+// Load читает и проверяет конфиг из переменных окружения
+func Load() (*Config, error) {
 	var cfg Config
 
-	err := cleanenv.ReadEnv(&cfg)
-	if err != nil {
-		log.Fatalf("cannot read config: %s internal", err)
+	if err := cleanenv.ReadEnv(&cfg); err != nil {
+		return nil, err
 	}
 
-	switch cfg.Env {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+func (c *Config) validate() error {
+	switch c.Env {
 	case env.Local, env.Dev, env.Preprod, env.Prod:
 	default:
-		log.Fatalf("invalid Env value: %s internal", cfg.Env)
+		return fmt.Errorf("invalid Env value: %s", c.Env)
+	}
+
+	switch c.RateAggregation {
+	case Failover, Consensus:
+	default:
+		return fmt.Errorf("invalid RateAggregation value: %s", c.RateAggregation)
+	}
+
+	switch c.EventBus {
+	case InMemoryBus, PostgresBus:
+	default:
+		return fmt.Errorf("invalid EventBus value: %s", c.EventBus)
+	}
+
+	switch c.RatePivot {
+	case "", types.EUR, types.USD:
+	default:
+		return fmt.Errorf("invalid RatePivot value: %s, only EUR and USD are supported", c.RatePivot)
 	}
 
-	switch cfg.Env {
+	switch c.RatePivotMode {
+	case PivotFallback, PivotAlways:
+	default:
+		return fmt.Errorf("invalid RatePivotMode value: %s", c.RatePivotMode)
+	}
+
+	switch c.RequestRetentionMode {
+	case RetentionDelete:
+	case RetentionExport:
+		if c.RequestRetentionExportDir == "" {
+			return fmt.Errorf("REQUEST_RETENTION_EXPORT_DIR must be set for %s retention mode", c.RequestRetentionMode)
+		}
+	default:
+		return fmt.Errorf("invalid RequestRetentionMode value: %s", c.RequestRetentionMode)
+	}
+
+	if c.RequestRetentionDays < 0 || c.RequestRetentionBatchSize <= 0 || c.RequestRetentionInterval <= 0 {
+		return errors.New("REQUEST_RETENTION_DAYS must not be negative, REQUEST_RETENTION_BATCH_SIZE and REQUEST_RETENTION_INTERVAL must be positive")
+	}
+
+	switch c.Env {
 	case env.Dev, env.Preprod:
-		if cfg.SwaggerUser == "" || cfg.SwaggerPassword == "" {
-			log.Fatalf("SWAGGER_USER and SWAGGER_PASSWORD must be set in %s environment", cfg.Env)
+		if c.SwaggerUser == "" || c.SwaggerPassword == "" {
+			return fmt.Errorf("SWAGGER_USER and SWAGGER_PASSWORD must be set in %s environment", c.Env)
 		}
 	}
 
-	return &cfg
+	return nil
+}
+
+func (c *Config) PostgresDsn() string {
+	return fmt.Sprintf(
+		"host=%s user=%s password=%s dbname=%s port=%d sslmode=%s TimeZone=UTC",
+		c.DbHost,
+		c.DbUser,
+		c.DbPassword,
+		c.DbName,
+		c.DbPort,
+		c.DbSslMode,
+	)
 }
@@ -1,10 +1,7 @@
 package metrics
 
 import (
-	"log/slog"
 	"net/http"
-	"os"
-	"plata_currency_quotation/internal/lib/logger/sl"
 	"strconv"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -35,14 +32,10 @@ var (
 	)
 )
 
-func Run(ip string, port uint16, services ...SetupMetricsInterface) {
-	var addr string
+// NewServer регистрирует метрики сервисов и возвращает сервер /metrics. Запуск и остановка - на вызывающем
+func NewServer(ip string, port uint16, services ...SetupMetricsInterface) *http.Server {
 	reg := prometheus.NewRegistry()
 
-	log := sl.Log.With(
-		slog.String("component", "middleware/metrics"),
-	)
-
 	reg.MustRegister(
 		collectors.NewGoCollector(),
 		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
@@ -58,15 +51,5 @@ func Run(ip string, port uint16, services ...SetupMetricsInterface) {
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
 
-	addr = ip + ":" + strconv.Itoa(int(port))
-
-	go func() {
-		log.Info("metrics are available at endpoint " + addr + "/metrics")
-
-		if err := http.ListenAndServe(addr, mux); err != nil {
-			log.Error("failed to start metrics server", sl.Err(err))
-
-			os.Exit(1)
-		}
-	}()
+	return &http.Server{Addr: ip + ":" + strconv.Itoa(int(port)), Handler: mux}
 }
@@ -1,21 +1,36 @@
 package inmemory
 
 import (
+	"plata_currency_quotation/internal/domain/enity/lease"
+	qb "plata_currency_quotation/internal/domain/enity/quotation-batch"
+	qh "plata_currency_quotation/internal/domain/enity/quotation-history"
 	qr "plata_currency_quotation/internal/domain/enity/quotation-request"
+	wd "plata_currency_quotation/internal/domain/enity/webhook-delivery"
 	"sync"
 )
 
 type Db struct {
-	store []*qr.QuotationRequest
-	mutex sync.Mutex
+	store      []*qr.QuotationRequest
+	batches    []qb.QuotationBatch
+	deliveries []*wd.WebhookDelivery
+	history    []qh.QuotationHistory
+	leases     map[string]lease.Lease
+	mutex      sync.Mutex
 }
 
 func (d *Db) OnStart() error {
 	return nil
 }
 
+func (d *Db) Close() error {
+	return nil
+}
+
 func New() *Db {
 	return &Db{
-		store: make([]*qr.QuotationRequest, 0),
+		store:      make([]*qr.QuotationRequest, 0),
+		deliveries: make([]*wd.WebhookDelivery, 0),
+		history:    make([]qh.QuotationHistory, 0),
+		leases:     make(map[string]lease.Lease),
 	}
 }
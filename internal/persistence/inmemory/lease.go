@@ -0,0 +1,34 @@
+package inmemory
+
+import (
+	"context"
+	"plata_currency_quotation/internal/domain/enity/lease"
+	"time"
+)
+
+func (d *Db) LeaseAcquire(_ context.Context, name string, holder string, ttl time.Duration) (bool, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := time.Now()
+	existing, exists := d.leases[name]
+
+	if exists && existing.Holder != holder && now.Before(existing.ExpiresAt) {
+		return false, nil
+	}
+
+	d.leases[name] = lease.Lease{Name: name, Holder: holder, ExpiresAt: now.Add(ttl)}
+
+	return true, nil
+}
+
+func (d *Db) LeaseRelease(name string, holder string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if existing, exists := d.leases[name]; exists && existing.Holder == holder {
+		delete(d.leases, name)
+	}
+
+	return nil
+}
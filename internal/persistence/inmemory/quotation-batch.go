@@ -0,0 +1,74 @@
+package inmemory
+
+import (
+	"cmp"
+	qb "plata_currency_quotation/internal/domain/enity/quotation-batch"
+	qr "plata_currency_quotation/internal/domain/enity/quotation-request"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+func (d *Db) QuotationBatchCreateOrGetByIdempotencyKey(batch *qb.QuotationBatch) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for _, existing := range d.batches {
+		if existing.IdempotencyKey == batch.IdempotencyKey {
+			*batch = d.withRequests(existing)
+
+			return nil
+		}
+	}
+
+	header := *batch
+	header.Requests = nil
+
+	d.batches = append(d.batches, header)
+
+	for i := range batch.Requests {
+		var clone qr.QuotationRequest
+
+		deepClone(&batch.Requests[i], &clone)
+
+		d.store = append(d.store, &clone)
+	}
+
+	return nil
+}
+
+func (d *Db) QuotationBatchGetById(id uuid.UUID) (*qb.QuotationBatch, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for _, existing := range d.batches {
+		if existing.Id == id {
+			batch := d.withRequests(existing)
+
+			return &batch, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (d *Db) withRequests(header qb.QuotationBatch) qb.QuotationBatch {
+	batch := header
+	batch.Requests = nil
+
+	for _, req := range d.store {
+		if req.BatchId != nil && *req.BatchId == header.Id {
+			var clone qr.QuotationRequest
+
+			deepClone(req, &clone)
+
+			batch.Requests = append(batch.Requests, clone)
+		}
+	}
+
+	slices.SortFunc(batch.Requests, func(a, b qr.QuotationRequest) int {
+		return cmp.Or(cmp.Compare(a.BaseCurrency, b.BaseCurrency), cmp.Compare(a.QuoteCurrency, b.QuoteCurrency))
+	})
+
+	return batch
+}
@@ -0,0 +1,74 @@
+package inmemory
+
+import (
+	qh "plata_currency_quotation/internal/domain/enity/quotation-history"
+	"plata_currency_quotation/internal/domain/types"
+	"plata_currency_quotation/internal/persistence"
+	"sort"
+	"time"
+)
+
+func (d *Db) QuotationHistoryAppend(records []qh.QuotationHistory) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.history = append(d.history, records...)
+
+	return nil
+}
+
+func (d *Db) QuotationHistoryGetLatest() ([]persistence.PairQuotation, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	latest := make(map[[2]types.Currency]qh.QuotationHistory)
+
+	for _, record := range d.history {
+		key := [2]types.Currency{record.BaseCurrency, record.QuoteCurrency}
+
+		if existing, exists := latest[key]; !exists || record.FetchedAt.After(existing.FetchedAt) {
+			latest[key] = record
+		}
+	}
+
+	result := make([]persistence.PairQuotation, 0, len(latest))
+
+	for key, record := range latest {
+		result = append(result, persistence.PairQuotation{
+			BaseCurrency:  key[0],
+			QuoteCurrency: key[1],
+			QuotationInfo: types.QuotationInfo{Rate: record.Rate, UpdatedAt: record.FetchedAt, Provider: record.Provider},
+		})
+	}
+
+	return result, nil
+}
+
+func (d *Db) QuotationHistoryGetRange(baseCurrency types.Currency, quoteCurrency types.Currency, from time.Time, to time.Time, limit int) ([]qh.QuotationHistory, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	result := make([]qh.QuotationHistory, 0)
+
+	for _, record := range d.history {
+		if record.BaseCurrency != baseCurrency || record.QuoteCurrency != quoteCurrency {
+			continue
+		}
+
+		if record.FetchedAt.Before(from) || !record.FetchedAt.Before(to) {
+			continue
+		}
+
+		result = append(result, record)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].FetchedAt.Before(result[j].FetchedAt)
+	})
+
+	if len(result) > limit {
+		result = result[:limit]
+	}
+
+	return result, nil
+}
@@ -1,8 +1,12 @@
 package inmemory
 
 import (
+	qb "plata_currency_quotation/internal/domain/enity/quotation-batch"
 	qr "plata_currency_quotation/internal/domain/enity/quotation-request"
+	wd "plata_currency_quotation/internal/domain/enity/webhook-delivery"
 	"plata_currency_quotation/internal/domain/types"
+	"plata_currency_quotation/internal/persistence"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -24,8 +28,18 @@ func (d *Db) QuotationRequestCreateOrGetByIdempotencyKey(request *qr.QuotationRe
 
 	deepClone(request, &clone)
 
+	// как default колонки в postgres
+	if clone.Status == "" {
+		clone.Status = qr.Pending
+	}
+
 	d.store = append(d.store, &clone)
 
+	// запрос может быть создан уже завершенным (исторический курс из кэша), вебхук все равно нужен
+	if clone.CompletedAt != nil {
+		d.enqueueWebhook(&clone, time.Now())
+	}
+
 	return nil
 }
 
@@ -46,14 +60,18 @@ func (d *Db) QuotationRequestGetById(id uuid.UUID) (*qr.QuotationRequest, error)
 	return nil, nil
 }
 
-func (d *Db) QuotationRequestUpdateByBaseAndQuote(baseCurrency types.Currency, quoteCurrency types.Currency, price string, t time.Time) error {
+func (d *Db) QuotationRequestCompletePending(baseCurrency types.Currency, quoteCurrency types.Currency, pendingAt time.Time, quotation types.QuotationInfo) error {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
 
+	now := time.Now()
+	scope := persistence.PendingScope{BaseCurrency: baseCurrency, QuoteCurrency: quoteCurrency, PendingAt: pendingAt}
+
 	for _, req := range d.store {
-		if req.BaseCurrency == baseCurrency && req.QuoteCurrency == quoteCurrency {
-			req.Rate = &price
-			req.CompletedAt = &t
+		if matchesPending(req, scope) {
+			d.enqueueWebhook(req, now)
+
+			fill(req, quotation)
 		}
 	}
 
@@ -68,7 +86,7 @@ func (d *Db) QuotationRequestGetUniqUnhandled() ([][2]types.Currency, error) {
 
 outer:
 	for _, req := range d.store {
-		if req.CompletedAt == nil {
+		if req.AsOf == nil && isOpen(req) {
 			key := [2]types.Currency{req.BaseCurrency, req.QuoteCurrency}
 
 			for _, existing := range result {
@@ -84,6 +102,355 @@ outer:
 	return result, nil
 }
 
+func (d *Db) QuotationRequestGetUniqUnhandledHistorical() ([]persistence.HistoricalPair, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	result := make([]persistence.HistoricalPair, 0)
+
+outer:
+	for _, req := range d.store {
+		if req.AsOf != nil && isOpen(req) {
+			key := persistence.HistoricalPair{BaseCurrency: req.BaseCurrency, QuoteCurrency: req.QuoteCurrency, AsOf: *req.AsOf}
+
+			for _, existing := range result {
+				if existing == key {
+					continue outer
+				}
+			}
+
+			result = append(result, key)
+		}
+	}
+
+	return result, nil
+}
+
+func (d *Db) QuotationRequestCompleteHistorical(pair persistence.HistoricalPair, quotation types.QuotationInfo) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := time.Now()
+
+	for _, req := range d.store {
+		if matchesHistorical(req, pair) && isOpen(req) {
+			d.enqueueWebhook(req, now)
+
+			fill(req, quotation)
+		}
+	}
+
+	return nil
+}
+
+func (d *Db) QuotationRequestGetCompletedHistorical(pair persistence.HistoricalPair) (*qr.QuotationRequest, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for _, req := range d.store {
+		if matchesHistorical(req, pair) && req.Status == qr.Ready {
+			var clone qr.QuotationRequest
+
+			deepClone(req, &clone)
+
+			return &clone, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (d *Db) QuotationRequestGetLatestCompleted() ([]persistence.PairQuotation, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	latest := make(map[[2]types.Currency]*qr.QuotationRequest)
+
+	for _, req := range d.store {
+		if req.AsOf != nil || req.Status != qr.Ready {
+			continue
+		}
+
+		key := [2]types.Currency{req.BaseCurrency, req.QuoteCurrency}
+
+		if existing, exists := latest[key]; !exists || req.CompletedAt.After(*existing.CompletedAt) {
+			latest[key] = req
+		}
+	}
+
+	result := make([]persistence.PairQuotation, 0, len(latest))
+
+	for key, req := range latest {
+		quotation := persistence.PairQuotation{
+			BaseCurrency:  key[0],
+			QuoteCurrency: key[1],
+			QuotationInfo: types.QuotationInfo{Rate: *req.Rate, UpdatedAt: *req.CompletedAt, Detail: req.Detail},
+		}
+
+		if req.Provider != nil {
+			quotation.Provider = *req.Provider
+		}
+
+		result = append(result, quotation)
+	}
+
+	return result, nil
+}
+
+func (d *Db) QuotationRequestStartAttempt(scope persistence.PendingScope) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for _, req := range d.store {
+		if matchesPending(req, scope) {
+			req.Status = qr.InProgress
+			req.Attempts++
+		}
+	}
+
+	return nil
+}
+
+func (d *Db) QuotationRequestFailAttempt(scope persistence.PendingScope, failure qr.Failure, maxAttempts int) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := time.Now()
+
+	for _, req := range d.store {
+		if !matchesPending(req, scope) || req.Status != qr.InProgress {
+			continue
+		}
+
+		code, message := failure.Code, failure.Message
+		req.ErrorCode, req.ErrorMessage = &code, &message
+		req.Status = qr.Pending
+
+		if maxAttempts > 0 && req.Attempts >= maxAttempts {
+			req.Status = qr.Failed
+			d.enqueueWebhook(req, now)
+		}
+	}
+
+	return nil
+}
+
+func (d *Db) QuotationRequestExpire(startedBefore time.Time) (int64, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var expired int64
+	now := time.Now()
+
+	for _, req := range d.store {
+		if !isOpen(req) || !req.TtlStartedAt().Before(startedBefore) {
+			continue
+		}
+
+		code, message := qr.ErrorExpired, "request was not completed in time"
+		req.ErrorCode, req.ErrorMessage = &code, &message
+		req.Status = qr.Expired
+		d.enqueueWebhook(req, now)
+		expired++
+	}
+
+	return expired, nil
+}
+
+func (d *Db) QuotationRequestList(filter persistence.QuotationRequestFilter) ([]qr.QuotationRequest, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	result := make([]qr.QuotationRequest, 0)
+
+	for _, req := range d.store {
+		if filter.Status != "" && req.Status != filter.Status {
+			continue
+		}
+
+		if filter.BaseCurrency != "" && req.BaseCurrency != filter.BaseCurrency {
+			continue
+		}
+
+		if filter.QuoteCurrency != "" && req.QuoteCurrency != filter.QuoteCurrency {
+			continue
+		}
+
+		var clone qr.QuotationRequest
+
+		deepClone(req, &clone)
+
+		result = append(result, clone)
+	}
+
+	slices.SortStableFunc(result, func(a, b qr.QuotationRequest) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[:filter.Limit]
+	}
+
+	return result, nil
+}
+
+func (d *Db) QuotationRequestRetry(id uuid.UUID) (bool, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for _, req := range d.store {
+		if req.Id == id && req.Status != qr.Ready {
+			now := time.Now()
+			req.Status = qr.Pending
+			req.Attempts = 0
+			req.ErrorCode, req.ErrorMessage = nil, nil
+			req.AttemptStartedAt = &now
+
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (d *Db) QuotationRequestExpireById(id uuid.UUID, message string) (bool, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for _, req := range d.store {
+		if req.Id == id && isOpen(req) {
+			code := qr.ErrorExpired
+			req.ErrorCode, req.ErrorMessage = &code, &message
+			req.Status = qr.Expired
+			d.enqueueWebhook(req, time.Now())
+
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (d *Db) QuotationRequestPurge(createdBefore time.Time, limit int, archive func([]qr.QuotationRequest) error) ([]qr.QuotationRequest, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	purgeable := func(req *qr.QuotationRequest) bool {
+		return req.Status.IsFinished() && req.CreatedAt.Before(createdBefore) && !d.hasPendingDelivery(req.Id)
+	}
+
+	purged := make([]qr.QuotationRequest, 0)
+
+	for _, req := range d.store {
+		if !purgeable(req) {
+			continue
+		}
+
+		if req.BatchId != nil && slices.ContainsFunc(d.store, func(other *qr.QuotationRequest) bool {
+			return other.BatchId != nil && *other.BatchId == *req.BatchId && !purgeable(other)
+		}) {
+			continue
+		}
+
+		var clone qr.QuotationRequest
+
+		deepClone(req, &clone)
+
+		purged = append(purged, clone)
+	}
+
+	slices.SortStableFunc(purged, func(a, b qr.QuotationRequest) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	if limit > 0 && len(purged) > limit {
+		purged = purged[:limit]
+	}
+
+	if len(purged) == 0 {
+		return purged, nil
+	}
+
+	if archive != nil {
+		if err := archive(purged); err != nil {
+			return nil, err
+		}
+	}
+
+	ids := make(map[uuid.UUID]bool, len(purged))
+	batchIds := make(map[uuid.UUID]bool)
+
+	for _, request := range purged {
+		ids[request.Id] = true
+
+		if request.BatchId != nil {
+			batchIds[*request.BatchId] = true
+		}
+	}
+
+	d.store = slices.DeleteFunc(d.store, func(req *qr.QuotationRequest) bool { return ids[req.Id] })
+	d.deliveries = slices.DeleteFunc(d.deliveries, func(delivery *wd.WebhookDelivery) bool { return ids[delivery.RequestId] })
+	d.batches = slices.DeleteFunc(d.batches, func(batch qb.QuotationBatch) bool {
+		return batchIds[batch.Id] && !slices.ContainsFunc(d.store, func(req *qr.QuotationRequest) bool {
+			return req.BatchId != nil && *req.BatchId == batch.Id
+		})
+	})
+
+	return purged, nil
+}
+
+func (d *Db) hasPendingDelivery(requestId uuid.UUID) bool {
+	return slices.ContainsFunc(d.deliveries, func(delivery *wd.WebhookDelivery) bool {
+		return delivery.RequestId == requestId && delivery.Status == wd.Pending
+	})
+}
+
+func isOpen(req *qr.QuotationRequest) bool {
+	return req.Status == qr.Pending || req.Status == qr.InProgress
+}
+
+func matchesPending(req *qr.QuotationRequest, scope persistence.PendingScope) bool {
+	if !isOpen(req) || req.CreatedAt.After(scope.PendingAt) {
+		return false
+	}
+
+	if req.BaseCurrency != scope.BaseCurrency || req.QuoteCurrency != scope.QuoteCurrency {
+		return false
+	}
+
+	if scope.AsOf == nil {
+		return req.AsOf == nil
+	}
+
+	return req.AsOf != nil && req.AsOf.Equal(*scope.AsOf)
+}
+
+func matchesHistorical(req *qr.QuotationRequest, pair persistence.HistoricalPair) bool {
+	return req.AsOf != nil &&
+		req.AsOf.Equal(pair.AsOf) &&
+		req.BaseCurrency == pair.BaseCurrency &&
+		req.QuoteCurrency == pair.QuoteCurrency
+}
+
+func fill(req *qr.QuotationRequest, quotation types.QuotationInfo) {
+	rate, completedAt, provider := quotation.Rate, quotation.UpdatedAt, quotation.Provider
+
+	req.Status = qr.Ready
+	req.Rate = &rate
+	req.CompletedAt = &completedAt
+	req.Provider = &provider
+	req.Detail = quotation.Detail
+}
+
+func (d *Db) enqueueWebhook(req *qr.QuotationRequest, now time.Time) {
+	if req.CallbackUrl == nil || req.CallbackSecret == nil {
+		return
+	}
+
+	delivery := wd.New(req.Id, *req.CallbackUrl, *req.CallbackSecret, now)
+	d.deliveries = append(d.deliveries, &delivery)
+}
+
 func deepClone(src *qr.QuotationRequest, dst *qr.QuotationRequest) {
 	if src == nil {
 		return
@@ -97,8 +464,61 @@ func deepClone(src *qr.QuotationRequest, dst *qr.QuotationRequest) {
 
 	}
 
+	if src.AttemptStartedAt != nil {
+		a := *src.AttemptStartedAt
+		dst.AttemptStartedAt = &a
+	}
+
+	if src.ErrorCode != nil {
+		c := *src.ErrorCode
+		dst.ErrorCode = &c
+	}
+
+	if src.ErrorMessage != nil {
+		m := *src.ErrorMessage
+		dst.ErrorMessage = &m
+	}
+
 	if src.Rate != nil {
 		r := *src.Rate
 		dst.Rate = &r
 	}
+
+	if src.CallbackUrl != nil {
+		u := *src.CallbackUrl
+		dst.CallbackUrl = &u
+	}
+
+	if src.CallbackSecret != nil {
+		s := *src.CallbackSecret
+		dst.CallbackSecret = &s
+	}
+
+	if src.AsOf != nil {
+		a := *src.AsOf
+		dst.AsOf = &a
+	}
+
+	if src.BatchId != nil {
+		b := *src.BatchId
+		dst.BatchId = &b
+	}
+
+	if src.Provider != nil {
+		p := *src.Provider
+		dst.Provider = &p
+	}
+
+	if src.Detail != nil {
+		d := *src.Detail
+		d.Sources = append([]types.RateSource(nil), src.Detail.Sources...)
+
+		if src.Detail.Synthetic != nil {
+			synthetic := *src.Detail.Synthetic
+			synthetic.Legs = append([]types.RateLeg(nil), src.Detail.Synthetic.Legs...)
+			d.Synthetic = &synthetic
+		}
+
+		dst.Detail = &d
+	}
 }
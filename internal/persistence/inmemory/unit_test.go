@@ -1,11 +1,15 @@
 package inmemory
 
 import (
+	"errors"
 	"testing"
 	"time"
 
+	qb "plata_currency_quotation/internal/domain/enity/quotation-batch"
 	qr "plata_currency_quotation/internal/domain/enity/quotation-request"
+	wd "plata_currency_quotation/internal/domain/enity/webhook-delivery"
 	"plata_currency_quotation/internal/domain/types"
+	"plata_currency_quotation/internal/persistence"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -91,7 +95,7 @@ func Test_FoundAndNotFound(t *testing.T) {
 	assert.Nil(t, other)
 }
 
-func Test_UpdateByBaseAndQuote(t *testing.T) {
+func Test_CompletePending(t *testing.T) {
 	db := newTestDb()
 	now := time.Now()
 
@@ -105,7 +109,7 @@ func Test_UpdateByBaseAndQuote(t *testing.T) {
 	err := db.QuotationRequestCreateOrGetByIdempotencyKey(req)
 	assert.NoError(t, err)
 
-	err = db.QuotationRequestUpdateByBaseAndQuote(types.USD, types.EUR, "1.25", now)
+	err = db.QuotationRequestCompletePending(types.USD, types.EUR, time.Now(), types.QuotationInfo{Rate: types.MustParseDecimal("1.25"), UpdatedAt: now})
 	assert.NoError(t, err)
 
 	req, err = db.QuotationRequestGetById(req.Id)
@@ -114,11 +118,62 @@ func Test_UpdateByBaseAndQuote(t *testing.T) {
 	assert.NotNil(t, req)
 	assert.NotNil(t, req.Rate)
 
-	assert.Equal(t, "1.25", *req.Rate)
+	assert.Equal(t, "1.25", req.Rate.String())
 	assert.NotNil(t, req.CompletedAt)
 	assert.WithinDuration(t, now, *req.CompletedAt, time.Second)
 }
 
+func Test_CompletedRequestsAreImmutable(t *testing.T) {
+	db := newTestDb()
+	start := time.Now().Add(-time.Hour)
+
+	create := func(createdAt time.Time) uuid.UUID {
+		request, err := qr.New(types.USD, types.EUR, uuid.New())
+		assert.NoError(t, err)
+
+		request.CreatedAt = createdAt
+
+		assert.NoError(t, db.QuotationRequestCreateOrGetByIdempotencyKey(&request))
+
+		return request.Id
+	}
+
+	rate := func(id uuid.UUID) *types.Decimal {
+		stored, err := db.QuotationRequestGetById(id)
+		assert.NoError(t, err)
+
+		return stored.Rate
+	}
+
+	old := create(start)
+
+	assert.NoError(t, db.QuotationRequestCompletePending(types.USD, types.EUR, start.Add(time.Minute), types.QuotationInfo{Rate: types.MustParseDecimal("1.25"), UpdatedAt: start.Add(time.Minute)}))
+
+	pending := create(start.Add(2 * time.Minute))
+	// создан, пока шел поход к провайдеру
+	late := create(start.Add(4 * time.Minute))
+
+	assert.NoError(t, db.QuotationRequestCompletePending(types.USD, types.EUR, start.Add(3*time.Minute), types.QuotationInfo{Rate: types.MustParseDecimal("1.26"), UpdatedAt: start.Add(3 * time.Minute)}))
+
+	assert.Equal(t, "1.25", rate(old).String())
+	assert.Equal(t, "1.26", rate(pending).String())
+	assert.Nil(t, rate(late))
+
+	stored, err := db.QuotationRequestGetById(old)
+	assert.NoError(t, err)
+	assert.Equal(t, start.Add(time.Minute), *stored.CompletedAt)
+
+	keys, err := db.QuotationRequestGetUniqUnhandled()
+	assert.NoError(t, err)
+	assert.Equal(t, [][2]types.Currency{{types.USD, types.EUR}}, keys)
+
+	assert.NoError(t, db.QuotationRequestCompletePending(types.USD, types.EUR, start.Add(5*time.Minute), types.QuotationInfo{Rate: types.MustParseDecimal("1.27"), UpdatedAt: start.Add(5 * time.Minute)}))
+
+	assert.Equal(t, "1.25", rate(old).String())
+	assert.Equal(t, "1.26", rate(pending).String())
+	assert.Equal(t, "1.27", rate(late).String())
+}
+
 func Test_GetUniqUnhandled(t *testing.T) {
 	db := newTestDb()
 
@@ -137,12 +192,13 @@ func Test_GetUniqUnhandled(t *testing.T) {
 	}
 
 	now := time.Now()
-	rate := "2.4"
+	rate := types.MustParseDecimal("2.4")
 	req3 := &qr.QuotationRequest{
 		Id:             uuid.New(),
 		IdempotencyKey: uuid.New(),
 		BaseCurrency:   types.USD,
 		QuoteCurrency:  types.MXN,
+		Status:         qr.Ready,
 		Rate:           &rate,
 		CompletedAt:    &now,
 	}
@@ -163,3 +219,461 @@ func Test_GetUniqUnhandled(t *testing.T) {
 		{types.USD, types.EUR},
 	}, keys)
 }
+
+func Test_UpdateEnqueuesWebhookOnlyForPendingWithCallback(t *testing.T) {
+	db := newTestDb()
+
+	url := "http://localhost/hook"
+	secret := "secret"
+
+	withCallback := &qr.QuotationRequest{
+		Id:             uuid.New(),
+		IdempotencyKey: uuid.New(),
+		BaseCurrency:   types.USD,
+		QuoteCurrency:  types.EUR,
+		CallbackUrl:    &url,
+		CallbackSecret: &secret,
+	}
+
+	withoutCallback := &qr.QuotationRequest{
+		Id:             uuid.New(),
+		IdempotencyKey: uuid.New(),
+		BaseCurrency:   types.USD,
+		QuoteCurrency:  types.EUR,
+	}
+
+	assert.NoError(t, db.QuotationRequestCreateOrGetByIdempotencyKey(withCallback))
+	assert.NoError(t, db.QuotationRequestCreateOrGetByIdempotencyKey(withoutCallback))
+
+	assert.NoError(t, db.QuotationRequestCompletePending(types.USD, types.EUR, time.Now(), types.QuotationInfo{Rate: types.MustParseDecimal("1.25"), UpdatedAt: time.Now()}))
+
+	due, err := db.WebhookDeliveryClaimDue(time.Now(), 10, 0)
+	assert.NoError(t, err)
+	assert.Len(t, due, 1)
+	assert.Equal(t, withCallback.Id, due[0].RequestId)
+	assert.Equal(t, url, due[0].Url)
+
+	assert.NoError(t, db.QuotationRequestCompletePending(types.USD, types.EUR, time.Now(), types.QuotationInfo{Rate: types.MustParseDecimal("1.26"), UpdatedAt: time.Now()}))
+
+	due, err = db.WebhookDeliveryClaimDue(time.Now(), 10, 0)
+	assert.NoError(t, err)
+	assert.Len(t, due, 1, "already completed request should not be enqueued again")
+}
+
+func Test_HistoricalRequestsAreImmutable(t *testing.T) {
+	db := newTestDb()
+
+	asOf := time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+	pair := persistence.HistoricalPair{BaseCurrency: types.EUR, QuoteCurrency: types.MXN, AsOf: asOf}
+
+	historical, err := qr.NewHistorical(types.EUR, types.MXN, uuid.New(), asOf)
+	assert.NoError(t, err)
+
+	latest, err := qr.New(types.EUR, types.MXN, uuid.New())
+	assert.NoError(t, err)
+
+	assert.NoError(t, db.QuotationRequestCreateOrGetByIdempotencyKey(&historical))
+	assert.NoError(t, db.QuotationRequestCreateOrGetByIdempotencyKey(&latest))
+
+	keys, err := db.QuotationRequestGetUniqUnhandled()
+	assert.NoError(t, err)
+	assert.Equal(t, [][2]types.Currency{{types.EUR, types.MXN}}, keys)
+
+	historicalKeys, err := db.QuotationRequestGetUniqUnhandledHistorical()
+	assert.NoError(t, err)
+	assert.Equal(t, []persistence.HistoricalPair{pair}, historicalKeys)
+
+	assert.NoError(t, db.QuotationRequestCompleteHistorical(pair, types.QuotationInfo{Rate: types.MustParseDecimal("19.5"), UpdatedAt: asOf}))
+	assert.NoError(t, db.QuotationRequestCompletePending(types.EUR, types.MXN, time.Now(), types.QuotationInfo{Rate: types.MustParseDecimal("21.0"), UpdatedAt: time.Now()}))
+	assert.NoError(t, db.QuotationRequestCompleteHistorical(pair, types.QuotationInfo{Rate: types.MustParseDecimal("20.0"), UpdatedAt: asOf}))
+
+	stored, err := db.QuotationRequestGetById(historical.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, "19.5", stored.Rate.String())
+
+	completed, err := db.QuotationRequestGetCompletedHistorical(pair)
+	assert.NoError(t, err)
+	assert.NotNil(t, completed)
+	assert.Equal(t, historical.Id, completed.Id)
+
+	historicalKeys, err = db.QuotationRequestGetUniqUnhandledHistorical()
+	assert.NoError(t, err)
+	assert.Empty(t, historicalKeys)
+}
+
+func Test_RequestFailsAfterMaxAttempts(t *testing.T) {
+	db := newTestDb()
+
+	request, err := qr.New(types.USD, types.EUR, uuid.New())
+	assert.NoError(t, err)
+	assert.NoError(t, db.QuotationRequestCreateOrGetByIdempotencyKey(&request))
+
+	scope := persistence.PendingScope{BaseCurrency: types.USD, QuoteCurrency: types.EUR, PendingAt: time.Now()}
+	failure := qr.Failure{Code: qr.ErrorRateNotFound, Message: "rate not found for USD/EUR"}
+
+	stored := func() *qr.QuotationRequest {
+		stored, err := db.QuotationRequestGetById(request.Id)
+		assert.NoError(t, err)
+
+		return stored
+	}
+
+	assert.NoError(t, db.QuotationRequestStartAttempt(scope))
+	assert.Equal(t, qr.InProgress, stored().Status)
+
+	assert.NoError(t, db.QuotationRequestFailAttempt(scope, failure, 2))
+	assert.Equal(t, qr.Pending, stored().Status)
+	assert.Equal(t, 1, stored().Attempts)
+	assert.Equal(t, &failure, stored().LastError())
+
+	// попытка засчитывается только InProgress запросам
+	assert.NoError(t, db.QuotationRequestFailAttempt(scope, failure, 2))
+	assert.Equal(t, 1, stored().Attempts)
+
+	assert.NoError(t, db.QuotationRequestStartAttempt(scope))
+	assert.NoError(t, db.QuotationRequestFailAttempt(scope, failure, 2))
+	assert.Equal(t, qr.Failed, stored().Status)
+	assert.Equal(t, 2, stored().Attempts)
+
+	assert.NoError(t, db.QuotationRequestCompletePending(types.USD, types.EUR, time.Now(), types.QuotationInfo{Rate: types.MustParseDecimal("1.25"), UpdatedAt: time.Now()}))
+	assert.Equal(t, qr.Failed, stored().Status)
+	assert.Nil(t, stored().Rate)
+
+	keys, err := db.QuotationRequestGetUniqUnhandled()
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func Test_ExpireOnlyOpenRequests(t *testing.T) {
+	db := newTestDb()
+	start := time.Now().Add(-time.Hour)
+
+	create := func(createdAt time.Time) uuid.UUID {
+		request, err := qr.New(types.USD, types.EUR, uuid.New())
+		assert.NoError(t, err)
+
+		request.CreatedAt = createdAt
+
+		assert.NoError(t, db.QuotationRequestCreateOrGetByIdempotencyKey(&request))
+
+		return request.Id
+	}
+
+	status := func(id uuid.UUID) qr.Status {
+		stored, err := db.QuotationRequestGetById(id)
+		assert.NoError(t, err)
+
+		return stored.Status
+	}
+
+	completed := create(start)
+	assert.NoError(t, db.QuotationRequestCompletePending(types.USD, types.EUR, start, types.QuotationInfo{Rate: types.MustParseDecimal("1.25"), UpdatedAt: start}))
+
+	old := create(start.Add(time.Minute))
+	fresh := create(start.Add(time.Hour))
+
+	expired, err := db.QuotationRequestExpire(start.Add(30 * time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), expired)
+
+	assert.Equal(t, qr.Ready, status(completed))
+	assert.Equal(t, qr.Expired, status(old))
+	assert.Equal(t, qr.Pending, status(fresh))
+
+	stored, err := db.QuotationRequestGetById(old)
+	assert.NoError(t, err)
+	assert.Equal(t, qr.ErrorExpired, stored.LastError().Code)
+}
+
+func Test_ListRetryAndExpireById(t *testing.T) {
+	db := newTestDb()
+	start := time.Now().Add(-time.Hour)
+
+	create := func(base types.Currency, createdAt time.Time) uuid.UUID {
+		request, err := qr.New(base, types.MXN, uuid.New())
+		assert.NoError(t, err)
+
+		request.CreatedAt = createdAt
+
+		assert.NoError(t, db.QuotationRequestCreateOrGetByIdempotencyKey(&request))
+
+		return request.Id
+	}
+
+	ready := create(types.USD, start)
+	assert.NoError(t, db.QuotationRequestCompletePending(types.USD, types.MXN, start, types.QuotationInfo{Rate: types.MustParseDecimal("17.5"), UpdatedAt: start}))
+
+	failed := create(types.EUR, start.Add(time.Minute))
+	pending := create(types.USD, start.Add(2*time.Minute))
+
+	scope := persistence.PendingScope{BaseCurrency: types.EUR, QuoteCurrency: types.MXN, PendingAt: start.Add(time.Minute)}
+	assert.NoError(t, db.QuotationRequestStartAttempt(scope))
+	assert.NoError(t, db.QuotationRequestFailAttempt(scope, qr.Failure{Code: qr.ErrorProvider, Message: "boom"}, 1))
+
+	all, err := db.QuotationRequestList(persistence.QuotationRequestFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{pending, failed, ready}, []uuid.UUID{all[0].Id, all[1].Id, all[2].Id})
+
+	usd, err := db.QuotationRequestList(persistence.QuotationRequestFilter{BaseCurrency: types.USD, Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, usd, 1)
+	assert.Equal(t, pending, usd[0].Id)
+
+	failedOnly, err := db.QuotationRequestList(persistence.QuotationRequestFilter{Status: qr.Failed})
+	assert.NoError(t, err)
+	assert.Len(t, failedOnly, 1)
+	assert.Equal(t, failed, failedOnly[0].Id)
+
+	retried, err := db.QuotationRequestRetry(failed)
+	assert.NoError(t, err)
+	assert.True(t, retried)
+
+	stored, err := db.QuotationRequestGetById(failed)
+	assert.NoError(t, err)
+	assert.Equal(t, qr.Pending, stored.Status)
+	assert.Equal(t, 0, stored.Attempts)
+	assert.Nil(t, stored.LastError())
+	assert.Equal(t, start.Add(time.Minute), stored.CreatedAt)
+	assert.NotNil(t, stored.AttemptStartedAt)
+
+	retried, err = db.QuotationRequestRetry(ready)
+	assert.NoError(t, err)
+	assert.False(t, retried)
+
+	expired, err := db.QuotationRequestExpireById(pending, "expired manually")
+	assert.NoError(t, err)
+	assert.True(t, expired)
+
+	expired, err = db.QuotationRequestExpireById(ready, "expired manually")
+	assert.NoError(t, err)
+	assert.False(t, expired)
+
+	stored, err = db.QuotationRequestGetById(pending)
+	assert.NoError(t, err)
+	assert.Equal(t, qr.Expired, stored.Status)
+	assert.Equal(t, "expired manually", stored.LastError().Message)
+}
+
+func Test_RetryRestartsTtl(t *testing.T) {
+	db := newTestDb()
+	createdAt := time.Now().Add(-time.Hour)
+
+	request, err := qr.New(types.USD, types.EUR, uuid.New())
+	assert.NoError(t, err)
+
+	request.CreatedAt = createdAt
+
+	assert.NoError(t, db.QuotationRequestCreateOrGetByIdempotencyKey(&request))
+
+	expired, err := db.QuotationRequestExpire(time.Now().Add(-30 * time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), expired)
+
+	retried, err := db.QuotationRequestRetry(request.Id)
+	assert.NoError(t, err)
+	assert.True(t, retried)
+
+	// ttl отсчитывается от retry, а не от создания
+	expired, err = db.QuotationRequestExpire(time.Now().Add(-30 * time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), expired)
+
+	stored, err := db.QuotationRequestGetById(request.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, qr.Pending, stored.Status)
+	assert.Equal(t, createdAt, stored.CreatedAt)
+	assert.Nil(t, stored.ErrorCode)
+	assert.Nil(t, stored.ErrorMessage)
+
+	expired, err = db.QuotationRequestExpire(time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), expired)
+}
+
+func Test_PurgeFinishedRequests(t *testing.T) {
+	db := newTestDb()
+	now := time.Now()
+	old, fresh := now.Add(-48*time.Hour), now.Add(-time.Hour)
+
+	newRequest := func(base types.Currency, quote types.Currency, status qr.Status, createdAt time.Time) qr.QuotationRequest {
+		request, err := qr.New(base, quote, uuid.New())
+		assert.NoError(t, err)
+
+		request.Status = status
+		request.CreatedAt = createdAt
+
+		if status == qr.Ready {
+			rate := types.MustParseDecimal("1.1")
+			request.Rate, request.CompletedAt = &rate, &createdAt
+		}
+
+		return request
+	}
+
+	create := func(request qr.QuotationRequest) uuid.UUID {
+		assert.NoError(t, db.QuotationRequestCreateOrGetByIdempotencyKey(&request))
+
+		return request.Id
+	}
+
+	createBatch := func(requests ...qr.QuotationRequest) uuid.UUID {
+		batch := qb.New(uuid.New())
+
+		for _, request := range requests {
+			assert.NoError(t, batch.Add(request))
+		}
+
+		assert.NoError(t, db.QuotationBatchCreateOrGetByIdempotencyKey(&batch))
+
+		return batch.Id
+	}
+
+	oldReady := create(newRequest(types.USD, types.EUR, qr.Ready, old))
+	oldFailed := create(newRequest(types.USD, types.MXN, qr.Failed, old.Add(time.Minute)))
+	oldPending := create(newRequest(types.EUR, types.MXN, qr.Pending, old))
+	freshReady := create(newRequest(types.EUR, types.USD, qr.Ready, fresh))
+
+	// вебхук еще не доставлен
+	withWebhook := newRequest(types.MXN, types.USD, qr.Ready, old)
+	url, secret := "http://localhost/callback", "secret"
+	withWebhook.CallbackUrl, withWebhook.CallbackSecret = &url, &secret
+	create(withWebhook)
+
+	finishedBatch := createBatch(newRequest(types.USD, types.EUR, qr.Ready, old), newRequest(types.EUR, types.USD, qr.Expired, old))
+	openBatch := createBatch(newRequest(types.USD, types.EUR, qr.Ready, old), newRequest(types.EUR, types.USD, qr.Pending, old))
+
+	cutoff := now.Add(-24 * time.Hour)
+
+	_, err := db.QuotationRequestPurge(cutoff, 10, func([]qr.QuotationRequest) error {
+		return errors.New("disk is full")
+	})
+	assert.Error(t, err)
+	assert.Len(t, db.store, 9)
+
+	var archived []uuid.UUID
+
+	archive := func(requests []qr.QuotationRequest) error {
+		for _, request := range requests {
+			archived = append(archived, request.Id)
+		}
+
+		return nil
+	}
+
+	first, err := db.QuotationRequestPurge(cutoff, 3, archive)
+	assert.NoError(t, err)
+	assert.Len(t, first, 3)
+
+	second, err := db.QuotationRequestPurge(cutoff, 3, archive)
+	assert.NoError(t, err)
+	assert.Len(t, second, 1)
+
+	last, err := db.QuotationRequestPurge(cutoff, 3, archive)
+	assert.NoError(t, err)
+	assert.Empty(t, last)
+
+	assert.Len(t, archived, 4)
+	assert.Contains(t, archived, oldReady)
+	assert.Contains(t, archived, oldFailed)
+
+	for _, id := range []uuid.UUID{oldReady, oldFailed} {
+		stored, err := db.QuotationRequestGetById(id)
+		assert.NoError(t, err)
+		assert.Nil(t, stored)
+	}
+
+	for _, id := range []uuid.UUID{oldPending, freshReady, withWebhook.Id} {
+		stored, err := db.QuotationRequestGetById(id)
+		assert.NoError(t, err)
+		assert.NotNil(t, stored)
+	}
+
+	removed, err := db.QuotationBatchGetById(finishedBatch)
+	assert.NoError(t, err)
+	assert.Nil(t, removed)
+
+	kept, err := db.QuotationBatchGetById(openBatch)
+	assert.NoError(t, err)
+	assert.Len(t, kept.Requests, 2)
+}
+
+func Test_PurgeKeepsBatchWithPendingWebhook(t *testing.T) {
+	db := newTestDb()
+	old := time.Now().Add(-48 * time.Hour)
+	batch := qb.New(uuid.New())
+
+	for _, quote := range []types.Currency{types.EUR, types.MXN} {
+		request, err := qr.New(types.USD, quote, uuid.New())
+		assert.NoError(t, err)
+
+		request.Status, request.CreatedAt = qr.Failed, old
+		assert.NoError(t, batch.Add(request))
+	}
+
+	assert.NoError(t, db.QuotationBatchCreateOrGetByIdempotencyKey(&batch))
+
+	// вебхук одного запроса пачки еще не доставлен
+	withWebhook := db.store[len(db.store)-1]
+	url, secret := "http://localhost/callback", "secret"
+	withWebhook.CallbackUrl, withWebhook.CallbackSecret = &url, &secret
+	db.enqueueWebhook(withWebhook, old)
+
+	purged, err := db.QuotationRequestPurge(time.Now().Add(-24*time.Hour), 10, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, purged)
+
+	kept, err := db.QuotationBatchGetById(batch.Id)
+	assert.NoError(t, err)
+	assert.Len(t, kept.Requests, 2)
+
+	db.deliveries[0].Status = wd.Delivered
+
+	purged, err = db.QuotationRequestPurge(time.Now().Add(-24*time.Hour), 10, nil)
+	assert.NoError(t, err)
+	assert.Len(t, purged, 2)
+}
+
+func Test_BatchCreateIdempotent(t *testing.T) {
+	db := newTestDb()
+	key := uuid.New()
+
+	newBatch := func(pairs ...[2]types.Currency) qb.QuotationBatch {
+		batch := qb.New(key)
+
+		for _, pair := range pairs {
+			request, err := qr.New(pair[0], pair[1], qb.RequestIdempotencyKey(key, pair[0], pair[1]))
+			assert.NoError(t, err)
+			assert.NoError(t, batch.Add(request))
+		}
+
+		return batch
+	}
+
+	first := newBatch([2]types.Currency{types.USD, types.MXN}, [2]types.Currency{types.EUR, types.MXN})
+	assert.NoError(t, db.QuotationBatchCreateOrGetByIdempotencyKey(&first))
+
+	second := newBatch([2]types.Currency{types.USD, types.EUR})
+	assert.NoError(t, db.QuotationBatchCreateOrGetByIdempotencyKey(&second))
+
+	assert.Equal(t, first.Id, second.Id)
+	assert.Len(t, second.Requests, 2)
+	assert.Len(t, db.store, 2)
+
+	found, err := db.QuotationBatchGetById(first.Id)
+
+	assert.NoError(t, err)
+	assert.Equal(t, types.EUR, found.Requests[0].BaseCurrency)
+	assert.Equal(t, types.USD, found.Requests[1].BaseCurrency)
+	assert.Equal(t, first.Id, *found.Requests[0].BatchId)
+
+	notFound, err := db.QuotationBatchGetById(uuid.New())
+
+	assert.NoError(t, err)
+	assert.Nil(t, notFound)
+
+	duplicate := qb.New(uuid.New())
+	request, _ := qr.New(types.USD, types.MXN, uuid.New())
+
+	assert.NoError(t, duplicate.Add(request))
+	assert.ErrorIs(t, duplicate.Add(request), qb.ErrDuplicatePair)
+}
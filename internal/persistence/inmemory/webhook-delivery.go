@@ -0,0 +1,70 @@
+package inmemory
+
+import (
+	wd "plata_currency_quotation/internal/domain/enity/webhook-delivery"
+	"sort"
+	"time"
+)
+
+func (d *Db) WebhookDeliveryClaimDue(now time.Time, limit int, lease time.Duration) ([]wd.WebhookDelivery, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	due := make([]*wd.WebhookDelivery, 0)
+
+	for _, delivery := range d.deliveries {
+		if delivery.Status == wd.Pending && !delivery.NextAttemptAt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	result := make([]wd.WebhookDelivery, 0, len(due))
+
+	for _, delivery := range due {
+		delivery.NextAttemptAt = now.Add(lease)
+
+		result = append(result, cloneDelivery(delivery))
+	}
+
+	return result, nil
+}
+
+func (d *Db) WebhookDeliveryUpdate(delivery *wd.WebhookDelivery) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for i, existing := range d.deliveries {
+		if existing.Id == delivery.Id {
+			clone := cloneDelivery(delivery)
+			d.deliveries[i] = &clone
+
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func cloneDelivery(src *wd.WebhookDelivery) wd.WebhookDelivery {
+	dst := *src
+
+	if src.LastError != nil {
+		e := *src.LastError
+		dst.LastError = &e
+	}
+
+	if src.DeliveredAt != nil {
+		t := *src.DeliveredAt
+		dst.DeliveredAt = &t
+	}
+
+	return dst
+}
@@ -0,0 +1,13 @@
+package persistence
+
+import (
+	"context"
+	"time"
+)
+
+type LeasePersistentOperations interface {
+	// LeaseAcquire захватывает или продлевает аренду на ttl, если она свободна, истекла или уже у holder.
+	// Время берется из бд, чтобы расхождение часов экземпляров не давало двух держателей
+	LeaseAcquire(ctx context.Context, name string, holder string, ttl time.Duration) (bool, error)
+	LeaseRelease(name string, holder string) error
+}
@@ -4,9 +4,15 @@ var Instance Interface
 
 type CommonPersistenceOperations interface {
 	OnStart() error
+	// Close закрывает соединения с бд, вызывается последним при остановке сервиса
+	Close() error
 }
 
 type Interface interface {
 	CommonPersistenceOperations
 	QuotationRequestPersistentOperations
+	QuotationBatchPersistentOperations
+	WebhookDeliveryPersistentOperations
+	QuotationHistoryPersistentOperations
+	LeasePersistentOperations
 }
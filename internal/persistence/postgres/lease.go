@@ -0,0 +1,26 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func (d *Db) LeaseAcquire(ctx context.Context, name string, holder string, ttl time.Duration) (bool, error) {
+	result := d.inner.WithContext(ctx).Exec(
+		`INSERT INTO leases (name, holder, expires_at) VALUES (?, ?, now() + ?::interval)
+		ON CONFLICT (name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+		WHERE leases.holder = excluded.holder OR leases.expires_at < now()`,
+		name, holder, fmt.Sprintf("%d milliseconds", ttl.Milliseconds()),
+	)
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (d *Db) LeaseRelease(name string, holder string) error {
+	return d.inner.Exec("DELETE FROM leases WHERE name = ? AND holder = ?", name, holder).Error
+}
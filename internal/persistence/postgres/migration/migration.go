@@ -0,0 +1,357 @@
+package migration
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"plata_currency_quotation/internal/lib/logger/sl"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+)
+
+//go:embed sql/*.sql
+var scripts embed.FS
+
+// lockKey - ключ pg_advisory_lock, под которым мигрирует только один экземпляр
+const lockKey int64 = 0x706c617461
+
+var ErrUnknownVersion = errors.New("unknown migration version")
+
+var ErrSchemaOutdated = errors.New("database schema is outdated")
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration - скрипты sql/NNNN_name.up.sql и sql/NNNN_name.down.sql. Каждый скрипт выполняется в своей транзакции
+// вместе с записью в schema_migrations, поэтому CREATE INDEX CONCURRENTLY и подобное в них не работает
+type Migration struct {
+	Version int64
+	Name    string
+	up      string
+	down    string
+}
+
+// State - миграция и когда она применена, nil - не применена
+type State struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	logger     *slog.Logger
+}
+
+func New(db *sql.DB) (*Migrator, error) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})).With(
+		"component", "persistence/postgres/migration",
+	)
+
+	migrations, err := load(scripts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, migrations: migrations, logger: logger}, nil
+}
+
+// load - версии по возрастанию, у каждой должны быть оба скрипта
+func load(fsys fs.FS) ([]Migration, error) {
+	paths, err := fs.Glob(fsys, "sql/*.sql")
+
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+
+	for _, path := range paths {
+		match := fileName.FindStringSubmatch(path[len("sql/"):])
+
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", path)
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %q", path)
+		}
+
+		content, err := fs.ReadFile(fsys, path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		migration, exists := byVersion[version]
+
+		if !exists {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has different names %q and %q", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.up = string(content)
+		} else {
+			migration.down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+
+	for _, migration := range byVersion {
+		if migration.up == "" || migration.down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down scripts", migration.Version, migration.Name)
+		}
+
+		migrations = append(migrations, *migration)
+	}
+
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+
+	return migrations, nil
+}
+
+// Latest - версия последней известной миграции
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]State, error) {
+	applied, err := m.applied(ctx, m.db)
+
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]State, 0, len(m.migrations))
+
+	for _, migration := range m.migrations {
+		state := State{Migration: migration}
+
+		if at, exists := applied[migration.Version]; exists {
+			state.AppliedAt = &at
+		}
+
+		states = append(states, state)
+	}
+
+	return states, nil
+}
+
+// Version - последняя примененная миграция, 0 - ни одной
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	applied, err := m.applied(ctx, m.db)
+
+	if err != nil {
+		return 0, err
+	}
+
+	var version int64
+
+	for v := range applied {
+		version = max(version, v)
+	}
+
+	return version, nil
+}
+
+// EnsureLatest - ошибка ErrSchemaOutdated, если есть непримененные миграции
+func (m *Migrator) EnsureLatest(ctx context.Context) error {
+	version, err := m.Version(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if version < m.Latest() {
+		return fmt.Errorf("%w: version %d, latest %d", ErrSchemaOutdated, version, m.Latest())
+	}
+
+	return nil
+}
+
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, m.Latest())
+}
+
+// Down откатывает последнюю примененную миграцию
+func (m *Migrator) Down(ctx context.Context) error {
+	version, err := m.Version(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	var target int64
+
+	for _, migration := range m.migrations {
+		if migration.Version < version {
+			target = migration.Version
+		}
+	}
+
+	return m.To(ctx, target)
+}
+
+// To применяет миграции до version включительно и откатывает все, что новее. 0 - откатить все
+func (m *Migrator) To(ctx context.Context, version int64) error {
+	if version != 0 && !slices.ContainsFunc(m.migrations, func(migration Migration) bool { return migration.Version == version }) {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	conn, err := m.db.Conn(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := conn.Close(); err != nil {
+			m.logger.Error("failed to close migration connection", sl.Err(err))
+		}
+	}()
+
+	// advisory lock живет в сессии, поэтому все миграции идут через одно соединение
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+
+	defer func() {
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", lockKey); err != nil {
+			m.logger.Error("failed to release migration lock", sl.Err(err))
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    bigint       PRIMARY KEY,
+		name       varchar(128) NOT NULL,
+		applied_at timestamp    NOT NULL
+	)`)
+
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	// пока ждали блокировку, другой экземпляр мог уже все применить
+	applied, err := m.applied(ctx, conn)
+
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range m.migrations {
+		if _, exists := applied[migration.Version]; exists || migration.Version > version {
+			continue
+		}
+
+		err := m.run(ctx, conn, migration.up, "INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, now())", migration.Version, migration.Name)
+
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+
+		m.logger.Info("migration applied", slog.Int64("version", migration.Version), slog.String("name", migration.Name))
+	}
+
+	for _, migration := range slices.Backward(m.migrations) {
+		if _, exists := applied[migration.Version]; !exists || migration.Version <= version {
+			continue
+		}
+
+		err := m.run(ctx, conn, migration.down, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+
+		if err != nil {
+			return fmt.Errorf("failed to revert migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+
+		m.logger.Info("migration reverted", slog.Int64("version", migration.Version), slog.String("name", migration.Name))
+	}
+
+	return nil
+}
+
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, script string, record string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	// без аргументов pgx выполняет скрипт простым протоколом, несколько команд за раз
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	return tx.Commit()
+}
+
+// querier - *sql.DB или *sql.Conn
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// applied - версия -> время применения. До первой миграции таблицы нет, это не ошибка
+func (m *Migrator) applied(ctx context.Context, db querier) (map[int64]time.Time, error) {
+	result := make(map[int64]time.Time)
+
+	var exists bool
+
+	if err := db.QueryRowContext(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists); err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return result, nil
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.logger.Error("failed to close rows", sl.Err(err))
+		}
+	}()
+
+	for rows.Next() {
+		var version int64
+		var at time.Time
+
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+
+		result[version] = at
+	}
+
+	return result, rows.Err()
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS leases;
+DROP TABLE IF EXISTS quotation_history;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS quotation_requests;
+DROP TABLE IF EXISTS quotation_batches;
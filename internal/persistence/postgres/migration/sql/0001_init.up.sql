@@ -0,0 +1,63 @@
+-- схема, которую создавал gorm AutoMigrate. IF NOT EXISTS, чтобы бд, созданные до миграций, приняли эту версию как есть
+CREATE TABLE IF NOT EXISTS quotation_batches (
+    id              uuid      PRIMARY KEY,
+    idempotency_key uuid      NOT NULL,
+    created_at      timestamp NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_quotation_batches_idempotency_key ON quotation_batches (idempotency_key);
+
+CREATE TABLE IF NOT EXISTS quotation_requests (
+    id              uuid        PRIMARY KEY,
+    idempotency_key uuid        NOT NULL,
+    created_at      timestamp   NOT NULL,
+    base_currency   varchar(3)  NOT NULL,
+    quote_currency  varchar(3)  NOT NULL,
+    completed_at    timestamp,
+    rate            numeric,
+    callback_url    text,
+    callback_secret text,
+    as_of           date,
+    provider        varchar(32),
+    detail          jsonb,
+    batch_id        uuid,
+    CONSTRAINT fk_quotation_batches_requests FOREIGN KEY (batch_id) REFERENCES quotation_batches (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_quotation_requests_idempotency_key ON quotation_requests (idempotency_key);
+CREATE INDEX IF NOT EXISTS idx_quotation_requests_batch_id ON quotation_requests (batch_id);
+CREATE INDEX IF NOT EXISTS idx_quotation_requests_pending ON quotation_requests (base_currency, quote_currency, created_at)
+    WHERE completed_at IS NULL AND as_of IS NULL;
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id              uuid        PRIMARY KEY,
+    request_id      uuid        NOT NULL,
+    url             text        NOT NULL,
+    secret          text        NOT NULL,
+    status          varchar(16) NOT NULL,
+    attempts        bigint      NOT NULL DEFAULT 0,
+    next_attempt_at timestamp   NOT NULL,
+    last_error      text,
+    created_at      timestamp   NOT NULL,
+    delivered_at    timestamp
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_request_id ON webhook_deliveries (request_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);
+
+CREATE TABLE IF NOT EXISTS quotation_history (
+    id             uuid        PRIMARY KEY,
+    base_currency  varchar(3)  NOT NULL,
+    quote_currency varchar(3)  NOT NULL,
+    rate           numeric     NOT NULL,
+    fetched_at     timestamp   NOT NULL,
+    provider       varchar(32) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_quotation_history_pair_time ON quotation_history (base_currency, quote_currency, fetched_at);
+
+CREATE TABLE IF NOT EXISTS leases (
+    name       varchar(64)  PRIMARY KEY,
+    holder     varchar(128) NOT NULL,
+    expires_at timestamp    NOT NULL
+);
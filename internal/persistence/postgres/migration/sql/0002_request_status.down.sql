@@ -0,0 +1,9 @@
+-- Failed и Expired запросы снова станут незавершенными и будут запрошены у провайдера повторно
+DROP INDEX IF EXISTS idx_quotation_requests_open;
+CREATE INDEX IF NOT EXISTS idx_quotation_requests_pending ON quotation_requests (base_currency, quote_currency, created_at)
+    WHERE completed_at IS NULL AND as_of IS NULL;
+
+ALTER TABLE quotation_requests DROP COLUMN IF EXISTS error_message;
+ALTER TABLE quotation_requests DROP COLUMN IF EXISTS error_code;
+ALTER TABLE quotation_requests DROP COLUMN IF EXISTS attempts;
+ALTER TABLE quotation_requests DROP COLUMN IF EXISTS status;
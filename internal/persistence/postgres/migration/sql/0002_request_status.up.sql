@@ -0,0 +1,11 @@
+ALTER TABLE quotation_requests ADD COLUMN IF NOT EXISTS status varchar(16) NOT NULL DEFAULT 'Pending';
+ALTER TABLE quotation_requests ADD COLUMN IF NOT EXISTS attempts bigint NOT NULL DEFAULT 0;
+ALTER TABLE quotation_requests ADD COLUMN IF NOT EXISTS error_code varchar(32);
+ALTER TABLE quotation_requests ADD COLUMN IF NOT EXISTS error_message text;
+
+-- до появления status завершенность определялась по completed_at
+UPDATE quotation_requests SET status = 'Ready' WHERE completed_at IS NOT NULL AND status = 'Pending';
+
+DROP INDEX IF EXISTS idx_quotation_requests_pending;
+CREATE INDEX IF NOT EXISTS idx_quotation_requests_open ON quotation_requests (base_currency, quote_currency, created_at)
+    WHERE as_of IS NULL AND (status = 'Pending' OR status = 'InProgress');
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_quotation_requests_finished;
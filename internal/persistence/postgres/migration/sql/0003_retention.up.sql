@@ -0,0 +1,3 @@
+-- по нему QuotationRequestPurge выбирает старые запросы в конечном статусе
+CREATE INDEX IF NOT EXISTS idx_quotation_requests_finished ON quotation_requests (created_at)
+    WHERE status IN ('Ready', 'Failed', 'Expired');
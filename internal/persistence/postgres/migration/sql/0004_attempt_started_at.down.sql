@@ -0,0 +1 @@
+ALTER TABLE quotation_requests DROP COLUMN IF EXISTS attempt_started_at;
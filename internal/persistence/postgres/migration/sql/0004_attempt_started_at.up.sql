@@ -0,0 +1,2 @@
+-- ручной retry перезапускает отсчет ttl, created_at при этом остается временем создания запроса
+ALTER TABLE quotation_requests ADD COLUMN IF NOT EXISTS attempt_started_at timestamp;
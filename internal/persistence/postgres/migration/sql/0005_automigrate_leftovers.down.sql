@@ -0,0 +1 @@
+-- откатывать нечего: после up схема совпадает с 0001, а старые типы и отсутствующие колонки возвращать незачем
@@ -0,0 +1,18 @@
+-- 0001 принимает бд, созданные AutoMigrate, как есть, а они могут быть на промежуточной схеме: без части колонок,
+-- с rate text и без внешнего ключа на пачку. Доводим их до схемы 0001, на остальных бд все это ничего не делает
+ALTER TABLE quotation_requests ADD COLUMN IF NOT EXISTS callback_url text;
+ALTER TABLE quotation_requests ADD COLUMN IF NOT EXISTS callback_secret text;
+ALTER TABLE quotation_requests ADD COLUMN IF NOT EXISTS provider varchar(32);
+ALTER TABLE quotation_requests ADD COLUMN IF NOT EXISTS detail jsonb;
+ALTER TABLE quotation_requests ALTER COLUMN rate TYPE numeric USING rate::numeric;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_quotation_batches_requests') THEN
+        ALTER TABLE quotation_requests
+            ADD CONSTRAINT fk_quotation_batches_requests FOREIGN KEY (batch_id) REFERENCES quotation_batches (id);
+    END IF;
+END $$;
+
+ALTER TABLE quotation_history ADD COLUMN IF NOT EXISTS provider varchar(32) NOT NULL DEFAULT '';
+ALTER TABLE quotation_history ALTER COLUMN rate TYPE numeric USING rate::numeric;
@@ -0,0 +1,256 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_EmbeddedMigrations(t *testing.T) {
+	migrations, err := load(scripts)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, migrations)
+
+	// версии идут подряд, пропуск обычно значит потерянный файл
+	for i, migration := range migrations {
+		assert.Equal(t, int64(i+1), migration.Version)
+	}
+}
+
+func Test_LoadSortsByVersion(t *testing.T) {
+	migrations, err := load(fstest.MapFS{
+		"sql/0010_second.up.sql":   {Data: []byte("SELECT 10")},
+		"sql/0010_second.down.sql": {Data: []byte("SELECT -10")},
+		"sql/0002_first.up.sql":    {Data: []byte("SELECT 2")},
+		"sql/0002_first.down.sql":  {Data: []byte("SELECT -2")},
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, migrations, 2)
+	assert.Equal(t, int64(2), migrations[0].Version)
+	assert.Equal(t, "first", migrations[0].Name)
+	assert.Equal(t, "SELECT 2", migrations[0].up)
+	assert.Equal(t, "SELECT -2", migrations[0].down)
+	assert.Equal(t, int64(10), migrations[1].Version)
+}
+
+func Test_LoadRejectsInvalidFiles(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"missing down": {
+			"sql/0001_init.up.sql": {Data: []byte("SELECT 1")},
+		},
+		"invalid name": {
+			"sql/init.up.sql":   {Data: []byte("SELECT 1")},
+			"sql/init.down.sql": {Data: []byte("SELECT 1")},
+		},
+		"different names": {
+			"sql/0001_init.up.sql":    {Data: []byte("SELECT 1")},
+			"sql/0001_other.down.sql": {Data: []byte("SELECT 1")},
+		},
+		"zero version": {
+			"sql/0000_init.up.sql":   {Data: []byte("SELECT 1")},
+			"sql/0000_init.down.sql": {Data: []byte("SELECT 1")},
+		},
+	}
+
+	for name, fsys := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := load(fsys)
+			assert.Error(t, err)
+		})
+	}
+}
+
+// testDb - настоящая бд из TEST_POSTGRES_DSN, схема в ней пересоздается. Это интеграционные тесты: без переменной
+// они пропускаются, в CI переменная указывает на postgres из service container
+func testDb(t *testing.T) *sql.DB {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+
+	if dsn == "" {
+		t.Skip("integration test: TEST_POSTGRES_DSN is not set")
+	}
+
+	db, err := sql.Open("pgx", dsn)
+
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS schema_migrations, leases, quotation_history, webhook_deliveries,
+		quotation_requests, quotation_batches CASCADE`)
+
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return db
+}
+
+// baselineSchema - промежуточная схема AutoMigrate: колонки, на которые опираются индексы 0001, уже есть, а rate
+// еще text, без части колонок и без внешнего ключа на пачку
+const baselineSchema = `
+CREATE TABLE quotation_requests (
+    id              uuid       PRIMARY KEY,
+    idempotency_key uuid       NOT NULL,
+    created_at      timestamp  NOT NULL,
+    base_currency   varchar(3) NOT NULL,
+    quote_currency  varchar(3) NOT NULL,
+    completed_at    timestamp,
+    rate            text,
+    as_of           date,
+    batch_id        uuid
+);
+
+CREATE UNIQUE INDEX idx_quotation_requests_idempotency_key ON quotation_requests (idempotency_key);
+
+CREATE TABLE quotation_history (
+    id             uuid       PRIMARY KEY,
+    base_currency  varchar(3) NOT NULL,
+    quote_currency varchar(3) NOT NULL,
+    rate           text       NOT NULL,
+    fetched_at     timestamp  NOT NULL
+);
+
+INSERT INTO quotation_requests VALUES
+    (gen_random_uuid(), gen_random_uuid(), now(), 'USD', 'MXN', now(), '20.10', null, null),
+    (gen_random_uuid(), gen_random_uuid(), now(), 'USD', 'EUR', null, null, null, null);
+
+INSERT INTO quotation_history VALUES (gen_random_uuid(), 'USD', 'MXN', '20.10', now());
+`
+
+func Test_PostgresMigratesBaselineSchema(t *testing.T) {
+	db := testDb(t)
+	ctx := context.Background()
+
+	_, err := db.ExecContext(ctx, baselineSchema)
+	assert.NoError(t, err)
+
+	migrator, err := New(db)
+	assert.NoError(t, err)
+
+	assert.NoError(t, migrator.Up(ctx))
+	assertVersion(t, migrator, migrator.Latest())
+
+	// колонки всей серии на месте, старые данные пережили смену типа rate
+	for _, column := range []string{
+		"callback_url", "callback_secret", "as_of", "provider", "detail", "batch_id",
+		"status", "attempts", "error_code", "error_message", "attempt_started_at",
+	} {
+		var exists bool
+
+		err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM information_schema.columns
+			WHERE table_name = 'quotation_requests' AND column_name = $1)`, column).Scan(&exists)
+
+		assert.NoError(t, err)
+		assert.True(t, exists, column)
+	}
+
+	var rate, status, rateType string
+
+	err = db.QueryRowContext(ctx, `SELECT rate::text, status, pg_typeof(rate)::text FROM quotation_requests
+		WHERE completed_at IS NOT NULL`).Scan(&rate, &status, &rateType)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "20.10", rate)
+	assert.Equal(t, "Ready", status)
+	assert.Equal(t, "numeric", rateType)
+
+	var provider string
+
+	err = db.QueryRowContext(ctx, "SELECT provider, pg_typeof(rate)::text FROM quotation_history").Scan(&provider, &rateType)
+
+	assert.NoError(t, err)
+	assert.Empty(t, provider)
+	assert.Equal(t, "numeric", rateType)
+
+	var foreignKey bool
+
+	err = db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_quotation_batches_requests')").
+		Scan(&foreignKey)
+
+	assert.NoError(t, err)
+	assert.True(t, foreignKey)
+
+	assert.NoError(t, migrator.Down(ctx))
+	assertVersion(t, migrator, migrator.Latest()-1)
+
+	assert.NoError(t, migrator.To(ctx, 1))
+	assertVersion(t, migrator, 1)
+
+	assert.NoError(t, migrator.Up(ctx))
+	assertVersion(t, migrator, migrator.Latest())
+
+	assert.NoError(t, migrator.To(ctx, 0))
+	assertVersion(t, migrator, 0)
+
+	var exists bool
+
+	assert.NoError(t, db.QueryRowContext(ctx, "SELECT to_regclass('quotation_requests') IS NOT NULL").Scan(&exists))
+	assert.False(t, exists)
+}
+
+func Test_PostgresMigrationLock(t *testing.T) {
+	db := testDb(t)
+	ctx := context.Background()
+
+	migrator, err := New(db)
+	assert.NoError(t, err)
+
+	conn, err := db.Conn(ctx)
+	assert.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey)
+	assert.NoError(t, err)
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- migrator.Up(ctx)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("migrated while the lock is held by another session")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	_, err = conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockKey)
+	assert.NoError(t, err)
+
+	assert.NoError(t, <-done)
+	assertVersion(t, migrator, migrator.Latest())
+
+	// экземпляры, которые ждали блокировку, видят примененные миграции и ничего не делают
+	assert.NoError(t, migrator.To(ctx, 0))
+
+	var wg sync.WaitGroup
+
+	for range 3 {
+		wg.Go(func() {
+			assert.NoError(t, migrator.Up(ctx))
+		})
+	}
+
+	wg.Wait()
+
+	assertVersion(t, migrator, migrator.Latest())
+}
+
+func assertVersion(t *testing.T, migrator *Migrator, expected int64) {
+	t.Helper()
+
+	version, err := migrator.Version(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, version)
+}
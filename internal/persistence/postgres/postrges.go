@@ -1,11 +1,11 @@
 package postgres
 
 import (
-	"fmt"
+	"context"
 	"log"
-	qr "plata_currency_quotation/internal/domain/enity/quotation-request"
 	"plata_currency_quotation/internal/lib/config"
 	"plata_currency_quotation/internal/lib/logger/sl"
+	"plata_currency_quotation/internal/persistence/postgres/migration"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -15,38 +15,58 @@ type Db struct {
 	inner *gorm.DB
 }
 
+// OnStart применяет миграции, если включен DB_AUTO_MIGRATE, иначе только проверяет, что схема не отстает
 func (d *Db) OnStart() error {
-	if err := d.inner.AutoMigrate(&qr.QuotationRequest{}); err != nil {
+	db, err := d.inner.DB()
+
+	if err != nil {
+		return err
+	}
+
+	migrator, err := migration.New(db)
+
+	if err != nil {
 		return err
 	}
 
-	return nil
+	if config.Instance.DbAutoMigrate {
+		return migrator.Up(context.Background())
+	}
+
+	return migrator.EnsureLatest(context.Background())
 }
 
-func New() *Db {
-	dsn := fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%d sslmode=%s TimeZone=UTC",
-		config.Instance.DbHost,
-		config.Instance.DbUser,
-		config.Instance.DbPassword,
-		config.Instance.DbName,
-		config.Instance.DbPort,
-		config.Instance.DbSslMode,
-	)
-
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+func (d *Db) Close() error {
+	db, err := d.inner.DB()
 
 	if err != nil {
-		log.Fatal("failed to init postgres db", sl.Err(err))
+		return err
 	}
 
-	var s = Db{
-		inner: db,
+	return db.Close()
+}
+
+func New() *Db {
+	s, err := Open()
+
+	if err != nil {
+		log.Fatal("failed to init postgres db", sl.Err(err))
 	}
 
 	if err := s.OnStart(); err != nil {
 		log.Fatal("failed postgres OnStart", sl.Err(err))
 	}
 
-	return &s
+	return s
+}
+
+// Open подключается без OnStart: для подкоманд, которые не должны мигрировать схему
+func Open() (*Db, error) {
+	db, err := gorm.Open(postgres.Open(config.Instance.PostgresDsn()), &gorm.Config{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Db{inner: db}, nil
 }
@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"errors"
+	qb "plata_currency_quotation/internal/domain/enity/quotation-batch"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (d *Db) QuotationBatchCreateOrGetByIdempotencyKey(batch *qb.QuotationBatch) error {
+	return d.inner.Transaction(func(tx *gorm.DB) error {
+		result := tx.Omit(clause.Associations).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "idempotency_key"}},
+			DoNothing: true,
+		}).Create(batch)
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			key := batch.IdempotencyKey
+			*batch = qb.QuotationBatch{}
+
+			return withRequests(tx).First(batch, "idempotency_key = ?", key).Error
+		}
+
+		return tx.Create(&batch.Requests).Error
+	})
+}
+
+func (d *Db) QuotationBatchGetById(id uuid.UUID) (*qb.QuotationBatch, error) {
+	var batch qb.QuotationBatch
+
+	if err := withRequests(d.inner).First(&batch, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &batch, nil
+}
+
+func withRequests(tx *gorm.DB) *gorm.DB {
+	return tx.Preload("Requests", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("base_currency, quote_currency")
+	})
+}
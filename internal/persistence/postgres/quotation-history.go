@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	qh "plata_currency_quotation/internal/domain/enity/quotation-history"
+	"plata_currency_quotation/internal/domain/types"
+	"plata_currency_quotation/internal/persistence"
+	"time"
+)
+
+func (d *Db) QuotationHistoryAppend(records []qh.QuotationHistory) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	return d.inner.Create(&records).Error
+}
+
+func (d *Db) QuotationHistoryGetLatest() ([]persistence.PairQuotation, error) {
+	var records []qh.QuotationHistory
+
+	err := d.inner.
+		Raw(`SELECT DISTINCT ON (base_currency, quote_currency) * FROM quotation_history
+			ORDER BY base_currency, quote_currency, fetched_at DESC`).
+		Scan(&records).
+		Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]persistence.PairQuotation, 0, len(records))
+
+	for _, record := range records {
+		result = append(result, persistence.PairQuotation{
+			BaseCurrency:  record.BaseCurrency,
+			QuoteCurrency: record.QuoteCurrency,
+			QuotationInfo: types.QuotationInfo{Rate: record.Rate, UpdatedAt: record.FetchedAt, Provider: record.Provider},
+		})
+	}
+
+	return result, nil
+}
+
+func (d *Db) QuotationHistoryGetRange(baseCurrency types.Currency, quoteCurrency types.Currency, from time.Time, to time.Time, limit int) ([]qh.QuotationHistory, error) {
+	var records []qh.QuotationHistory
+
+	err := d.inner.
+		Where("base_currency = ? AND quote_currency = ?", baseCurrency, quoteCurrency).
+		Where("fetched_at >= ? AND fetched_at < ?", from, to).
+		Order("fetched_at").
+		Limit(limit).
+		Find(&records).
+		Error
+
+	return records, err
+}
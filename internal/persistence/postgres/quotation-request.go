@@ -2,8 +2,11 @@ package postgres
 
 import (
 	"errors"
+	qb "plata_currency_quotation/internal/domain/enity/quotation-batch"
 	qr "plata_currency_quotation/internal/domain/enity/quotation-request"
+	wd "plata_currency_quotation/internal/domain/enity/webhook-delivery"
 	"plata_currency_quotation/internal/domain/types"
+	"plata_currency_quotation/internal/persistence"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,24 +14,37 @@ import (
 	"gorm.io/gorm/clause"
 )
 
+// openCondition совпадает с условием частичного индекса idx_quotation_requests_open
+const openCondition = "(status = 'Pending' OR status = 'InProgress')"
+
+// finishedCondition совпадает с условием частичного индекса idx_quotation_requests_finished
+const finishedCondition = "status IN ('Ready', 'Failed', 'Expired')"
+
 func (d *Db) QuotationRequestCreateOrGetByIdempotencyKey(request *qr.QuotationRequest) error {
-	result := d.inner.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "idempotency_key"}},
-		DoNothing: true,
-	}).Create(request)
+	return d.inner.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "idempotency_key"}},
+			DoNothing: true,
+		}).Create(request)
 
-	if result.Error != nil {
-		return result.Error
-	}
+		if result.Error != nil {
+			return result.Error
+		}
 
-	if result.RowsAffected == 0 {
-		request.Id = uuid.Nil
+		if result.RowsAffected == 0 {
+			request.Id = uuid.Nil
 
-		//WTF: какой гений решил, что это хорошая идея неявно id добавлять к where...
-		return d.inner.First(request, "idempotency_key = ?", request.IdempotencyKey).Error
-	}
+			//WTF: какой гений решил, что это хорошая идея неявно id добавлять к where...
+			return tx.First(request, "idempotency_key = ?", request.IdempotencyKey).Error
+		}
 
-	return nil
+		// запрос может быть создан уже завершенным (исторический курс из кэша), вебхук все равно нужен
+		if request.CompletedAt != nil {
+			return enqueueWebhooks(tx, []qr.QuotationRequest{*request})
+		}
+
+		return nil
+	})
 }
 
 func (d *Db) QuotationRequestGetById(id uuid.UUID) (*qr.QuotationRequest, error) {
@@ -45,12 +61,16 @@ func (d *Db) QuotationRequestGetById(id uuid.UUID) (*qr.QuotationRequest, error)
 	return &request, nil
 }
 
-func (d *Db) QuotationRequestUpdateByBaseAndQuote(baseCurrency types.Currency, quoteCurrency types.Currency, price string, time time.Time) error {
-	return d.inner.Model(&qr.QuotationRequest{}).
-		Where("base_currency = ? AND quote_currency = ?", baseCurrency, quoteCurrency).
-		Update("rate", price).
-		Update("completed_at", time).
-		Error
+func (d *Db) QuotationRequestCompletePending(baseCurrency types.Currency, quoteCurrency types.Currency, pendingAt time.Time, quotation types.QuotationInfo) error {
+	return d.inner.Transaction(func(tx *gorm.DB) error {
+		scope := pendingScope(persistence.PendingScope{
+			BaseCurrency:  baseCurrency,
+			QuoteCurrency: quoteCurrency,
+			PendingAt:     pendingAt,
+		})
+
+		return complete(tx, scope, quotation)
+	})
 }
 
 func (d *Db) QuotationRequestGetUniqUnhandled() ([][2]types.Currency, error) {
@@ -58,7 +78,7 @@ func (d *Db) QuotationRequestGetUniqUnhandled() ([][2]types.Currency, error) {
 
 	rows, err := d.inner.Model(&qr.QuotationRequest{}).
 		Select("DISTINCT base_currency, quote_currency").
-		Where("completed_at is null").
+		Where("as_of is null AND " + openCondition).
 		Rows()
 
 	if err != nil {
@@ -84,3 +104,362 @@ func (d *Db) QuotationRequestGetUniqUnhandled() ([][2]types.Currency, error) {
 
 	return result, nil
 }
+
+func (d *Db) QuotationRequestGetUniqUnhandledHistorical() ([]persistence.HistoricalPair, error) {
+	result := make([]persistence.HistoricalPair, 0)
+
+	err := d.inner.Model(&qr.QuotationRequest{}).
+		Select("DISTINCT base_currency, quote_currency, as_of").
+		Where("as_of is not null AND " + openCondition).
+		Scan(&result).
+		Error
+
+	return result, err
+}
+
+func (d *Db) QuotationRequestCompleteHistorical(pair persistence.HistoricalPair, quotation types.QuotationInfo) error {
+	return d.inner.Transaction(func(tx *gorm.DB) error {
+		scope := func(tx *gorm.DB) *gorm.DB {
+			return tx.Where(
+				"base_currency = ? AND quote_currency = ? AND as_of = ? AND "+openCondition,
+				pair.BaseCurrency, pair.QuoteCurrency, pair.AsOf,
+			)
+		}
+
+		return complete(tx, scope, quotation)
+	})
+}
+
+func (d *Db) QuotationRequestGetCompletedHistorical(pair persistence.HistoricalPair) (*qr.QuotationRequest, error) {
+	var request qr.QuotationRequest
+
+	err := d.inner.
+		Where(
+			"base_currency = ? AND quote_currency = ? AND as_of = ? AND status = ?",
+			pair.BaseCurrency, pair.QuoteCurrency, pair.AsOf, qr.Ready,
+		).
+		First(&request).
+		Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &request, nil
+}
+
+func (d *Db) QuotationRequestGetLatestCompleted() ([]persistence.PairQuotation, error) {
+	var requests []qr.QuotationRequest
+
+	err := d.inner.
+		Raw(`SELECT DISTINCT ON (base_currency, quote_currency) * FROM quotation_requests
+			WHERE status = 'Ready' AND as_of is null
+			ORDER BY base_currency, quote_currency, completed_at DESC`).
+		Scan(&requests).
+		Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]persistence.PairQuotation, 0, len(requests))
+
+	for _, request := range requests {
+		result = append(result, toPairQuotation(request))
+	}
+
+	return result, nil
+}
+
+func (d *Db) QuotationRequestStartAttempt(scope persistence.PendingScope) error {
+	return pendingScope(scope)(d.inner.Model(&qr.QuotationRequest{})).
+		Updates(map[string]any{
+			"status":   qr.InProgress,
+			"attempts": gorm.Expr("attempts + 1"),
+		}).
+		Error
+}
+
+func (d *Db) QuotationRequestFailAttempt(scope persistence.PendingScope, failure qr.Failure, maxAttempts int) error {
+	return d.inner.Transaction(func(tx *gorm.DB) error {
+		inProgress := func(tx *gorm.DB) *gorm.DB {
+			return pendingScope(scope)(tx).Where("status = ?", qr.InProgress)
+		}
+
+		// сначала исчерпавшие попытки, им нужен вебхук, остальные возвращаются в Pending
+		if maxAttempts > 0 {
+			exhausted := func(tx *gorm.DB) *gorm.DB {
+				return inProgress(tx).Where("attempts >= ?", maxAttempts)
+			}
+
+			_, err := finalize(tx, exhausted, map[string]any{
+				"status":        qr.Failed,
+				"error_code":    failure.Code,
+				"error_message": failure.Message,
+			})
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return inProgress(tx.Model(&qr.QuotationRequest{})).
+			Updates(map[string]any{
+				"status":        qr.Pending,
+				"error_code":    failure.Code,
+				"error_message": failure.Message,
+			}).
+			Error
+	})
+}
+
+func (d *Db) QuotationRequestExpire(startedBefore time.Time) (int64, error) {
+	var expired int64
+
+	err := d.inner.Transaction(func(tx *gorm.DB) error {
+		scope := func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("COALESCE(attempt_started_at, created_at) < ? AND "+openCondition, startedBefore)
+		}
+
+		var err error
+
+		expired, err = finalize(tx, scope, map[string]any{
+			"status":        qr.Expired,
+			"error_code":    qr.ErrorExpired,
+			"error_message": "request was not completed in time",
+		})
+
+		return err
+	})
+
+	return expired, err
+}
+
+func (d *Db) QuotationRequestList(filter persistence.QuotationRequestFilter) ([]qr.QuotationRequest, error) {
+	query := d.inner.Order("created_at DESC")
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	if filter.BaseCurrency != "" {
+		query = query.Where("base_currency = ?", filter.BaseCurrency)
+	}
+
+	if filter.QuoteCurrency != "" {
+		query = query.Where("quote_currency = ?", filter.QuoteCurrency)
+	}
+
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	requests := make([]qr.QuotationRequest, 0)
+
+	return requests, query.Find(&requests).Error
+}
+
+func (d *Db) QuotationRequestRetry(id uuid.UUID) (bool, error) {
+	result := d.inner.Model(&qr.QuotationRequest{}).
+		Where("id = ? AND status <> ?", id, qr.Ready).
+		Updates(map[string]any{
+			"status":             qr.Pending,
+			"attempts":           0,
+			"error_code":         nil,
+			"error_message":      nil,
+			"attempt_started_at": time.Now(),
+		})
+
+	return result.RowsAffected > 0, result.Error
+}
+
+func (d *Db) QuotationRequestExpireById(id uuid.UUID, message string) (bool, error) {
+	var expired int64
+
+	err := d.inner.Transaction(func(tx *gorm.DB) error {
+		scope := func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("id = ? AND "+openCondition, id)
+		}
+
+		var err error
+
+		expired, err = finalize(tx, scope, map[string]any{
+			"status":        qr.Expired,
+			"error_code":    qr.ErrorExpired,
+			"error_message": message,
+		})
+
+		return err
+	})
+
+	return expired > 0, err
+}
+
+func (d *Db) QuotationRequestPurge(createdBefore time.Time, limit int, archive func([]qr.QuotationRequest) error) ([]qr.QuotationRequest, error) {
+	var purged []qr.QuotationRequest
+
+	err := d.inner.Transaction(func(tx *gorm.DB) error {
+		// SKIP LOCKED - строки, которые сейчас кто-то держит, подождут следующей пачки. Пачка запросов удаляется, только
+		// когда под удаление подходят все ее запросы (включая недоставленные вебхуки), иначе GET пачки отдал бы ее частично
+		err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("created_at < ? AND "+finishedCondition, createdBefore).
+			Where(
+				"NOT EXISTS (SELECT 1 FROM webhook_deliveries w WHERE w.request_id = quotation_requests.id AND w.status = ?)",
+				wd.Pending,
+			).
+			Where(
+				`(batch_id IS NULL OR NOT EXISTS (
+					SELECT 1 FROM quotation_requests o
+					WHERE o.batch_id = quotation_requests.batch_id
+						AND (
+							NOT (o.created_at < ? AND o.status IN ('Ready', 'Failed', 'Expired'))
+							OR EXISTS (SELECT 1 FROM webhook_deliveries w WHERE w.request_id = o.id AND w.status = ?)
+						)
+				))`,
+				createdBefore, wd.Pending,
+			).
+			Order("created_at").
+			Limit(limit).
+			Find(&purged).
+			Error
+
+		if err != nil || len(purged) == 0 {
+			return err
+		}
+
+		if archive != nil {
+			if err := archive(purged); err != nil {
+				return err
+			}
+		}
+
+		ids := make([]uuid.UUID, 0, len(purged))
+		batchIds := make([]uuid.UUID, 0)
+
+		for _, request := range purged {
+			ids = append(ids, request.Id)
+
+			if request.BatchId != nil {
+				batchIds = append(batchIds, *request.BatchId)
+			}
+		}
+
+		if err := tx.Where("request_id IN ?", ids).Delete(&wd.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("id IN ?", ids).Delete(&qr.QuotationRequest{}).Error; err != nil {
+			return err
+		}
+
+		if len(batchIds) == 0 {
+			return nil
+		}
+
+		return tx.
+			Where("id IN ? AND NOT EXISTS (SELECT 1 FROM quotation_requests r WHERE r.batch_id = quotation_batches.id)", batchIds).
+			Delete(&qb.QuotationBatch{}).
+			Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return purged, nil
+}
+
+// pendingScope - для запросов последнего курса условие совпадает с частичным индексом idx_quotation_requests_open
+func pendingScope(scope persistence.PendingScope) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		tx = tx.Where(
+			"base_currency = ? AND quote_currency = ? AND created_at <= ? AND "+openCondition,
+			scope.BaseCurrency, scope.QuoteCurrency, scope.PendingAt,
+		)
+
+		if scope.AsOf == nil {
+			return tx.Where("as_of is null")
+		}
+
+		return tx.Where("as_of = ?", *scope.AsOf)
+	}
+}
+
+func toPairQuotation(request qr.QuotationRequest) persistence.PairQuotation {
+	quotation := persistence.PairQuotation{
+		BaseCurrency:  request.BaseCurrency,
+		QuoteCurrency: request.QuoteCurrency,
+		QuotationInfo: types.QuotationInfo{
+			Rate:      *request.Rate,
+			UpdatedAt: *request.CompletedAt,
+			Detail:    request.Detail,
+		},
+	}
+
+	if request.Provider != nil {
+		quotation.Provider = *request.Provider
+	}
+
+	return quotation
+}
+
+// complete проставляет курс незавершенным запросам из scope и ставит для них в очередь вебхуки
+func complete(tx *gorm.DB, scope func(*gorm.DB) *gorm.DB, quotation types.QuotationInfo) error {
+	_, err := finalize(tx, scope, map[string]any{
+		"status":       qr.Ready,
+		"rate":         quotation.Rate,
+		"completed_at": quotation.UpdatedAt,
+		"provider":     quotation.Provider,
+		"detail":       quotation.Detail,
+	})
+
+	return err
+}
+
+// finalize переводит запросы scope в конечный статус (Ready, Failed, Expired) и в той же транзакции ставит в очередь
+// вебхуки тем из них, у кого есть callback. Возвращает число обновленных запросов
+func finalize(tx *gorm.DB, scope func(*gorm.DB) *gorm.DB, updates map[string]any) (int64, error) {
+	var callbacks []qr.QuotationRequest
+
+	err := scope(tx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("callback_url is not null AND callback_secret is not null").
+		Find(&callbacks).
+		Error
+
+	if err != nil {
+		return 0, err
+	}
+
+	result := scope(tx.Model(&qr.QuotationRequest{})).Updates(updates)
+
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return result.RowsAffected, enqueueWebhooks(tx, callbacks)
+}
+
+func enqueueWebhooks(tx *gorm.DB, requests []qr.QuotationRequest) error {
+	now := time.Now()
+	deliveries := make([]wd.WebhookDelivery, 0, len(requests))
+
+	for _, request := range requests {
+		if request.CallbackUrl == nil || request.CallbackSecret == nil {
+			continue
+		}
+
+		deliveries = append(deliveries, wd.New(request.Id, *request.CallbackUrl, *request.CallbackSecret, now))
+	}
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	return tx.Create(&deliveries).Error
+}
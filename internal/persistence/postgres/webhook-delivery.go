@@ -0,0 +1,36 @@
+package postgres
+
+import (
+	wd "plata_currency_quotation/internal/domain/enity/webhook-delivery"
+	"slices"
+	"time"
+)
+
+func (d *Db) WebhookDeliveryClaimDue(now time.Time, limit int, lease time.Duration) ([]wd.WebhookDelivery, error) {
+	var deliveries []wd.WebhookDelivery
+
+	// SKIP LOCKED - строки, которые прямо сейчас забирает другой диспетчер, достанутся ему
+	err := d.inner.
+		Raw(`UPDATE webhook_deliveries SET next_attempt_at = ?
+			WHERE id IN (
+				SELECT id FROM webhook_deliveries
+				WHERE status = ? AND next_attempt_at <= ?
+				ORDER BY next_attempt_at
+				LIMIT ?
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING *`, now.Add(lease), wd.Pending, now, limit).
+		Scan(&deliveries).
+		Error
+
+	// RETURNING порядок не сохраняет
+	slices.SortFunc(deliveries, func(a, b wd.WebhookDelivery) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return deliveries, err
+}
+
+func (d *Db) WebhookDeliveryUpdate(delivery *wd.WebhookDelivery) error {
+	return d.inner.Save(delivery).Error
+}
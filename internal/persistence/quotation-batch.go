@@ -0,0 +1,15 @@
+package persistence
+
+import (
+	qb "plata_currency_quotation/internal/domain/enity/quotation-batch"
+
+	"github.com/google/uuid"
+)
+
+type QuotationBatchPersistentOperations interface {
+	// QuotationBatchCreateOrGetByIdempotencyKey создает пачку вместе с запросами атомарно. Если пачка с таким ключом
+	// уже есть, заполняет batch существующей
+	QuotationBatchCreateOrGetByIdempotencyKey(batch *qb.QuotationBatch) error
+	// QuotationBatchGetById - пачка с запросами, упорядоченными по base, quote. nil если нет
+	QuotationBatchGetById(id uuid.UUID) (*qb.QuotationBatch, error)
+}
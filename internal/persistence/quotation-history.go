@@ -0,0 +1,15 @@
+package persistence
+
+import (
+	qh "plata_currency_quotation/internal/domain/enity/quotation-history"
+	"plata_currency_quotation/internal/domain/types"
+	"time"
+)
+
+type QuotationHistoryPersistentOperations interface {
+	QuotationHistoryAppend(records []qh.QuotationHistory) error
+	// QuotationHistoryGetRange - записи за [from, to), отсортированные по времени, не больше limit
+	QuotationHistoryGetRange(baseCurrency types.Currency, quoteCurrency types.Currency, from time.Time, to time.Time, limit int) ([]qh.QuotationHistory, error)
+	// QuotationHistoryGetLatest - самая свежая запись по каждой паре
+	QuotationHistoryGetLatest() ([]PairQuotation, error)
+}
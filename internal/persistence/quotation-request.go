@@ -10,9 +10,70 @@ import (
 
 // WTF: Утиные интерфейсы полная хрень!
 
+type HistoricalPair struct {
+	BaseCurrency  types.Currency
+	QuoteCurrency types.Currency
+	AsOf          time.Time
+}
+
+// PairQuotation - последний известный курс пары
+type PairQuotation struct {
+	BaseCurrency  types.Currency
+	QuoteCurrency types.Currency
+	types.QuotationInfo
+}
+
+// PendingScope - незавершенные запросы пары, созданные не позже PendingAt (начала похода к провайдеру).
+// AsOf = nil - запросы последнего курса, иначе исторические на дату AsOf
+type PendingScope struct {
+	BaseCurrency  types.Currency
+	QuoteCurrency types.Currency
+	AsOf          *time.Time
+	PendingAt     time.Time
+}
+
+// QuotationRequestFilter - пустые поля не фильтруют, Limit <= 0 - без ограничения
+type QuotationRequestFilter struct {
+	Status        qr.Status
+	BaseCurrency  types.Currency
+	QuoteCurrency types.Currency
+	Limit         int
+}
+
+// Методы без Historical в названии работают только с запросами последнего курса (as_of is null)
+
 type QuotationRequestPersistentOperations interface {
 	QuotationRequestCreateOrGetByIdempotencyKey(*qr.QuotationRequest) error
 	QuotationRequestGetById(id uuid.UUID) (*qr.QuotationRequest, error)
-	QuotationRequestUpdateByBaseAndQuote(baseCurrency types.Currency, quoteCurrency types.Currency, rate string, time time.Time) error
+	// QuotationRequestCompletePending завершает запросы пары, которые были незавершенными на момент pendingAt (начало
+	// похода к провайдеру). Завершенные запросы сохраняют свой курс, созданные позже pendingAt ждут следующего курса
+	QuotationRequestCompletePending(baseCurrency types.Currency, quoteCurrency types.Currency, pendingAt time.Time, quotation types.QuotationInfo) error
 	QuotationRequestGetUniqUnhandled() ([][2]types.Currency, error)
+	QuotationRequestGetUniqUnhandledHistorical() ([]HistoricalPair, error)
+	// QuotationRequestCompleteHistorical завершает только незавершенные запросы на дату
+	QuotationRequestCompleteHistorical(pair HistoricalPair, quotation types.QuotationInfo) error
+	// QuotationRequestGetCompletedHistorical - любой завершенный запрос на дату, nil если нет
+	QuotationRequestGetCompletedHistorical(pair HistoricalPair) (*qr.QuotationRequest, error)
+	// QuotationRequestStartAttempt переводит запросы scope в InProgress и увеличивает счетчик попыток
+	QuotationRequestStartAttempt(scope PendingScope) error
+	// QuotationRequestFailAttempt запоминает ошибку у InProgress запросов scope. Запросы, исчерпавшие maxAttempts,
+	// становятся Failed, остальные возвращаются в Pending. maxAttempts <= 0 - без ограничения
+	QuotationRequestFailAttempt(scope PendingScope, failure qr.Failure, maxAttempts int) error
+	// QuotationRequestExpire переводит в Expired незавершенные запросы, ttl которых начался раньше startedBefore
+	// (см. qr.QuotationRequest.TtlStartedAt)
+	QuotationRequestExpire(startedBefore time.Time) (int64, error)
+	// QuotationRequestList - сначала новые, и последнего курса, и исторические
+	QuotationRequestList(filter QuotationRequestFilter) ([]qr.QuotationRequest, error)
+	// QuotationRequestRetry возвращает любой не Ready запрос в Pending со сброшенными попытками и ошибкой.
+	// ttl отсчитывается заново от attempt_started_at, created_at не меняется. false - запроса нет или он уже Ready
+	QuotationRequestRetry(id uuid.UUID) (bool, error)
+	// QuotationRequestExpireById переводит Pending или InProgress запрос в Expired, false - запроса нет или он завершен
+	QuotationRequestExpireById(id uuid.UUID, message string) (bool, error)
+	// QuotationRequestGetLatestCompleted - курс самого свежего завершенного запроса по каждой паре
+	QuotationRequestGetLatestCompleted() ([]PairQuotation, error)
+	// QuotationRequestPurge удаляет до limit запросов в конечном статусе, созданных раньше createdBefore, вместе с их
+	// доставками вебхуков и опустевшими пачками. Не трогает запросы с недоставленным вебхуком и пачки, в которых есть
+	// запросы, не подходящие под удаление. archive (если не nil) получает удаляемые запросы в той же транзакции, его
+	// ошибка откатывает пачку. Пустой результат - удалять больше нечего
+	QuotationRequestPurge(createdBefore time.Time, limit int, archive func([]qr.QuotationRequest) error) ([]qr.QuotationRequest, error)
 }
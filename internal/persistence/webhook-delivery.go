@@ -0,0 +1,16 @@
+package persistence
+
+import (
+	wd "plata_currency_quotation/internal/domain/enity/webhook-delivery"
+	"time"
+)
+
+// Доставки создаются в той же транзакции, что и завершение запроса - в QuotationRequestCompletePending
+
+type WebhookDeliveryPersistentOperations interface {
+	// WebhookDeliveryClaimDue забирает до limit доставок, которым пора уходить, и откладывает их на lease, чтобы
+	// другой диспетчер не отправил их еще раз. Результат попытки пишет WebhookDeliveryUpdate, если диспетчер упал -
+	// доставка вернется в очередь по истечении lease
+	WebhookDeliveryClaimDue(now time.Time, limit int, lease time.Duration) ([]wd.WebhookDelivery, error)
+	WebhookDeliveryUpdate(delivery *wd.WebhookDelivery) error
+}
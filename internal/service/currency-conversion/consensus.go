@@ -0,0 +1,251 @@
+package currency_conversion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"plata_currency_quotation/internal/domain/types"
+	"plata_currency_quotation/internal/lib/logger/sl"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const consensusProvider = "consensus"
+
+var ErrNoConsensus = errors.New("not enough agreeing rate sources")
+
+// Consensus опрашивает всех провайдеров параллельно и публикует медиану. Курсы, отклоняющиеся от медианы
+// больше чем на tolerance (доля, 0.01 = 1%), отбрасываются, медиана пересчитывается по оставшимся
+type Consensus struct {
+	providers   []NamedProvider
+	tolerance   types.Decimal
+	minSources  int
+	callTimeout time.Duration
+	logger      *slog.Logger
+}
+
+func NewConsensus(tolerance float64, minSources int, callTimeout time.Duration, providers ...NamedProvider) *Consensus {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})).With(
+		"component", "service/currency-conversion/consensus",
+	)
+
+	return &Consensus{
+		providers:   providers,
+		tolerance:   types.MustParseDecimal(strconv.FormatFloat(tolerance, 'f', -1, 64)),
+		minSources:  max(minSources, 1),
+		callTimeout: callTimeout,
+		logger:      logger,
+	}
+}
+
+func (c *Consensus) SetupMetrics(reg *prometheus.Registry) {
+	for _, provider := range c.providers {
+		provider.Provider.SetupMetrics(reg)
+	}
+}
+
+func (c *Consensus) GetLatestRates(ctx context.Context, base types.Currency, quotes []types.Currency) ([]CurrencyRate, error) {
+	return c.fetch(ctx, base, quotes, false, func(ctx context.Context, provider Interface, quotes []types.Currency) ([]CurrencyRate, error) {
+		return provider.GetLatestRates(ctx, base, quotes)
+	})
+}
+
+func (c *Consensus) GetRatesOn(ctx context.Context, base types.Currency, quotes []types.Currency, date time.Time) ([]CurrencyRate, error) {
+	return c.fetch(ctx, base, quotes, false, func(ctx context.Context, provider Interface, quotes []types.Currency) ([]CurrencyRate, error) {
+		return provider.GetRatesOn(ctx, base, quotes, date)
+	})
+}
+
+func (c *Consensus) GetRatesRange(ctx context.Context, base types.Currency, quotes []types.Currency, from time.Time, to time.Time) ([]CurrencyRate, error) {
+	rates, err := c.fetch(ctx, base, quotes, true, func(ctx context.Context, provider Interface, quotes []types.Currency) ([]CurrencyRate, error) {
+		return provider.GetRatesRange(ctx, base, quotes, from, to)
+	})
+
+	slices.SortStableFunc(rates, func(a, b CurrencyRate) int {
+		return a.Time.Compare(b.Time)
+	})
+
+	return rates, err
+}
+
+type providerResult struct {
+	provider string
+	rates    []CurrencyRate
+	err      error
+}
+
+type consensusKey struct {
+	currency types.Currency
+	// day заполняется только для GetRatesRange, где у каждого дня своя медиана
+	day string
+}
+
+func (c *Consensus) fetch(ctx context.Context, base types.Currency, quotes []types.Currency, byDay bool, call providerCall) ([]CurrencyRate, error) {
+	results := make([]providerResult, len(c.providers))
+	var wg sync.WaitGroup
+
+	for i, provider := range c.providers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			rates, err := callWithTimeout(ctx, provider.Provider, c.callTimeout, quotes, call)
+
+			if err != nil {
+				c.logger.Warn("rate provider failed", slog.String("provider", provider.Name), sl.Err(err))
+			}
+
+			results[i] = providerResult{provider: provider.Name, rates: rates, err: err}
+		}()
+	}
+
+	wg.Wait()
+
+	var keys []consensusKey
+	grouped := make(map[consensusKey][]CurrencyRate)
+
+	for _, result := range results {
+		for _, rate := range result.rates {
+			if !slices.Contains(quotes, rate.Currency) {
+				continue
+			}
+
+			key := consensusKey{currency: rate.Currency}
+
+			if byDay {
+				key.day = rate.Time.Format(time.DateOnly)
+			}
+
+			if _, exists := grouped[key]; !exists {
+				keys = append(keys, key)
+			}
+
+			rate.Provider = result.provider
+			grouped[key] = append(grouped[key], rate)
+		}
+	}
+
+	var rates []CurrencyRate
+	var errs []error
+	served := make(map[types.Currency]bool)
+
+	for _, key := range keys {
+		rate, err := c.aggregate(key.currency, grouped[key], results)
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s/%s %s: %w", base, key.currency, key.day, err))
+
+			continue
+		}
+
+		rates = append(rates, rate)
+		served[key.currency] = true
+	}
+
+	var missing []string
+
+	for _, quote := range quotes {
+		if !served[quote] {
+			missing = append(missing, string(base+"/"+quote))
+		}
+	}
+
+	if len(missing) > 0 {
+		errs = append(errs, fmt.Errorf("%w for %s", ErrRateNotFound, strings.Join(missing, ", ")))
+	}
+
+	return rates, errors.Join(errs...)
+}
+
+func (c *Consensus) aggregate(currency types.Currency, candidates []CurrencyRate, results []providerResult) (CurrencyRate, error) {
+	values := make([]types.Decimal, len(candidates))
+
+	for i, candidate := range candidates {
+		values[i] = candidate.Rate
+	}
+
+	median := medianOf(values)
+	accepted := make([]types.Decimal, 0, len(values))
+	detail := types.RateDetail{Sources: make([]types.RateSource, 0, len(c.providers))}
+	var at time.Time
+
+	for i, candidate := range candidates {
+		ok := withinTolerance(values[i], median, c.tolerance)
+
+		if ok {
+			accepted = append(accepted, values[i])
+
+			if candidate.Time.After(at) {
+				at = candidate.Time
+			}
+		}
+
+		detail.Sources = append(detail.Sources, types.RateSource{
+			Provider: candidate.Provider,
+			Rate:     &values[i],
+			Accepted: ok,
+		})
+	}
+
+	// провайдеры без курса по валюте тоже попадают в разбивку, чтобы было видно, почему низкий confidence
+	for _, result := range results {
+		if slices.ContainsFunc(candidates, func(rate CurrencyRate) bool { return rate.Provider == result.provider }) {
+			continue
+		}
+
+		reason := ErrRateNotFound.Error()
+
+		if result.err != nil && !errors.Is(result.err, ErrRateNotFound) {
+			reason = result.err.Error()
+		}
+
+		detail.Sources = append(detail.Sources, types.RateSource{Provider: result.provider, Error: reason})
+	}
+
+	if len(accepted) < c.minSources {
+		return CurrencyRate{}, fmt.Errorf("%w: %d of %d required", ErrNoConsensus, len(accepted), c.minSources)
+	}
+
+	detail.Confidence = float64(len(accepted)) / float64(len(c.providers))
+
+	return CurrencyRate{
+		Rate:     medianOf(accepted),
+		Time:     at,
+		Currency: currency,
+		Provider: consensusProvider,
+		Detail:   &detail,
+	}, nil
+}
+
+var half = types.MustParseDecimal("0.5")
+
+// medianOf не меняет порядок values, он совпадает с приоритетом провайдеров. Для нечетного числа значений медиана -
+// одно из них с исходным масштабом, из численно равных ("20.10" и "20.1") берется значение самого приоритетного
+// провайдера. Для четного полусумма точна: масштаб на один знак больше максимального масштаба слагаемых
+func medianOf(values []types.Decimal) types.Decimal {
+	sorted := slices.Clone(values)
+	slices.SortFunc(sorted, func(a, b types.Decimal) int { return a.Cmp(b) })
+
+	middle := len(sorted) / 2
+
+	if len(sorted)%2 == 1 {
+		return values[slices.IndexFunc(values, func(value types.Decimal) bool { return value.Cmp(sorted[middle]) == 0 })]
+	}
+
+	return sorted[middle-1].Add(sorted[middle]).Mul(half)
+}
+
+// withinTolerance - |value - median| <= tolerance * |median|
+func withinTolerance(value types.Decimal, median types.Decimal, tolerance types.Decimal) bool {
+	deviation := value.Sub(median).Abs()
+
+	return deviation.Cmp(tolerance.Mul(median.Abs())) <= 0
+}
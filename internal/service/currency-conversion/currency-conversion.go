@@ -1,6 +1,8 @@
 package currency_conversion
 
 import (
+	"context"
+	"errors"
 	"plata_currency_quotation/internal/domain/types"
 	"time"
 
@@ -9,13 +11,32 @@ import (
 
 var Instance Interface
 
+// ErrRateNotFound - провайдер ответил, но не знает часть запрошенных валют. Курсы по остальным возвращаются вместе с ошибкой
+var ErrRateNotFound = errors.New("rate not found")
+
 type CurrencyRate struct {
-	Rate     string
+	Rate     types.Decimal
 	Time     time.Time
 	Currency types.Currency
+	Provider string
+	Detail   *types.RateDetail
+}
+
+func (r CurrencyRate) Info() types.QuotationInfo {
+	return types.QuotationInfo{
+		Rate:      r.Rate,
+		UpdatedAt: r.Time,
+		Provider:  r.Provider,
+		Detail:    r.Detail,
+	}
 }
 
+// Interface - провайдер обрывает поход за курсами, когда отменен ctx
 type Interface interface {
-	GetLatestRates(base types.Currency, quotes []types.Currency) ([]CurrencyRate, error)
+	GetLatestRates(ctx context.Context, base types.Currency, quotes []types.Currency) ([]CurrencyRate, error)
+	// GetRatesOn - курсы на дату. Если на дату курса нет (выходной), провайдер отдает ближайший предыдущий, Time - его дата
+	GetRatesOn(ctx context.Context, base types.Currency, quotes []types.Currency, date time.Time) ([]CurrencyRate, error)
+	// GetRatesRange - курсы за каждый доступный день в [from, to], отсортированы по Time
+	GetRatesRange(ctx context.Context, base types.Currency, quotes []types.Currency, from time.Time, to time.Time) ([]CurrencyRate, error)
 	SetupMetrics(reg *prometheus.Registry)
 }
@@ -0,0 +1,203 @@
+package currency_conversion
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"plata_currency_quotation/internal/domain/types"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	ecbProvider = "ecb"
+	// ecbCrossRateScale - знаков после запятой для кросс-курсов, ЕЦБ публикует курсы только к EUR
+	ecbCrossRateScale = 10
+)
+
+// EcbApi читает формат eurofxref ЕЦБ. dailyUrl - eurofxref-daily.xml, historyUrl - eurofxref-hist-90d.xml
+// или eurofxref-hist.xml, нужен для GetRatesOn и GetRatesRange
+type EcbApi struct {
+	dailyUrl   string
+	historyUrl string
+	source     *httpSource
+}
+
+func NewEcbApi(dailyUrl string, historyUrl string, requestTimeout time.Duration) *EcbApi {
+	return &EcbApi{
+		dailyUrl:   dailyUrl,
+		historyUrl: historyUrl,
+		source:     newHttpSource(ecbProvider, requestTimeout),
+	}
+}
+
+type ecbEnvelope struct {
+	Days []ecbDay `xml:"Cube>Cube"`
+}
+
+type ecbDay struct {
+	Time  string `xml:"time,attr"`
+	Rates []struct {
+		Currency string `xml:"currency,attr"`
+		Rate     string `xml:"rate,attr"`
+	} `xml:"Cube"`
+}
+
+func (e *EcbApi) SetupMetrics(reg *prometheus.Registry) {
+	registerHttpMetrics(reg)
+}
+
+func (e *EcbApi) SetPolicy(retry RetryPolicy, breaker BreakerPolicy) {
+	e.source.setPolicy(retry, breaker)
+}
+
+func (e *EcbApi) GetLatestRates(ctx context.Context, base types.Currency, quotes []types.Currency) ([]CurrencyRate, error) {
+	days, err := e.fetch(ctx, e.dailyUrl)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(days) == 0 {
+		return nil, fmt.Errorf("%w: ecb daily feed is empty", ErrRateNotFound)
+	}
+
+	latest := days[len(days)-1]
+
+	return e.toCurrencyRates(base, quotes, latest)
+}
+
+func (e *EcbApi) GetRatesOn(ctx context.Context, base types.Currency, quotes []types.Currency, date time.Time) ([]CurrencyRate, error) {
+	days, err := e.fetch(ctx, e.historyUrl)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// как и Frankfurter, на выходной отдаем ближайший предыдущий день
+	for i := len(days) - 1; i >= 0; i-- {
+		if !days[i].date.After(date) {
+			return e.toCurrencyRates(base, quotes, days[i])
+		}
+	}
+
+	return nil, fmt.Errorf("%w: ecb history has no rates on %s", ErrRateNotFound, date.Format(time.DateOnly))
+}
+
+func (e *EcbApi) GetRatesRange(ctx context.Context, base types.Currency, quotes []types.Currency, from time.Time, to time.Time) ([]CurrencyRate, error) {
+	days, err := e.fetch(ctx, e.historyUrl)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var rates []CurrencyRate
+
+	for _, day := range days {
+		if day.date.Before(from) || day.date.After(to) {
+			continue
+		}
+
+		// Пропуски по отдельным дням допустимы
+		converted, _ := e.toCurrencyRates(base, quotes, day)
+
+		rates = append(rates, converted...)
+	}
+
+	return rates, nil
+}
+
+type ecbRates struct {
+	date  time.Time
+	rates map[types.Currency]types.Decimal
+}
+
+// fetch возвращает дни, отсортированные по возрастанию даты
+func (e *EcbApi) fetch(ctx context.Context, url string) ([]ecbRates, error) {
+	var envelope ecbEnvelope
+
+	err := e.source.get(ctx, url, func(body io.Reader) error {
+		return xml.NewDecoder(body).Decode(&envelope)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	days := make([]ecbRates, 0, len(envelope.Days))
+
+	for _, day := range envelope.Days {
+		date, err := time.Parse(time.DateOnly, day.Time)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse date %q: %w", day.Time, err)
+		}
+
+		rates := map[types.Currency]types.Decimal{types.EUR: types.NewDecimalFromInt(1)}
+
+		for _, rate := range day.Rates {
+			value, err := types.ParseDecimal(rate.Rate)
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid ecb rate for %s: %w", rate.Currency, err)
+			}
+
+			rates[types.Currency(rate.Currency)] = value
+		}
+
+		days = append(days, ecbRates{date: date, rates: rates})
+	}
+
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].date.Before(days[j].date)
+	})
+
+	return days, nil
+}
+
+// toCurrencyRates пересчитывает курсы к EUR в курсы к base: base/quote = (EUR/quote) / (EUR/base)
+func (e *EcbApi) toCurrencyRates(base types.Currency, quotes []types.Currency, day ecbRates) ([]CurrencyRate, error) {
+	baseRate, exists := day.rates[base]
+
+	if !exists {
+		return nil, fmt.Errorf("%w: ecb does not publish %s", ErrRateNotFound, base)
+	}
+
+	if baseRate.IsZero() {
+		return nil, fmt.Errorf("invalid ecb rate %s for %s", baseRate, base)
+	}
+
+	var rates []CurrencyRate
+	var missing []string
+
+	for _, quote := range quotes {
+		rate, exists := day.rates[quote]
+
+		if !exists {
+			missing = append(missing, string(base+"/"+quote))
+
+			continue
+		}
+
+		if base != types.EUR {
+			rate = rate.Div(baseRate, ecbCrossRateScale)
+		}
+
+		rates = append(rates, CurrencyRate{
+			Rate:     rate,
+			Time:     day.date,
+			Currency: quote,
+			Provider: ecbProvider,
+		})
+	}
+
+	if len(missing) > 0 {
+		return rates, fmt.Errorf("%w for %s", ErrRateNotFound, strings.Join(missing, ", "))
+	}
+
+	return rates, nil
+}
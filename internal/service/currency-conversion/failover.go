@@ -0,0 +1,207 @@
+package currency_conversion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"plata_currency_quotation/internal/domain/types"
+	"plata_currency_quotation/internal/lib/logger/sl"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var ErrProviderTimeout = errors.New("provider call timed out")
+
+var providerAvailable = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "rate_provider_available",
+		Help: "Whether the rate provider is currently used (1) or skipped due to cooldown (0)",
+	},
+	[]string{"provider"},
+)
+
+type NamedProvider struct {
+	Name     string
+	Provider Interface
+}
+
+// Failover опрашивает провайдеров в порядке приоритета. Валюты, которые провайдер не отдал (ошибка, таймаут,
+// отсутствующий символ), запрашиваются у следующего. Упавший провайдер пропускается на время cooldown
+type Failover struct {
+	providers      []NamedProvider
+	cooldown       time.Duration
+	callTimeout    time.Duration
+	mutex          sync.Mutex
+	unhealthyUntil map[string]time.Time
+	now            func() time.Time
+	logger         *slog.Logger
+}
+
+func NewFailover(cooldown time.Duration, callTimeout time.Duration, providers ...NamedProvider) *Failover {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})).With(
+		"component", "service/currency-conversion/failover",
+	)
+
+	for _, provider := range providers {
+		providerAvailable.WithLabelValues(provider.Name).Set(1)
+	}
+
+	return &Failover{
+		providers:      providers,
+		cooldown:       cooldown,
+		callTimeout:    callTimeout,
+		unhealthyUntil: make(map[string]time.Time),
+		now:            time.Now,
+		logger:         logger,
+	}
+}
+
+func (f *Failover) SetupMetrics(reg *prometheus.Registry) {
+	reg.MustRegister(providerAvailable)
+
+	for _, provider := range f.providers {
+		provider.Provider.SetupMetrics(reg)
+	}
+}
+
+func (f *Failover) GetLatestRates(ctx context.Context, base types.Currency, quotes []types.Currency) ([]CurrencyRate, error) {
+	return f.fetch(ctx, base, quotes, func(ctx context.Context, provider Interface, quotes []types.Currency) ([]CurrencyRate, error) {
+		return provider.GetLatestRates(ctx, base, quotes)
+	})
+}
+
+func (f *Failover) GetRatesOn(ctx context.Context, base types.Currency, quotes []types.Currency, date time.Time) ([]CurrencyRate, error) {
+	return f.fetch(ctx, base, quotes, func(ctx context.Context, provider Interface, quotes []types.Currency) ([]CurrencyRate, error) {
+		return provider.GetRatesOn(ctx, base, quotes, date)
+	})
+}
+
+func (f *Failover) GetRatesRange(ctx context.Context, base types.Currency, quotes []types.Currency, from time.Time, to time.Time) ([]CurrencyRate, error) {
+	rates, err := f.fetch(ctx, base, quotes, func(ctx context.Context, provider Interface, quotes []types.Currency) ([]CurrencyRate, error) {
+		return provider.GetRatesRange(ctx, base, quotes, from, to)
+	})
+
+	slices.SortStableFunc(rates, func(a, b CurrencyRate) int {
+		return a.Time.Compare(b.Time)
+	})
+
+	return rates, err
+}
+
+type providerCall func(ctx context.Context, provider Interface, quotes []types.Currency) ([]CurrencyRate, error)
+
+// fetch возвращает ошибку, только если часть валют не отдал ни один провайдер
+func (f *Failover) fetch(ctx context.Context, base types.Currency, quotes []types.Currency, call providerCall) ([]CurrencyRate, error) {
+	remaining := slices.Clone(quotes)
+	var result []CurrencyRate
+	var errs []error
+
+	for _, provider := range f.candidates() {
+		if len(remaining) == 0 {
+			break
+		}
+
+		rates, err := callWithTimeout(ctx, provider.Provider, f.callTimeout, remaining, call)
+
+		if err != nil && !errors.Is(err, ErrRateNotFound) {
+			f.markUnhealthy(provider.Name)
+			f.logger.Warn("rate provider failed", slog.String("provider", provider.Name), sl.Err(err))
+		} else {
+			f.markHealthy(provider.Name)
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", provider.Name, err))
+		}
+
+		var served []types.Currency
+
+		for _, rate := range rates {
+			if !slices.Contains(remaining, rate.Currency) {
+				continue
+			}
+
+			rate.Provider = provider.Name
+			result = append(result, rate)
+			served = append(served, rate.Currency)
+		}
+
+		remaining = slices.DeleteFunc(remaining, func(quote types.Currency) bool {
+			return slices.Contains(served, quote)
+		})
+	}
+
+	if len(remaining) == 0 {
+		return result, nil
+	}
+
+	missing := make([]string, 0, len(remaining))
+
+	for _, quote := range remaining {
+		missing = append(missing, string(base+"/"+quote))
+	}
+
+	errs = append(errs, fmt.Errorf("%w for %s", ErrRateNotFound, strings.Join(missing, ", ")))
+
+	return result, errors.Join(errs...)
+}
+
+// candidates - здоровые провайдеры в порядке приоритета. Если на cooldown все, пробуем всех, чтобы не простаивать
+func (f *Failover) candidates() []NamedProvider {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	now := f.now()
+	healthy := make([]NamedProvider, 0, len(f.providers))
+
+	for _, provider := range f.providers {
+		if now.Before(f.unhealthyUntil[provider.Name]) {
+			continue
+		}
+
+		healthy = append(healthy, provider)
+	}
+
+	if len(healthy) == 0 {
+		return f.providers
+	}
+
+	return healthy
+}
+
+// callWithTimeout вызывает провайдера с ctx, который истекает через timeout. Провайдер сам обрывает запрос по ctx,
+// так что после возврата от вызова ничего не остается работать
+func callWithTimeout(ctx context.Context, provider Interface, timeout time.Duration, quotes []types.Currency, call providerCall) ([]CurrencyRate, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	rates, err := call(ctx, provider, quotes)
+
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return rates, fmt.Errorf("%w after %s: %w", ErrProviderTimeout, timeout, err)
+	}
+
+	return rates, err
+}
+
+func (f *Failover) markUnhealthy(name string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.unhealthyUntil[name] = f.now().Add(f.cooldown)
+	providerAvailable.WithLabelValues(name).Set(0)
+}
+
+func (f *Failover) markHealthy(name string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	delete(f.unhealthyUntil, name)
+	providerAvailable.WithLabelValues(name).Set(1)
+}
@@ -0,0 +1,279 @@
+package currency_conversion
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"plata_currency_quotation/internal/domain/types"
+	"plata_currency_quotation/internal/lib/logger/sl"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const fileProvider = "file"
+
+// FileProvider читает курсы из CSV или JSON файла, формат по расширению. Файл перечитывается при изменении
+// mtime или размера. Если новая версия не разбирается, продолжаем отдавать предыдущую.
+//
+// CSV - заголовок base,quote,rate,date. JSON - массив объектов с теми же полями, rate - число или строка.
+// date (YYYY-MM-DD) необязательна: строка без даты - текущий курс со временем изменения файла
+type FileProvider struct {
+	path    string
+	mutex   sync.Mutex
+	modTime time.Time
+	size    int64
+	entries []fileRate
+	logger  *slog.Logger
+}
+
+type fileRate struct {
+	Base  types.Currency `json:"base"`
+	Quote types.Currency `json:"quote"`
+	Rate  types.Decimal  `json:"rate"`
+	Date  string         `json:"date,omitempty"`
+	at    time.Time
+	dated bool
+}
+
+func NewFileProvider(path string) *FileProvider {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})).With(
+		"component", "service/currency-conversion/file",
+	)
+
+	return &FileProvider{path: path, logger: logger}
+}
+
+func (f *FileProvider) SetupMetrics(_ *prometheus.Registry) {
+
+}
+
+func (f *FileProvider) GetLatestRates(_ context.Context, base types.Currency, quotes []types.Currency) ([]CurrencyRate, error) {
+	return f.pick(base, quotes, func(entry fileRate) bool { return true })
+}
+
+func (f *FileProvider) GetRatesOn(_ context.Context, base types.Currency, quotes []types.Currency, date time.Time) ([]CurrencyRate, error) {
+	return f.pick(base, quotes, func(entry fileRate) bool {
+		return entry.dated && !entry.at.After(date)
+	})
+}
+
+func (f *FileProvider) GetRatesRange(_ context.Context, base types.Currency, quotes []types.Currency, from time.Time, to time.Time) ([]CurrencyRate, error) {
+	entries, err := f.load()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var rates []CurrencyRate
+
+	for _, entry := range entries {
+		if entry.Base != base || !slices.Contains(quotes, entry.Quote) || !entry.dated {
+			continue
+		}
+
+		if entry.at.Before(from) || entry.at.After(to) {
+			continue
+		}
+
+		rates = append(rates, entry.toCurrencyRate())
+	}
+
+	slices.SortStableFunc(rates, func(a, b CurrencyRate) int {
+		return a.Time.Compare(b.Time)
+	})
+
+	return rates, nil
+}
+
+// pick - самая свежая подходящая запись по каждой валюте. Недатированная запись свежее любой датированной
+func (f *FileProvider) pick(base types.Currency, quotes []types.Currency, matches func(entry fileRate) bool) ([]CurrencyRate, error) {
+	entries, err := f.load()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var rates []CurrencyRate
+	var missing []string
+
+	for _, quote := range quotes {
+		var best *fileRate
+
+		for i := range entries {
+			entry := &entries[i]
+
+			if entry.Base != base || entry.Quote != quote || !matches(*entry) {
+				continue
+			}
+
+			if best == nil || (best.dated && (!entry.dated || entry.at.After(best.at))) {
+				best = entry
+			}
+		}
+
+		if best == nil {
+			missing = append(missing, string(base+"/"+quote))
+
+			continue
+		}
+
+		rates = append(rates, best.toCurrencyRate())
+	}
+
+	if len(missing) > 0 {
+		return rates, fmt.Errorf("%w for %s", ErrRateNotFound, strings.Join(missing, ", "))
+	}
+
+	return rates, nil
+}
+
+func (e fileRate) toCurrencyRate() CurrencyRate {
+	return CurrencyRate{Rate: e.Rate, Time: e.at, Currency: e.Quote, Provider: fileProvider}
+}
+
+func (f *FileProvider) load() ([]fileRate, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	info, err := os.Stat(f.path)
+
+	if err != nil {
+		if f.entries != nil {
+			f.logger.Warn("rates file is unavailable, serving previous version", sl.Err(err))
+
+			return f.entries, nil
+		}
+
+		return nil, fmt.Errorf("failed to stat rates file: %w", err)
+	}
+
+	if f.entries != nil && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.entries, nil
+	}
+
+	entries, err := readRatesFile(f.path, info.ModTime())
+
+	if err != nil {
+		if f.entries != nil {
+			f.logger.Warn("failed to reload rates file, serving previous version", sl.Err(err))
+
+			return f.entries, nil
+		}
+
+		return nil, err
+	}
+
+	f.entries, f.modTime, f.size = entries, info.ModTime(), info.Size()
+
+	return f.entries, nil
+}
+
+func readRatesFile(path string, modTime time.Time) ([]fileRate, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rates file: %w", err)
+	}
+
+	defer func() {
+		if err := file.Close(); err != nil {
+			sl.Log.Error("failed to close rates file", sl.Err(err))
+		}
+	}()
+
+	var entries []fileRate
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		entries, err = readRatesCsv(file)
+	case ".json":
+		err = json.NewDecoder(file).Decode(&entries)
+	default:
+		return nil, fmt.Errorf("unsupported rates file extension %q", filepath.Ext(path))
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rates file: %w", err)
+	}
+
+	// пустой, но разобранный файл отличаем от незагруженного
+	result := make([]fileRate, 0, len(entries))
+
+	for i, entry := range entries {
+		entry.Base = types.Currency(strings.ToUpper(strings.TrimSpace(string(entry.Base))))
+		entry.Quote = types.Currency(strings.ToUpper(strings.TrimSpace(string(entry.Quote))))
+		entry.at = modTime
+
+		if entry.Date != "" {
+			date, err := time.Parse(time.DateOnly, strings.TrimSpace(entry.Date))
+
+			if err != nil {
+				return nil, fmt.Errorf("record %d: invalid date %q", i+1, entry.Date)
+			}
+
+			entry.at, entry.dated = date, true
+		}
+
+		result = append(result, entry)
+	}
+
+	return result, nil
+}
+
+func readRatesCsv(reader io.Reader) ([]fileRate, error) {
+	records, err := csv.NewReader(reader).ReadAll()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, errors.New("missing header")
+	}
+
+	columns := make(map[string]int)
+
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, required := range []string{"base", "quote", "rate"} {
+		if _, exists := columns[required]; !exists {
+			return nil, fmt.Errorf("missing column %q", required)
+		}
+	}
+
+	entries := make([]fileRate, 0, len(records)-1)
+
+	for i, record := range records[1:] {
+		rate, err := types.ParseDecimal(strings.TrimSpace(record[columns["rate"]]))
+
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", i+1, err)
+		}
+
+		entry := fileRate{
+			Base:  types.Currency(record[columns["base"]]),
+			Quote: types.Currency(record[columns["quote"]]),
+			Rate:  rate,
+		}
+
+		if i, exists := columns["date"]; exists {
+			entry.Date = record[i]
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
@@ -1,122 +1,155 @@
 package currency_conversion
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
+	"io"
 	"plata_currency_quotation/internal/domain/types"
-	"plata_currency_quotation/internal/lib/logger/sl"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-var (
-	requestsTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "outgoing_http_requests_total",
-			Help: "Total number of outgoing HTTP requests",
-		},
-		[]string{"method", "status", "service"},
-	)
-
-	requestDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "outgoing_http_request_duration_seconds",
-			Help:    "Duration of outgoing HTTP requests in seconds",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"method", "service"},
-	)
+const (
+	frankfurterDateLayout = "2006-01-02"
+	frankfurterProvider   = "frankfurter"
 )
 
 type FrankfurterApi struct {
 	apiUrl string
-	client *http.Client
+	source *httpSource
 }
 
 func NewFrankfurterApi(apiUrl string, requestTimeout time.Duration) *FrankfurterApi {
 	return &FrankfurterApi{
 		apiUrl: apiUrl,
-		client: &http.Client{
-			Timeout: requestTimeout,
-		},
+		source: newHttpSource(frankfurterProvider, requestTimeout),
 	}
 }
 
 type frankfurterResponse struct {
-	Amount float64            `json:"amount"`
-	Base   string             `json:"base"`
-	Date   string             `json:"date"`
-	Rates  map[string]float64 `json:"rates"`
+	Amount float64                  `json:"amount"`
+	Base   string                   `json:"base"`
+	Date   string                   `json:"date"`
+	Rates  map[string]types.Decimal `json:"rates"`
+}
+
+type frankfurterRangeResponse struct {
+	Amount    float64                             `json:"amount"`
+	Base      string                              `json:"base"`
+	StartDate string                              `json:"start_date"`
+	EndDate   string                              `json:"end_date"`
+	Rates     map[string]map[string]types.Decimal `json:"rates"`
 }
 
 func (m *FrankfurterApi) SetupMetrics(reg *prometheus.Registry) {
-	reg.MustRegister(requestsTotal)
-	reg.MustRegister(requestDuration)
+	registerHttpMetrics(reg)
 }
 
-func (m *FrankfurterApi) GetLatestRates(base types.Currency, quotes []types.Currency) ([]CurrencyRate, error) {
-	symbols := ""
-	for i, quote := range quotes {
-		if i > 0 {
-			symbols += ","
-		}
-		symbols += string(quote)
+func (m *FrankfurterApi) SetPolicy(retry RetryPolicy, breaker BreakerPolicy) {
+	m.source.setPolicy(retry, breaker)
+}
+
+func (m *FrankfurterApi) GetLatestRates(ctx context.Context, base types.Currency, quotes []types.Currency) ([]CurrencyRate, error) {
+	var response frankfurterResponse
+
+	if err := m.get(ctx, "latest", base, quotes, &response); err != nil {
+		return nil, err
 	}
 
-	url := fmt.Sprintf("%s/v1/latest?base=%s&symbols=%s", m.apiUrl, base, symbols)
+	//TODO: Апи отдает только дату, без времени
+	return toCurrencyRates(base, quotes, response.Rates, time.Now())
+}
 
-	start := time.Now()
-	resp, err := m.client.Get(url)
-	duration := time.Since(start).Seconds()
+func (m *FrankfurterApi) GetRatesOn(ctx context.Context, base types.Currency, quotes []types.Currency, date time.Time) ([]CurrencyRate, error) {
+	var response frankfurterResponse
+
+	if err := m.get(ctx, date.Format(frankfurterDateLayout), base, quotes, &response); err != nil {
+		return nil, err
+	}
 
-	requestDuration.WithLabelValues("GET", "frankfurter").Observe(duration)
+	rateDate, err := time.Parse(frankfurterDateLayout, response.Date)
 
 	if err != nil {
-		requestsTotal.WithLabelValues("GET", "error", "frankfurter").Inc()
+		return nil, fmt.Errorf("failed to parse date %q: %w", response.Date, err)
+	}
+
+	return toCurrencyRates(base, quotes, response.Rates, rateDate)
+}
+
+func (m *FrankfurterApi) GetRatesRange(ctx context.Context, base types.Currency, quotes []types.Currency, from time.Time, to time.Time) ([]CurrencyRate, error) {
+	var response frankfurterRangeResponse
 
-		return nil, fmt.Errorf("failed to fetch rate: %w", err)
+	path := from.Format(frankfurterDateLayout) + ".." + to.Format(frankfurterDateLayout)
+
+	if err := m.get(ctx, path, base, quotes, &response); err != nil {
+		return nil, err
 	}
 
-	defer func() {
-		err := resp.Body.Close()
+	var rates []CurrencyRate
+
+	for date, dayRates := range response.Rates {
+		rateDate, err := time.Parse(frankfurterDateLayout, date)
 
 		if err != nil {
-			sl.Log.Error("failed to close response body", sl.Err(err))
+			return nil, fmt.Errorf("failed to parse date %q: %w", date, err)
 		}
-	}()
 
-	if resp.StatusCode != http.StatusOK {
-		requestsTotal.WithLabelValues("GET", fmt.Sprint(resp.StatusCode), "frankfurter").Inc()
+		// Пропуски по отдельным дням допустимы
+		converted, _ := toCurrencyRates(base, quotes, dayRates, rateDate)
 
-		return nil, fmt.Errorf("API request failed with status: %d", resp.StatusCode)
+		rates = append(rates, converted...)
 	}
 
-	requestsTotal.WithLabelValues("GET", fmt.Sprint(resp.StatusCode), "frankfurter").Inc()
+	sort.SliceStable(rates, func(i, j int) bool {
+		return rates[i].Time.Before(rates[j].Time)
+	})
 
-	var response frankfurterResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	return rates, nil
+}
+
+func (m *FrankfurterApi) get(ctx context.Context, path string, base types.Currency, quotes []types.Currency, response any) error {
+	symbols := make([]string, 0, len(quotes))
+
+	for _, quote := range quotes {
+		symbols = append(symbols, string(quote))
 	}
 
+	url := fmt.Sprintf("%s/v1/%s?base=%s&symbols=%s", m.apiUrl, path, base, strings.Join(symbols, ","))
+
+	return m.source.get(ctx, url, func(body io.Reader) error {
+		return json.NewDecoder(body).Decode(response)
+	})
+}
+
+// toCurrencyRates возвращает найденные курсы и ErrRateNotFound со списком пропущенных валют, если такие есть
+func toCurrencyRates(base types.Currency, quotes []types.Currency, values map[string]types.Decimal, at time.Time) ([]CurrencyRate, error) {
 	var rates []CurrencyRate
+	var missing []string
 
 	for _, quote := range quotes {
-		rate, exists := response.Rates[string(quote)]
+		rate, exists := values[string(quote)]
 
 		if !exists {
-			//TODO: не уверен, мб стоит просто залогировать
-			return nil, fmt.Errorf("rate not found for currency pair %s/%s", base, quote)
+			missing = append(missing, string(base+"/"+quote))
+
+			continue
 		}
 
 		rates = append(rates, CurrencyRate{
-			Rate: fmt.Sprintf("%g", rate),
-			//TODO: Апи отдает только дату, без времени
-			Time:     time.Now(),
+			Rate:     rate,
+			Time:     at,
 			Currency: quote,
+			Provider: frankfurterProvider,
 		})
 	}
 
+	if len(missing) > 0 {
+		return rates, fmt.Errorf("%w for %s", ErrRateNotFound, strings.Join(missing, ", "))
+	}
+
 	return rates, nil
 }
@@ -0,0 +1,248 @@
+package currency_conversion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"plata_currency_quotation/internal/lib/logger/sl"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	requestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "outgoing_http_requests_total",
+			Help: "Total number of outgoing HTTP requests",
+		},
+		[]string{"method", "status", "service"},
+	)
+
+	requestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "outgoing_http_request_duration_seconds",
+			Help:    "Duration of outgoing HTTP requests in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"method", "service"},
+	)
+)
+
+// registerHttpMetrics - метрики общие для всех http провайдеров, поэтому повторная регистрация не ошибка
+func registerHttpMetrics(reg *prometheus.Registry) {
+	for _, collector := range []prometheus.Collector{requestsTotal, requestDuration, retriesTotal, circuitState} {
+		var alreadyRegistered prometheus.AlreadyRegisteredError
+
+		if err := reg.Register(collector); err != nil && !errors.As(err, &alreadyRegistered) {
+			panic(err)
+		}
+	}
+}
+
+// httpSource - GET с метриками исходящих запросов, повторами и автоматом на провайдера, service - лейбл провайдера.
+// По умолчанию без повторов и без автомата, см. setPolicy
+type httpSource struct {
+	service string
+	client  *http.Client
+	retry   RetryPolicy
+	breaker *circuitBreaker
+	sleep   func(ctx context.Context, d time.Duration) error
+}
+
+// retryableError - провайдер недоступен (сеть, 5xx, 429), запрос можно повторить. retryAfter < 0 - сервер не указал
+type retryableError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// badResponseError - провайдер ответил, но ответ не годится: 4xx, кроме 404, или неразбираемое тело. Повторять
+// бессмысленно, но автомат это считает. 404 - нормальный ответ про неизвестную провайдеру валюту или дату
+type badResponseError struct {
+	err error
+}
+
+func (e *badResponseError) Error() string {
+	return e.err.Error()
+}
+
+func (e *badResponseError) Unwrap() error {
+	return e.err
+}
+
+func newHttpSource(service string, requestTimeout time.Duration) *httpSource {
+	return &httpSource{
+		service: service,
+		client: &http.Client{
+			Timeout: requestTimeout,
+		},
+		retry:   RetryPolicy{MaxAttempts: 1},
+		breaker: newCircuitBreaker(service, BreakerPolicy{}),
+		sleep:   sleepContext,
+	}
+}
+
+// sleepContext - time.Sleep, который прерывается отменой ctx
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (h *httpSource) setPolicy(retry RetryPolicy, breaker BreakerPolicy) {
+	h.retry = retry
+	h.breaker = newCircuitBreaker(h.service, breaker)
+}
+
+// get вызывает decode с телом ответа, если статус 200. Недоступность провайдера повторяется по retry, остальные
+// ответы (4xx, неразбираемое тело) возвращаются сразу. Автомат считает успехом только удачный вызов, а отмену ctx
+// вызывающим не считает вовсе. Повторы не переживают ctx: после его отмены и если до дедлайна ctx не дождаться
+// следующей попытки, get возвращает последнюю ошибку
+func (h *httpSource) get(ctx context.Context, url string, decode func(body io.Reader) error) error {
+	if !h.breaker.allow() {
+		return fmt.Errorf("%w: %s", ErrCircuitOpen, h.service)
+	}
+
+	attempts := max(h.retry.MaxAttempts, 1)
+
+	for attempt := 1; ; attempt++ {
+		err := h.attempt(ctx, url, decode)
+
+		if err == nil {
+			h.breaker.record(true)
+
+			return nil
+		}
+
+		var retryable *retryableError
+		var badResponse *badResponseError
+
+		switch {
+		case errors.As(err, &badResponse):
+			h.fail(ctx)
+
+			return err
+		case !errors.As(err, &retryable):
+			// до провайдера не дошли, его вины нет
+			h.breaker.release()
+
+			return err
+		}
+
+		if attempt >= attempts || ctx.Err() != nil {
+			h.fail(ctx)
+
+			return fmt.Errorf("%w after %d attempts", err, attempt)
+		}
+
+		wait := h.retry.Backoff(attempt)
+
+		if retryable.retryAfter >= 0 {
+			// ждать дольше MaxBackoff в цикле обработки запросов не будем, пусть разбирается автомат
+			if retryable.retryAfter > h.retry.MaxBackoff {
+				h.fail(ctx)
+
+				return fmt.Errorf("%w, retry after %s exceeds max backoff", err, retryable.retryAfter)
+			}
+
+			wait = retryable.retryAfter
+		}
+
+		if deadline, exists := ctx.Deadline(); exists && time.Until(deadline) < wait {
+			h.fail(ctx)
+
+			return fmt.Errorf("%w, retry in %s exceeds caller deadline", err, wait)
+		}
+
+		retriesTotal.WithLabelValues(h.service).Inc()
+
+		if sleepErr := h.sleep(ctx, wait); sleepErr != nil {
+			h.fail(ctx)
+
+			return fmt.Errorf("%w, retry interrupted: %w", err, sleepErr)
+		}
+	}
+}
+
+// fail - неудача провайдера для автомата, если только вызывающий не отменил ctx (например, при остановке сервиса)
+func (h *httpSource) fail(ctx context.Context) {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		h.breaker.release()
+
+		return
+	}
+
+	h.breaker.record(false)
+}
+
+func (h *httpSource) attempt(ctx context.Context, url string, decode func(body io.Reader) error) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	start := time.Now()
+	resp, err := h.client.Do(request)
+	duration := time.Since(start).Seconds()
+
+	requestDuration.WithLabelValues("GET", h.service).Observe(duration)
+
+	if err != nil {
+		requestsTotal.WithLabelValues("GET", "error", h.service).Inc()
+
+		return &retryableError{err: fmt.Errorf("failed to fetch rate: %w", err), retryAfter: -1}
+	}
+
+	defer func() {
+		err := resp.Body.Close()
+
+		if err != nil {
+			sl.Log.Error("failed to close response body", sl.Err(err))
+		}
+	}()
+
+	requestsTotal.WithLabelValues("GET", fmt.Sprint(resp.StatusCode), h.service).Inc()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("API request failed with status: %d", resp.StatusCode)
+
+		if resp.StatusCode == http.StatusNotFound {
+			return err
+		}
+
+		if !isRetryableStatus(resp.StatusCode) {
+			return &badResponseError{err: err}
+		}
+
+		after, exists := retryAfter(resp, time.Now())
+
+		if !exists {
+			after = -1
+		}
+
+		return &retryableError{err: err, retryAfter: after}
+	}
+
+	if err := decode(resp.Body); err != nil {
+		return &badResponseError{err: fmt.Errorf("failed to decode response: %w", err)}
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package currency_conversion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"plata_currency_quotation/internal/domain/types"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const jsonProvider = "json"
+
+// JsonApi - настраиваемый REST провайдер. В шаблонах url подставляются {base}, {symbols} (через запятую)
+// и {date} (YYYY-MM-DD). ratesPath - путь через точку до объекта валюта -> курс, например "data.rates" или "$.rates".
+// Курсы могут быть числами или строками, числа не проходят через float64
+type JsonApi struct {
+	latestUrl     string
+	historicalUrl string
+	ratesPath     []string
+	source        *httpSource
+}
+
+func NewJsonApi(latestUrl string, historicalUrl string, ratesPath string, requestTimeout time.Duration) *JsonApi {
+	return &JsonApi{
+		latestUrl:     latestUrl,
+		historicalUrl: historicalUrl,
+		ratesPath:     splitRatesPath(ratesPath),
+		source:        newHttpSource(jsonProvider, requestTimeout),
+	}
+}
+
+func splitRatesPath(path string) []string {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+
+	if path == "" {
+		return nil
+	}
+
+	return strings.Split(path, ".")
+}
+
+func (j *JsonApi) SetupMetrics(reg *prometheus.Registry) {
+	registerHttpMetrics(reg)
+}
+
+func (j *JsonApi) SetPolicy(retry RetryPolicy, breaker BreakerPolicy) {
+	j.source.setPolicy(retry, breaker)
+}
+
+func (j *JsonApi) GetLatestRates(ctx context.Context, base types.Currency, quotes []types.Currency) ([]CurrencyRate, error) {
+	return j.fetch(ctx, j.latestUrl, base, quotes, time.Now())
+}
+
+func (j *JsonApi) GetRatesOn(ctx context.Context, base types.Currency, quotes []types.Currency, date time.Time) ([]CurrencyRate, error) {
+	if j.historicalUrl == "" {
+		return nil, fmt.Errorf("%w: historical url is not configured", ErrRateNotFound)
+	}
+
+	return j.fetch(ctx, j.historicalUrl, base, quotes, date)
+}
+
+func (j *JsonApi) GetRatesRange(ctx context.Context, base types.Currency, quotes []types.Currency, from time.Time, to time.Time) ([]CurrencyRate, error) {
+	var rates []CurrencyRate
+
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		converted, err := j.GetRatesOn(ctx, base, quotes, date)
+
+		if err != nil && len(converted) == 0 {
+			return rates, err
+		}
+
+		rates = append(rates, converted...)
+	}
+
+	return rates, nil
+}
+
+func (j *JsonApi) fetch(ctx context.Context, template string, base types.Currency, quotes []types.Currency, at time.Time) ([]CurrencyRate, error) {
+	symbols := make([]string, 0, len(quotes))
+
+	for _, quote := range quotes {
+		symbols = append(symbols, string(quote))
+	}
+
+	target := strings.NewReplacer(
+		"{base}", url.QueryEscape(string(base)),
+		"{symbols}", url.QueryEscape(strings.Join(symbols, ",")),
+		"{date}", at.Format(time.DateOnly),
+	).Replace(template)
+
+	var values map[string]any
+
+	err := j.source.get(ctx, target, func(body io.Reader) error {
+		var err error
+		values, err = j.extractRates(body)
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var rates []CurrencyRate
+	var missing []string
+
+	for _, quote := range quotes {
+		rate, err := parseRate(values[string(quote)])
+
+		if err != nil {
+			missing = append(missing, string(base+"/"+quote))
+
+			continue
+		}
+
+		rates = append(rates, CurrencyRate{
+			Rate:     rate,
+			Time:     at,
+			Currency: quote,
+			Provider: jsonProvider,
+		})
+	}
+
+	if len(missing) > 0 {
+		return rates, fmt.Errorf("%w for %s", ErrRateNotFound, strings.Join(missing, ", "))
+	}
+
+	return rates, nil
+}
+
+func (j *JsonApi) extractRates(body io.Reader) (map[string]any, error) {
+	decoder := json.NewDecoder(body)
+	decoder.UseNumber()
+
+	var node any
+
+	if err := decoder.Decode(&node); err != nil {
+		return nil, err
+	}
+
+	for _, key := range j.ratesPath {
+		object, ok := node.(map[string]any)
+
+		if !ok {
+			return nil, fmt.Errorf("rates path: %q is not an object", key)
+		}
+
+		node = object[key]
+	}
+
+	rates, ok := node.(map[string]any)
+
+	if !ok {
+		return nil, fmt.Errorf("rates path %q does not point to an object", strings.Join(j.ratesPath, "."))
+	}
+
+	return rates, nil
+}
+
+func parseRate(value any) (types.Decimal, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return types.ParseDecimal(v.String())
+	case string:
+		return types.ParseDecimal(v)
+	default:
+		return types.Decimal{}, fmt.Errorf("unexpected rate %v", value)
+	}
+}
@@ -1,6 +1,7 @@
 package currency_conversion
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"plata_currency_quotation/internal/domain/types"
@@ -19,12 +20,35 @@ func (m *Mock) SetupMetrics(_ *prometheus.Registry) {
 
 }
 
-func (m *Mock) GetLatestRates(_ types.Currency, quotes []types.Currency) ([]CurrencyRate, error) {
+func (m *Mock) GetLatestRates(_ context.Context, _ types.Currency, quotes []types.Currency) ([]CurrencyRate, error) {
+	return randomRates(quotes, time.Now()), nil
+}
+
+func (m *Mock) GetRatesOn(_ context.Context, _ types.Currency, quotes []types.Currency, date time.Time) ([]CurrencyRate, error) {
+	return randomRates(quotes, date), nil
+}
+
+func (m *Mock) GetRatesRange(_ context.Context, _ types.Currency, quotes []types.Currency, from time.Time, to time.Time) ([]CurrencyRate, error) {
 	var rates []CurrencyRate
 
-	for _, quote := range quotes {
-		rates = append(rates, CurrencyRate{Rate: fmt.Sprintf("%.2f", 0.1+rand.Float64()*2000.), Time: time.Now(), Currency: quote})
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		rates = append(rates, randomRates(quotes, date)...)
 	}
 
 	return rates, nil
 }
+
+func randomRates(quotes []types.Currency, at time.Time) []CurrencyRate {
+	var rates []CurrencyRate
+
+	for _, quote := range quotes {
+		rates = append(rates, CurrencyRate{
+			Rate:     types.MustParseDecimal(fmt.Sprintf("%.2f", 0.1+rand.Float64()*2000.)),
+			Time:     at,
+			Currency: quote,
+			Provider: "mock",
+		})
+	}
+
+	return rates
+}
@@ -0,0 +1,193 @@
+package currency_conversion
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var ErrCircuitOpen = errors.New("provider circuit is open")
+
+const (
+	circuitClosed   = "closed"
+	circuitOpen     = "open"
+	circuitHalfOpen = "half_open"
+)
+
+var circuitStates = []string{circuitClosed, circuitOpen, circuitHalfOpen}
+
+var (
+	circuitState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "rate_provider_circuit_state",
+			Help: "Circuit breaker state of the rate provider, 1 for the current state and 0 for the others",
+		},
+		[]string{"service", "state"},
+	)
+
+	retriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "outgoing_http_retries_total",
+			Help: "Total number of retried outgoing HTTP requests",
+		},
+		[]string{"service"},
+	)
+)
+
+// RetryPolicy - повтор при сетевой ошибке, 5xx и 429. MaxAttempts включает первую попытку, 1 - без повторов
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// Backoff - задержка перед попыткой attempt + 1: экспонента от InitialBackoff до MaxBackoff, из нее случайно
+// берется от половины до целого, чтобы реплики не повторяли запросы одновременно
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+
+	for i := 1; i < attempt && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	backoff = min(backoff, p.MaxBackoff)
+
+	if backoff <= 0 {
+		return 0
+	}
+
+	return backoff/2 + rand.N(backoff/2+1)
+}
+
+// BreakerPolicy - после FailureThreshold неудачных вызовов подряд провайдер не вызывается OpenTimeout,
+// потом пропускается один пробный вызов. FailureThreshold = 0 - без автомата
+type BreakerPolicy struct {
+	FailureThreshold int
+	OpenTimeout      time.Duration
+}
+
+type circuitBreaker struct {
+	service  string
+	policy   BreakerPolicy
+	mutex    sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+	now      func() time.Time
+}
+
+func newCircuitBreaker(service string, policy BreakerPolicy) *circuitBreaker {
+	breaker := &circuitBreaker{service: service, policy: policy, now: time.Now}
+	breaker.setState(circuitClosed)
+
+	return breaker
+}
+
+// allow - можно ли сейчас вызвать провайдера. В half_open пропускается только один вызов до его результата
+func (c *circuitBreaker) allow() bool {
+	if c.policy.FailureThreshold <= 0 {
+		return true
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.state == circuitOpen && c.now().Sub(c.openedAt) >= c.policy.OpenTimeout {
+		c.setState(circuitHalfOpen)
+	}
+
+	switch c.state {
+	case circuitClosed:
+		return true
+	case circuitHalfOpen:
+		if c.probing {
+			return false
+		}
+
+		c.probing = true
+
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *circuitBreaker) record(success bool) {
+	if c.policy.FailureThreshold <= 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.probing = false
+
+	if success {
+		c.failures = 0
+		c.setState(circuitClosed)
+
+		return
+	}
+
+	c.failures++
+
+	if c.state == circuitHalfOpen || c.failures >= c.policy.FailureThreshold {
+		c.openedAt = c.now()
+		c.setState(circuitOpen)
+	}
+}
+
+// release - вызов закончился без вердикта о провайдере: состояние не меняется, half_open снова пускает пробный вызов
+func (c *circuitBreaker) release() {
+	if c.policy.FailureThreshold <= 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.probing = false
+}
+
+func (c *circuitBreaker) setState(state string) {
+	c.state = state
+
+	for _, s := range circuitStates {
+		value := 0.0
+
+		if s == state {
+			value = 1
+		}
+
+		circuitState.WithLabelValues(c.service, s).Set(value)
+	}
+}
+
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// retryAfter - заголовок Retry-After в секундах или HTTP-дате
+func retryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+
+	return 0, false
+}
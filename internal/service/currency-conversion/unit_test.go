@@ -0,0 +1,797 @@
+package currency_conversion
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"plata_currency_quotation/internal/domain/types"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFixtureServer(t *testing.T, routes map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, exists := routes[r.URL.Path]
+
+		if !exists {
+			t.Errorf("unexpected request %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+}
+
+func Test_FrankfurterGetRatesOn(t *testing.T) {
+	server := newFixtureServer(t, map[string]string{
+		"/v1/2025-03-30": `{"amount":1.0,"base":"EUR","date":"2025-03-28","rates":{"MXN":21.9,"USD":1.0816}}`,
+	})
+	defer server.Close()
+
+	api := NewFrankfurterApi(server.URL, time.Second)
+
+	rates, err := api.GetRatesOn(context.Background(), types.EUR, []types.Currency{types.USD, types.MXN}, time.Date(2025, 3, 30, 0, 0, 0, 0, time.UTC))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []CurrencyRate{
+		{Rate: types.MustParseDecimal("1.0816"), Time: time.Date(2025, 3, 28, 0, 0, 0, 0, time.UTC), Currency: types.USD, Provider: "frankfurter"},
+		{Rate: types.MustParseDecimal("21.9"), Time: time.Date(2025, 3, 28, 0, 0, 0, 0, time.UTC), Currency: types.MXN, Provider: "frankfurter"},
+	}, rates)
+}
+
+func Test_FrankfurterGetRatesRange(t *testing.T) {
+	server := newFixtureServer(t, map[string]string{
+		"/v1/2025-03-28..2025-03-31": `{"amount":1.0,"base":"EUR","start_date":"2025-03-28","end_date":"2025-03-31",
+			"rates":{"2025-03-31":{"USD":1.0815},"2025-03-28":{"USD":1.0816}}}`,
+	})
+	defer server.Close()
+
+	api := NewFrankfurterApi(server.URL, time.Second)
+
+	rates, err := api.GetRatesRange(context.Background(), types.EUR,
+		[]types.Currency{types.USD},
+		time.Date(2025, 3, 28, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC),
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []CurrencyRate{
+		{Rate: types.MustParseDecimal("1.0816"), Time: time.Date(2025, 3, 28, 0, 0, 0, 0, time.UTC), Currency: types.USD, Provider: "frankfurter"},
+		{Rate: types.MustParseDecimal("1.0815"), Time: time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC), Currency: types.USD, Provider: "frankfurter"},
+	}, rates)
+}
+
+func Test_FrankfurterMissingRate(t *testing.T) {
+	server := newFixtureServer(t, map[string]string{
+		"/v1/latest": `{"amount":1.0,"base":"EUR","date":"2025-03-28","rates":{"USD":1.0816}}`,
+	})
+	defer server.Close()
+
+	api := NewFrankfurterApi(server.URL, time.Second)
+
+	rates, err := api.GetLatestRates(context.Background(), types.EUR, []types.Currency{types.USD, types.MXN})
+
+	assert.ErrorIs(t, err, ErrRateNotFound)
+	assert.Len(t, rates, 1)
+	assert.Equal(t, types.USD, rates[0].Currency)
+}
+
+type stubProvider struct {
+	Mock
+	calls int
+	delay time.Duration
+	err   error
+	rates map[types.Currency]string
+}
+
+func (s *stubProvider) GetLatestRates(ctx context.Context, _ types.Currency, quotes []types.Currency) ([]CurrencyRate, error) {
+	s.calls++
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(s.delay):
+	}
+
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	var rates []CurrencyRate
+
+	for _, quote := range quotes {
+		if rate, exists := s.rates[quote]; exists {
+			rates = append(rates, CurrencyRate{Rate: types.MustParseDecimal(rate), Currency: quote})
+		}
+	}
+
+	if len(rates) < len(quotes) {
+		return rates, ErrRateNotFound
+	}
+
+	return rates, nil
+}
+
+func Test_FailoverOnError(t *testing.T) {
+	primary := &stubProvider{err: errors.New("boom")}
+	secondary := &stubProvider{rates: map[types.Currency]string{types.MXN: "20.1"}}
+
+	failover := NewFailover(time.Minute, time.Second,
+		NamedProvider{Name: "primary", Provider: primary},
+		NamedProvider{Name: "secondary", Provider: secondary},
+	)
+
+	rates, err := failover.GetLatestRates(context.Background(), types.USD, []types.Currency{types.MXN})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []CurrencyRate{{Rate: types.MustParseDecimal("20.1"), Currency: types.MXN, Provider: "secondary"}}, rates)
+}
+
+func Test_FailoverOnTimeout(t *testing.T) {
+	primary := &stubProvider{delay: 200 * time.Millisecond, rates: map[types.Currency]string{types.MXN: "1"}}
+	secondary := &stubProvider{rates: map[types.Currency]string{types.MXN: "20.1"}}
+
+	failover := NewFailover(time.Minute, 20*time.Millisecond,
+		NamedProvider{Name: "primary", Provider: primary},
+		NamedProvider{Name: "secondary", Provider: secondary},
+	)
+
+	rates, err := failover.GetLatestRates(context.Background(), types.USD, []types.Currency{types.MXN})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "secondary", rates[0].Provider)
+}
+
+func Test_FailoverTimeoutCancelsRequest(t *testing.T) {
+	cancelled := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		close(cancelled)
+	}))
+	defer server.Close()
+
+	failover := NewFailover(time.Minute, 20*time.Millisecond,
+		NamedProvider{Name: "primary", Provider: NewFrankfurterApi(server.URL, time.Minute)},
+		NamedProvider{Name: "secondary", Provider: &stubProvider{rates: map[types.Currency]string{types.MXN: "20.1"}}},
+	)
+
+	rates, err := failover.GetLatestRates(context.Background(), types.USD, []types.Currency{types.MXN})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "secondary", rates[0].Provider)
+
+	// запрос к зависшему провайдеру оборван, а не брошен работать в фоне
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("request to the timed out provider is still running")
+	}
+}
+
+func Test_FailoverMissingSymbols(t *testing.T) {
+	primary := &stubProvider{rates: map[types.Currency]string{types.EUR: "0.9"}}
+	secondary := &stubProvider{rates: map[types.Currency]string{types.EUR: "0.95", types.MXN: "20.1"}}
+
+	failover := NewFailover(time.Minute, time.Second,
+		NamedProvider{Name: "primary", Provider: primary},
+		NamedProvider{Name: "secondary", Provider: secondary},
+	)
+
+	rates, err := failover.GetLatestRates(context.Background(), types.USD, []types.Currency{types.EUR, types.MXN})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []CurrencyRate{
+		{Rate: types.MustParseDecimal("0.9"), Currency: types.EUR, Provider: "primary"},
+		{Rate: types.MustParseDecimal("20.1"), Currency: types.MXN, Provider: "secondary"},
+	}, rates)
+
+	// Отсутствующий символ - не повод выводить провайдера из ротации
+	_, _ = failover.GetLatestRates(context.Background(), types.USD, []types.Currency{types.EUR})
+	assert.Equal(t, 2, primary.calls)
+}
+
+func Test_FailoverCooldown(t *testing.T) {
+	now := time.Date(2025, 3, 28, 12, 0, 0, 0, time.UTC)
+	primary := &stubProvider{err: errors.New("boom")}
+	secondary := &stubProvider{rates: map[types.Currency]string{types.MXN: "20.1"}}
+
+	failover := NewFailover(time.Minute, time.Second,
+		NamedProvider{Name: "primary", Provider: primary},
+		NamedProvider{Name: "secondary", Provider: secondary},
+	)
+	failover.now = func() time.Time { return now }
+
+	_, _ = failover.GetLatestRates(context.Background(), types.USD, []types.Currency{types.MXN})
+	_, _ = failover.GetLatestRates(context.Background(), types.USD, []types.Currency{types.MXN})
+	assert.Equal(t, 1, primary.calls)
+
+	now = now.Add(time.Minute)
+	primary.err = nil
+	primary.rates = map[types.Currency]string{types.MXN: "20"}
+
+	rates, err := failover.GetLatestRates(context.Background(), types.USD, []types.Currency{types.MXN})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, primary.calls)
+	assert.Equal(t, "primary", rates[0].Provider)
+}
+
+func Test_FailoverAllFailed(t *testing.T) {
+	failover := NewFailover(time.Minute, time.Second,
+		NamedProvider{Name: "primary", Provider: &stubProvider{err: errors.New("boom")}},
+		NamedProvider{Name: "secondary", Provider: &stubProvider{}},
+	)
+
+	rates, err := failover.GetLatestRates(context.Background(), types.USD, []types.Currency{types.MXN})
+
+	assert.Empty(t, rates)
+	assert.ErrorIs(t, err, ErrRateNotFound)
+	assert.ErrorContains(t, err, "boom")
+}
+
+func decimalPtr(value string) *types.Decimal {
+	d := types.MustParseDecimal(value)
+
+	return &d
+}
+
+func Test_ConsensusRejectsOutliers(t *testing.T) {
+	consensus := NewConsensus(0.05, 2, time.Second,
+		NamedProvider{Name: "a", Provider: &stubProvider{rates: map[types.Currency]string{types.MXN: "20.00"}}},
+		NamedProvider{Name: "b", Provider: &stubProvider{rates: map[types.Currency]string{types.MXN: "20.1"}}},
+		NamedProvider{Name: "c", Provider: &stubProvider{rates: map[types.Currency]string{types.MXN: "25"}}},
+		NamedProvider{Name: "d", Provider: &stubProvider{err: errors.New("boom")}},
+	)
+
+	rates, err := consensus.GetLatestRates(context.Background(), types.USD, []types.Currency{types.MXN})
+
+	assert.NoError(t, err)
+	assert.Len(t, rates, 1)
+	assert.Equal(t, "20.050", rates[0].Rate.String())
+	assert.Equal(t, "consensus", rates[0].Provider)
+	assert.Equal(t, &types.RateDetail{
+		Confidence: 0.5,
+		Sources: []types.RateSource{
+			{Provider: "a", Rate: decimalPtr("20.00"), Accepted: true},
+			{Provider: "b", Rate: decimalPtr("20.1"), Accepted: true},
+			{Provider: "c", Rate: decimalPtr("25"), Accepted: false},
+			{Provider: "d", Error: "boom"},
+		},
+	}, rates[0].Detail)
+}
+
+func Test_ConsensusOddMedianKeepsSourceRate(t *testing.T) {
+	consensus := NewConsensus(0.01, 1, time.Second,
+		NamedProvider{Name: "a", Provider: &stubProvider{rates: map[types.Currency]string{types.MXN: "20.10"}}},
+		NamedProvider{Name: "b", Provider: &stubProvider{rates: map[types.Currency]string{types.MXN: "20.1"}}},
+		NamedProvider{Name: "c", Provider: &stubProvider{rates: map[types.Currency]string{types.MXN: "20.12"}}},
+	)
+
+	rates, err := consensus.GetLatestRates(context.Background(), types.USD, []types.Currency{types.MXN})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "20.10", rates[0].Rate.String())
+	assert.Equal(t, 1., rates[0].Detail.Confidence)
+}
+
+func Test_ConsensusMedianTieTakesPriorityProvider(t *testing.T) {
+	consensus := NewConsensus(0.01, 1, time.Second,
+		NamedProvider{Name: "a", Provider: &stubProvider{rates: map[types.Currency]string{types.MXN: "20.1"}}},
+		NamedProvider{Name: "b", Provider: &stubProvider{rates: map[types.Currency]string{types.MXN: "20.10"}}},
+		NamedProvider{Name: "c", Provider: &stubProvider{rates: map[types.Currency]string{types.MXN: "20.12"}}},
+	)
+
+	rates, err := consensus.GetLatestRates(context.Background(), types.USD, []types.Currency{types.MXN})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "20.1", rates[0].Rate.String())
+}
+
+func Test_ConsensusNotEnoughSources(t *testing.T) {
+	consensus := NewConsensus(0.01, 2, time.Second,
+		NamedProvider{Name: "a", Provider: &stubProvider{rates: map[types.Currency]string{types.MXN: "20", types.EUR: "0.9"}}},
+		NamedProvider{Name: "b", Provider: &stubProvider{rates: map[types.Currency]string{types.MXN: "20"}}},
+	)
+
+	rates, err := consensus.GetLatestRates(context.Background(), types.USD, []types.Currency{types.MXN, types.EUR})
+
+	assert.ErrorIs(t, err, ErrNoConsensus)
+	assert.ErrorIs(t, err, ErrRateNotFound)
+	assert.Len(t, rates, 1)
+	assert.Equal(t, types.MXN, rates[0].Currency)
+}
+
+const ecbDailyFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<Cube>
+		<Cube time="2025-03-28">
+			<Cube currency="USD" rate="1.0816"/>
+			<Cube currency="MXN" rate="21.9"/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`
+
+const ecbHistoryFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<Cube>
+		<Cube time="2025-03-31"><Cube currency="USD" rate="1.0815"/><Cube currency="MXN" rate="22.0"/></Cube>
+		<Cube time="2025-03-28"><Cube currency="USD" rate="1.0816"/><Cube currency="MXN" rate="21.9"/></Cube>
+	</Cube>
+</gesmes:Envelope>`
+
+func Test_EcbGetLatestRates(t *testing.T) {
+	server := newFixtureServer(t, map[string]string{"/daily.xml": ecbDailyFixture})
+	defer server.Close()
+
+	api := NewEcbApi(server.URL+"/daily.xml", server.URL+"/hist.xml", time.Second)
+
+	rates, err := api.GetLatestRates(context.Background(), types.EUR, []types.Currency{types.USD, types.MXN})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []CurrencyRate{
+		{Rate: types.MustParseDecimal("1.0816"), Time: time.Date(2025, 3, 28, 0, 0, 0, 0, time.UTC), Currency: types.USD, Provider: "ecb"},
+		{Rate: types.MustParseDecimal("21.9"), Time: time.Date(2025, 3, 28, 0, 0, 0, 0, time.UTC), Currency: types.MXN, Provider: "ecb"},
+	}, rates)
+}
+
+func Test_EcbCrossRates(t *testing.T) {
+	server := newFixtureServer(t, map[string]string{"/daily.xml": ecbDailyFixture})
+	defer server.Close()
+
+	api := NewEcbApi(server.URL+"/daily.xml", server.URL+"/hist.xml", time.Second)
+
+	rates, err := api.GetLatestRates(context.Background(), types.USD, []types.Currency{types.EUR, types.MXN, "GBP"})
+
+	assert.ErrorIs(t, err, ErrRateNotFound)
+	assert.Equal(t, []CurrencyRate{
+		{Rate: types.MustParseDecimal("0.9245562130"), Time: time.Date(2025, 3, 28, 0, 0, 0, 0, time.UTC), Currency: types.EUR, Provider: "ecb"},
+		{Rate: types.MustParseDecimal("20.2477810651"), Time: time.Date(2025, 3, 28, 0, 0, 0, 0, time.UTC), Currency: types.MXN, Provider: "ecb"},
+	}, rates)
+}
+
+func Test_EcbGetRatesOnWeekend(t *testing.T) {
+	server := newFixtureServer(t, map[string]string{"/hist.xml": ecbHistoryFixture})
+	defer server.Close()
+
+	api := NewEcbApi(server.URL+"/daily.xml", server.URL+"/hist.xml", time.Second)
+
+	rates, err := api.GetRatesOn(context.Background(), types.EUR, []types.Currency{types.USD}, time.Date(2025, 3, 30, 0, 0, 0, 0, time.UTC))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []CurrencyRate{
+		{Rate: types.MustParseDecimal("1.0816"), Time: time.Date(2025, 3, 28, 0, 0, 0, 0, time.UTC), Currency: types.USD, Provider: "ecb"},
+	}, rates)
+}
+
+func Test_JsonApi(t *testing.T) {
+	var requested string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.String()
+		_, _ = w.Write([]byte(`{"success":true,"data":{"base":"USD","rates":{"MXN":20.123456789012345678,"EUR":"0.92"}}}`))
+	}))
+	defer server.Close()
+
+	api := NewJsonApi(
+		server.URL+"/latest?from={base}&to={symbols}",
+		server.URL+"/historical/{date}?from={base}&to={symbols}",
+		"$.data.rates",
+		time.Second,
+	)
+
+	rates, err := api.GetLatestRates(context.Background(), types.USD, []types.Currency{types.MXN, types.EUR})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/latest?from=USD&to=MXN%2CEUR", requested)
+	assert.Equal(t, "20.123456789012345678", rates[0].Rate.String())
+	assert.Equal(t, "0.92", rates[1].Rate.String())
+	assert.Equal(t, "json", rates[0].Provider)
+
+	rates, err = api.GetRatesOn(context.Background(), types.USD, []types.Currency{types.MXN, "GBP"}, time.Date(2025, 3, 28, 0, 0, 0, 0, time.UTC))
+
+	assert.ErrorIs(t, err, ErrRateNotFound)
+	assert.Equal(t, "/historical/2025-03-28?from=USD&to=MXN%2CGBP", requested)
+	assert.Len(t, rates, 1)
+}
+
+func Test_JsonApiBadPath(t *testing.T) {
+	server := newFixtureServer(t, map[string]string{"/latest": `{"rates":[1,2]}`})
+	defer server.Close()
+
+	api := NewJsonApi(server.URL+"/latest", "", "rates", time.Second)
+
+	_, err := api.GetLatestRates(context.Background(), types.USD, []types.Currency{types.MXN})
+
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrRateNotFound)
+}
+
+func Test_FileProviderCsvHotReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.csv")
+
+	assert.NoError(t, os.WriteFile(path, []byte("base,quote,rate,date\nUSD,MXN,20.1,\nUSD,MXN,19.5,2025-03-28\nUSD,EUR,0.92,2025-03-31\n"), 0o644))
+
+	provider := NewFileProvider(path)
+
+	rates, err := provider.GetLatestRates(context.Background(), types.USD, []types.Currency{types.MXN, types.EUR})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "20.1", rates[0].Rate.String())
+	assert.Equal(t, "0.92", rates[1].Rate.String())
+	assert.Equal(t, "file", rates[0].Provider)
+
+	rates, err = provider.GetRatesOn(context.Background(), types.USD, []types.Currency{types.MXN, types.EUR}, time.Date(2025, 3, 30, 0, 0, 0, 0, time.UTC))
+
+	assert.ErrorIs(t, err, ErrRateNotFound)
+	assert.Equal(t, []CurrencyRate{
+		{Rate: types.MustParseDecimal("19.5"), Time: time.Date(2025, 3, 28, 0, 0, 0, 0, time.UTC), Currency: types.MXN, Provider: "file"},
+	}, rates)
+
+	assert.NoError(t, os.WriteFile(path, []byte("base,quote,rate\nUSD,MXN,21.0\n"), 0o644))
+	assert.NoError(t, os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute)))
+
+	rates, err = provider.GetLatestRates(context.Background(), types.USD, []types.Currency{types.MXN})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "21.0", rates[0].Rate.String())
+
+	// битый файл не должен ломать уже загруженные курсы
+	assert.NoError(t, os.WriteFile(path, []byte("base,quote,rate\nUSD,MXN,oops\n"), 0o644))
+	assert.NoError(t, os.Chtimes(path, time.Now().Add(2*time.Minute), time.Now().Add(2*time.Minute)))
+
+	rates, err = provider.GetLatestRates(context.Background(), types.USD, []types.Currency{types.MXN})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "21.0", rates[0].Rate.String())
+}
+
+func Test_FileProviderJson(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+
+	assert.NoError(t, os.WriteFile(path, []byte(`[
+		{"base":"usd","quote":"mxn","rate":20.10,"date":"2025-03-28"},
+		{"base":"USD","quote":"MXN","rate":"20.2","date":"2025-03-31"}
+	]`), 0o644))
+
+	provider := NewFileProvider(path)
+
+	rates, err := provider.GetRatesRange(context.Background(), types.USD,
+		[]types.Currency{types.MXN},
+		time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC),
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []CurrencyRate{
+		{Rate: types.MustParseDecimal("20.10"), Time: time.Date(2025, 3, 28, 0, 0, 0, 0, time.UTC), Currency: types.MXN, Provider: "file"},
+		{Rate: types.MustParseDecimal("20.2"), Time: time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC), Currency: types.MXN, Provider: "file"},
+	}, rates)
+}
+
+func Test_SharedHttpMetricsRegistration(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	failover := NewFailover(time.Minute, time.Second,
+		NamedProvider{Name: "frankfurter", Provider: NewFrankfurterApi("http://localhost", time.Second)},
+		NamedProvider{Name: "ecb", Provider: NewEcbApi("http://localhost", "http://localhost", time.Second)},
+		NamedProvider{Name: "json", Provider: NewJsonApi("http://localhost", "", "rates", time.Second)},
+	)
+
+	assert.NotPanics(t, func() { failover.SetupMetrics(reg) })
+}
+
+const frankfurterLatest = `{"amount":1.0,"base":"EUR","date":"2025-03-28","rates":{"USD":1.0816}}`
+
+// newFaultyServer отвечает по очереди ответами из faults, когда они кончаются - frankfurterLatest.
+// Возвращает счетчик запросов
+func newFaultyServer(faults ...func(w http.ResponseWriter)) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(requests.Add(1)) - 1
+
+		if i < len(faults) {
+			faults[i](w)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(frankfurterLatest))
+	}))
+
+	return server, &requests
+}
+
+func withStatus(status int, headers ...string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		for i := 0; i+1 < len(headers); i += 2 {
+			w.Header().Set(headers[i], headers[i+1])
+		}
+
+		w.WriteHeader(status)
+	}
+}
+
+// newResilientApi - паузы между попытками не выполняются, а записываются
+func newResilientApi(url string, retry RetryPolicy, breaker BreakerPolicy) (*FrankfurterApi, *[]time.Duration) {
+	api := NewFrankfurterApi(url, time.Second)
+	api.SetPolicy(retry, breaker)
+
+	var waits []time.Duration
+
+	api.source.sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+
+		return nil
+	}
+
+	return api, &waits
+}
+
+func Test_RetryOnServerErrors(t *testing.T) {
+	server, requests := newFaultyServer(withStatus(http.StatusBadGateway), withStatus(http.StatusServiceUnavailable))
+	defer server.Close()
+
+	api, waits := newResilientApi(server.URL, RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second, MaxBackoff: time.Minute}, BreakerPolicy{})
+
+	rates, err := api.GetLatestRates(context.Background(), types.EUR, []types.Currency{types.USD})
+
+	assert.NoError(t, err)
+	assert.Len(t, rates, 1)
+	assert.Equal(t, int32(3), requests.Load())
+	assert.Len(t, *waits, 2)
+
+	// экспонента с jitter: от половины до целой паузы
+	assert.True(t, (*waits)[0] >= 500*time.Millisecond && (*waits)[0] <= time.Second, (*waits)[0])
+	assert.True(t, (*waits)[1] >= time.Second && (*waits)[1] <= 2*time.Second, (*waits)[1])
+}
+
+func Test_RetryGivesUp(t *testing.T) {
+	server, requests := newFaultyServer(
+		withStatus(http.StatusInternalServerError),
+		withStatus(http.StatusInternalServerError),
+		withStatus(http.StatusInternalServerError),
+	)
+	defer server.Close()
+
+	api, _ := newResilientApi(server.URL, RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Second, MaxBackoff: time.Minute}, BreakerPolicy{})
+
+	_, err := api.GetLatestRates(context.Background(), types.EUR, []types.Currency{types.USD})
+
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func Test_RetryHonoursRetryAfter(t *testing.T) {
+	server, requests := newFaultyServer(withStatus(http.StatusTooManyRequests, "Retry-After", "3"))
+	defer server.Close()
+
+	api, waits := newResilientApi(server.URL, RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}, BreakerPolicy{})
+
+	_, err := api.GetLatestRates(context.Background(), types.EUR, []types.Currency{types.USD})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), requests.Load())
+	assert.Equal(t, []time.Duration{3 * time.Second}, *waits)
+
+	// ждать дольше MaxBackoff не будем
+	server, requests = newFaultyServer(withStatus(http.StatusTooManyRequests, "Retry-After", "60"))
+	defer server.Close()
+
+	api, waits = newResilientApi(server.URL, RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}, BreakerPolicy{})
+
+	_, err = api.GetLatestRates(context.Background(), types.EUR, []types.Currency{types.USD})
+
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), requests.Load())
+	assert.Empty(t, *waits)
+}
+
+func Test_RetryBoundedByCallerDeadline(t *testing.T) {
+	server, requests := newFaultyServer(
+		withStatus(http.StatusInternalServerError),
+		withStatus(http.StatusInternalServerError),
+	)
+	defer server.Close()
+
+	api, waits := newResilientApi(server.URL, RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Minute, MaxBackoff: time.Minute}, BreakerPolicy{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// до следующей попытки не меньше 30s, вызывающий столько не ждет
+	_, err := api.GetLatestRates(ctx, types.EUR, []types.Currency{types.USD})
+
+	assert.ErrorContains(t, err, "exceeds caller deadline")
+	assert.Equal(t, int32(1), requests.Load())
+	assert.Empty(t, *waits)
+}
+
+func Test_RetrySleepStopsOnCancel(t *testing.T) {
+	server, requests := newFaultyServer(
+		withStatus(http.StatusInternalServerError),
+		withStatus(http.StatusInternalServerError),
+	)
+	defer server.Close()
+
+	api := NewFrankfurterApi(server.URL, time.Second)
+	api.SetPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Minute, MaxBackoff: time.Minute}, BreakerPolicy{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := api.GetLatestRates(ctx, types.EUR, []types.Currency{types.USD})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func Test_NoRetryOnClientError(t *testing.T) {
+	server, requests := newFaultyServer(withStatus(http.StatusBadRequest))
+	defer server.Close()
+
+	api, _ := newResilientApi(server.URL, RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second, MaxBackoff: time.Minute}, BreakerPolicy{})
+
+	_, err := api.GetLatestRates(context.Background(), types.EUR, []types.Currency{types.USD})
+
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func Test_RetryOnNetworkError(t *testing.T) {
+	var requests atomic.Int32
+
+	// обрываем соединение без ответа
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			assert.NoError(t, err)
+			_ = conn.Close()
+
+			return
+		}
+
+		_, _ = w.Write([]byte(frankfurterLatest))
+	}))
+	defer server.Close()
+
+	api, waits := newResilientApi(server.URL, RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Second, MaxBackoff: time.Minute}, BreakerPolicy{})
+
+	rates, err := api.GetLatestRates(context.Background(), types.EUR, []types.Currency{types.USD})
+
+	assert.NoError(t, err)
+	assert.Len(t, rates, 1)
+	assert.Len(t, *waits, 1)
+}
+
+func Test_CircuitBreaker(t *testing.T) {
+	server, requests := newFaultyServer(withStatus(http.StatusInternalServerError), withStatus(http.StatusInternalServerError))
+	defer server.Close()
+
+	api, _ := newResilientApi(server.URL, RetryPolicy{MaxAttempts: 1}, BreakerPolicy{FailureThreshold: 2, OpenTimeout: time.Minute})
+
+	now := time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC)
+	api.source.breaker.now = func() time.Time { return now }
+
+	state := func(state string) float64 {
+		return testutil.ToFloat64(circuitState.WithLabelValues(frankfurterProvider, state))
+	}
+
+	for range 2 {
+		_, err := api.GetLatestRates(context.Background(), types.EUR, []types.Currency{types.USD})
+		assert.Error(t, err)
+	}
+
+	assert.Equal(t, 1.0, state(circuitOpen))
+	assert.Equal(t, 0.0, state(circuitClosed))
+
+	// открытый автомат не пускает к провайдеру
+	_, err := api.GetLatestRates(context.Background(), types.EUR, []types.Currency{types.USD})
+
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(2), requests.Load())
+
+	// по истечении OpenTimeout пробный вызов проходит и закрывает автомат
+	now = now.Add(time.Minute)
+
+	rates, err := api.GetLatestRates(context.Background(), types.EUR, []types.Currency{types.USD})
+
+	assert.NoError(t, err)
+	assert.Len(t, rates, 1)
+	assert.Equal(t, int32(3), requests.Load())
+	assert.Equal(t, 1.0, state(circuitClosed))
+	assert.Equal(t, 0.0, state(circuitOpen))
+}
+
+func Test_CircuitBreakerHalfOpenFailure(t *testing.T) {
+	breaker := newCircuitBreaker("test", BreakerPolicy{FailureThreshold: 1, OpenTimeout: time.Minute})
+
+	now := time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC)
+	breaker.now = func() time.Time { return now }
+
+	assert.True(t, breaker.allow())
+	breaker.record(false)
+	assert.False(t, breaker.allow())
+
+	now = now.Add(time.Minute)
+
+	// в half_open пропускается только один вызов
+	assert.True(t, breaker.allow())
+	assert.False(t, breaker.allow())
+
+	breaker.record(false)
+
+	assert.False(t, breaker.allow())
+	assert.Equal(t, circuitOpen, breaker.state)
+}
+
+func Test_CircuitBreakerCountsBadResponses(t *testing.T) {
+	garbage := func(w http.ResponseWriter) {
+		_, _ = w.Write([]byte("<html>maintenance</html>"))
+	}
+
+	server, requests := newFaultyServer(withStatus(http.StatusNotFound), withStatus(http.StatusBadRequest), garbage)
+	defer server.Close()
+
+	api, _ := newResilientApi(server.URL, RetryPolicy{MaxAttempts: 3}, BreakerPolicy{FailureThreshold: 2, OpenTimeout: time.Minute})
+
+	// 404 - ответ про неизвестную пару, провайдер исправен
+	_, err := api.GetLatestRates(context.Background(), types.EUR, []types.Currency{types.USD})
+
+	assert.Error(t, err)
+	assert.Equal(t, 0, api.source.breaker.failures)
+
+	for range 2 {
+		_, err = api.GetLatestRates(context.Background(), types.EUR, []types.Currency{types.USD})
+		assert.Error(t, err)
+	}
+
+	_, err = api.GetLatestRates(context.Background(), types.EUR, []types.Currency{types.USD})
+
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func Test_CircuitBreakerIgnoresCancel(t *testing.T) {
+	server, requests := newFaultyServer(withStatus(http.StatusInternalServerError), withStatus(http.StatusInternalServerError))
+	defer server.Close()
+
+	api, _ := newResilientApi(server.URL, RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second, MaxBackoff: time.Minute}, BreakerPolicy{FailureThreshold: 1, OpenTimeout: time.Minute})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// вызывающий уходит во время паузы между попытками
+	api.source.sleep = func(ctx context.Context, _ time.Duration) error {
+		cancel()
+
+		return ctx.Err()
+	}
+
+	_, err := api.GetLatestRates(ctx, types.EUR, []types.Currency{types.USD})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, circuitClosed, api.source.breaker.state)
+
+	// отмена до первой попытки тоже не вина провайдера
+	_, err = api.GetLatestRates(ctx, types.EUR, []types.Currency{types.USD})
+
+	assert.Error(t, err)
+	assert.Equal(t, circuitClosed, api.source.breaker.state)
+	assert.Equal(t, int32(1), requests.Load())
+}
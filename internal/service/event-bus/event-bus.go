@@ -0,0 +1,28 @@
+package event_bus
+
+import (
+	"context"
+	"plata_currency_quotation/internal/domain/types"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var Instance Interface
+
+// QuotationUpdated - новый курс пары записан в бд. Origin - экземпляр, который его записал
+type QuotationUpdated struct {
+	Origin    string              `json:"origin"`
+	Base      types.Currency      `json:"base"`
+	Quote     types.Currency      `json:"quote"`
+	Quotation types.QuotationInfo `json:"quotation"`
+}
+
+type Interface interface {
+	SetupMetrics(reg *prometheus.Registry)
+	// PublishQuotationUpdated доставляет событие всем подписчикам всех экземпляров, включая отправителя
+	PublishQuotationUpdated(event QuotationUpdated) error
+	// SubscribeQuotationUpdated - обработчики вызываются последовательно, в порядке получения событий
+	SubscribeQuotationUpdated(handler func(event QuotationUpdated))
+	Start(ctx context.Context)
+	Stop()
+}
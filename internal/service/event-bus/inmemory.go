@@ -0,0 +1,49 @@
+package event_bus
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// InMemory - шина внутри процесса, для одного экземпляра и тестов. Несколько менеджеров на одной шине
+// ведут себя как реплики
+type InMemory struct {
+	mutex    sync.Mutex
+	handlers []func(event QuotationUpdated)
+}
+
+func NewInMemory() *InMemory {
+	return &InMemory{}
+}
+
+func (b *InMemory) SetupMetrics(_ *prometheus.Registry) {
+
+}
+
+func (b *InMemory) PublishQuotationUpdated(event QuotationUpdated) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, handler := range b.handlers {
+		handler(event)
+	}
+
+	return nil
+}
+
+func (b *InMemory) SubscribeQuotationUpdated(handler func(event QuotationUpdated)) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.handlers = append(b.handlers, handler)
+}
+
+func (b *InMemory) Start(_ context.Context) {
+
+}
+
+func (b *InMemory) Stop() {
+
+}
@@ -0,0 +1,190 @@
+package event_bus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"plata_currency_quotation/internal/lib/logger/sl"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	quotationUpdatedChannel = "quotation_updated"
+	// maxPayloadSize - NOTIFY принимает не больше 8000 байт
+	maxPayloadSize = 7900
+	publishTimeout = 5 * time.Second
+	reconnectDelay = time.Second
+	postgresBus    = "postgres"
+)
+
+var (
+	eventsPublished = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "event_bus_published_total",
+			Help: "Total number of published events by result",
+		},
+		[]string{"bus", "result"},
+	)
+
+	eventsReceived = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "event_bus_received_total",
+			Help: "Total number of received events",
+		},
+		[]string{"bus"},
+	)
+)
+
+// Postgres - шина на LISTEN/NOTIFY. Слушатель держит отдельное соединение и переподключается при обрыве,
+// события, отправленные пока соединения не было, теряются
+type Postgres struct {
+	dsn      string
+	pool     *pgxpool.Pool
+	mutex    sync.Mutex
+	handlers []func(event QuotationUpdated)
+	logger   *slog.Logger
+	stop     context.CancelFunc
+	done     sync.WaitGroup
+}
+
+func NewPostgres(dsn string) (*Postgres, error) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})).With(
+		"component", "service/event-bus/postgres",
+	)
+
+	pool, err := pgxpool.New(context.Background(), dsn)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create postgres pool: %w", err)
+	}
+
+	return &Postgres{dsn: dsn, pool: pool, logger: logger}, nil
+}
+
+func (b *Postgres) SetupMetrics(reg *prometheus.Registry) {
+	reg.MustRegister(eventsPublished)
+	reg.MustRegister(eventsReceived)
+}
+
+func (b *Postgres) PublishQuotationUpdated(event QuotationUpdated) error {
+	payload, err := json.Marshal(event)
+
+	if err != nil {
+		return err
+	}
+
+	// разбивка по источникам не обязательна, без нее курс все равно дойдет
+	if len(payload) > maxPayloadSize {
+		event.Quotation.Detail = nil
+
+		if payload, err = json.Marshal(event); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+
+	if _, err = b.pool.Exec(ctx, "SELECT pg_notify($1, $2)", quotationUpdatedChannel, string(payload)); err != nil {
+		eventsPublished.WithLabelValues(postgresBus, "error").Inc()
+
+		return fmt.Errorf("failed to notify: %w", err)
+	}
+
+	eventsPublished.WithLabelValues(postgresBus, "ok").Inc()
+
+	return nil
+}
+
+func (b *Postgres) SubscribeQuotationUpdated(handler func(event QuotationUpdated)) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.handlers = append(b.handlers, handler)
+}
+
+func (b *Postgres) Start(ctx context.Context) {
+	ctx, b.stop = context.WithCancel(ctx)
+
+	b.done.Add(1)
+
+	go func() {
+		defer b.done.Done()
+
+		for {
+			if err := b.listen(ctx); err != nil && ctx.Err() == nil {
+				b.logger.Error("event listener disconnected", sl.Err(err))
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(reconnectDelay):
+			}
+		}
+	}()
+}
+
+// Stop закрывает слушателя и пул, публиковать после этого нельзя
+func (b *Postgres) Stop() {
+	if b.stop != nil {
+		b.stop()
+		b.done.Wait()
+	}
+
+	b.pool.Close()
+}
+
+func (b *Postgres) listen(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, b.dsn)
+
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+
+	defer func() {
+		if err := conn.Close(context.Background()); err != nil {
+			b.logger.Error("failed to close listener connection", sl.Err(err))
+		}
+	}()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+quotationUpdatedChannel); err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		var event QuotationUpdated
+
+		if err := json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+			b.logger.Error("failed to decode event", sl.Err(err))
+
+			continue
+		}
+
+		eventsReceived.WithLabelValues(postgresBus).Inc()
+
+		b.dispatch(event)
+	}
+}
+
+func (b *Postgres) dispatch(event QuotationUpdated) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, handler := range b.handlers {
+		handler(event)
+	}
+}
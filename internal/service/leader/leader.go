@@ -0,0 +1,151 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"plata_currency_quotation/internal/lib/logger/sl"
+	"plata_currency_quotation/internal/persistence"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var Instance *Elector
+
+var isLeader = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "leader",
+		Help: "Whether this instance currently holds the leader lease (1) or not (0)",
+	},
+)
+
+type Interface interface {
+	IsLeader() bool
+}
+
+// Elector держит аренду name в бд. Аренда продлевается каждую треть ttl, так что лидер переживает пару
+// неудачных продлений, а после его падения другой экземпляр захватывает роль не позже чем через ttl
+type Elector struct {
+	name   string
+	holder string
+	ttl    time.Duration
+	db     persistence.LeasePersistentOperations
+	leader atomic.Bool
+	// validUntil - когда истечет аренда по нашим часам, считается от начала последнего удачного продления
+	validUntil atomic.Pointer[time.Time]
+	logger     *slog.Logger
+	stop       context.CancelFunc
+	done       sync.WaitGroup
+}
+
+func New(name string, ttl time.Duration, db persistence.LeasePersistentOperations) *Elector {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})).With(
+		"component", "service/leader",
+	)
+
+	hostname, _ := os.Hostname()
+
+	return &Elector{
+		name:   name,
+		holder: fmt.Sprintf("%s-%s", hostname, uuid.NewString()[:8]),
+		ttl:    ttl,
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (e *Elector) SetupMetrics(reg *prometheus.Registry) {
+	reg.MustRegister(isLeader)
+}
+
+// IsLeader - false и после истечения аренды, даже если продление еще не вернулось: к этому моменту роль мог
+// захватить другой экземпляр
+func (e *Elector) IsLeader() bool {
+	if !e.leader.Load() {
+		return false
+	}
+
+	validUntil := e.validUntil.Load()
+
+	return validUntil != nil && time.Now().Before(*validUntil)
+}
+
+func (e *Elector) Start(ctx context.Context) {
+	ctx, e.stop = context.WithCancel(ctx)
+
+	e.done.Add(1)
+
+	go func() {
+		defer e.done.Done()
+
+		for {
+			e.renew(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(e.ttl / 3):
+			}
+		}
+	}()
+}
+
+// Stop перестает продлевать аренду и отдает ее. Вызывать после остановки сервисов, которые работают только на лидере
+func (e *Elector) Stop() error {
+	if e.stop != nil {
+		e.stop()
+		e.done.Wait()
+	}
+
+	return e.Release()
+}
+
+// Release отдает аренду, чтобы другой экземпляр не ждал ее истечения
+func (e *Elector) Release() error {
+	e.setLeader(false)
+
+	return e.db.LeaseRelease(e.name, e.holder)
+}
+
+func (e *Elector) renew(ctx context.Context) {
+	started := time.Now()
+
+	// к следующему продлению ответ уже не нужен, а зависшая бд не должна держать роль
+	ctx, cancel := context.WithTimeout(ctx, e.ttl/3)
+	defer cancel()
+
+	acquired, err := e.db.LeaseAcquire(ctx, e.name, e.holder, e.ttl)
+
+	if err != nil {
+		// без ответа бд не знаем, продлена ли аренда, безопаснее считать, что нет
+		e.logger.Error("failed to renew leader lease", sl.Err(err))
+
+		acquired = false
+	}
+
+	if acquired {
+		validUntil := started.Add(e.ttl)
+		e.validUntil.Store(&validUntil)
+	}
+
+	e.setLeader(acquired)
+}
+
+func (e *Elector) setLeader(leader bool) {
+	if e.leader.Swap(leader) == leader {
+		return
+	}
+
+	if leader {
+		isLeader.Set(1)
+		e.logger.Info("became leader", slog.String("holder", e.holder))
+	} else {
+		isLeader.Set(0)
+		e.logger.Info("lost leadership", slog.String("holder", e.holder))
+	}
+}
@@ -0,0 +1,112 @@
+package leader
+
+import (
+	"context"
+	"plata_currency_quotation/internal/persistence/inmemory"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SingleLeader(t *testing.T) {
+	db := inmemory.New()
+
+	first := New("test", time.Duration(50)*time.Millisecond, db)
+	second := New("test", time.Duration(50)*time.Millisecond, db)
+
+	first.renew(context.Background())
+	second.renew(context.Background())
+
+	assert.True(t, first.IsLeader())
+	assert.False(t, second.IsLeader())
+
+	// продление держателем не отдает роль
+	first.renew(context.Background())
+	second.renew(context.Background())
+
+	assert.True(t, first.IsLeader())
+	assert.False(t, second.IsLeader())
+
+	assert.NoError(t, first.Release())
+
+	second.renew(context.Background())
+	first.renew(context.Background())
+
+	assert.False(t, first.IsLeader())
+	assert.True(t, second.IsLeader())
+}
+
+func Test_LeaseExpires(t *testing.T) {
+	db := inmemory.New()
+
+	first := New("test", time.Duration(20)*time.Millisecond, db)
+	second := New("test", time.Duration(20)*time.Millisecond, db)
+
+	first.renew(context.Background())
+	assert.True(t, first.IsLeader())
+
+	// лидер перестал продлевать аренду
+	time.Sleep(time.Duration(30) * time.Millisecond)
+
+	second.renew(context.Background())
+	assert.True(t, second.IsLeader())
+
+	first.renew(context.Background())
+	assert.False(t, first.IsLeader())
+}
+
+type stalledDb struct {
+	*inmemory.Db
+	stall atomic.Bool
+}
+
+func (s *stalledDb) LeaseAcquire(ctx context.Context, name string, holder string, ttl time.Duration) (bool, error) {
+	if s.stall.Load() {
+		<-ctx.Done()
+
+		return false, ctx.Err()
+	}
+
+	return s.Db.LeaseAcquire(ctx, name, holder, ttl)
+}
+
+func Test_StalledRenewLosesLeadership(t *testing.T) {
+	db := &stalledDb{Db: inmemory.New()}
+
+	elector := New("test", time.Duration(60)*time.Millisecond, db)
+
+	elector.renew(context.Background())
+	assert.True(t, elector.IsLeader())
+
+	// продление зависло: само обрывается через треть ttl, а роль пропадает, как только истекла аренда
+	db.stall.Store(true)
+
+	done := make(chan struct{})
+
+	go func() {
+		elector.renew(context.Background())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("renew was not cancelled")
+	}
+
+	assert.False(t, elector.IsLeader())
+}
+
+func Test_LeadershipEndsWithLease(t *testing.T) {
+	elector := New("test", time.Duration(20)*time.Millisecond, inmemory.New())
+
+	elector.renew(context.Background())
+	assert.True(t, elector.IsLeader())
+
+	// продлений больше нет, а setLeader(false) еще не вызывался
+	time.Sleep(time.Duration(30) * time.Millisecond)
+
+	assert.False(t, elector.IsLeader())
+}
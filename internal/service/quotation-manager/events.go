@@ -0,0 +1,47 @@
+package quotation_manager
+
+import (
+	"log/slog"
+	"plata_currency_quotation/internal/domain/types"
+	"plata_currency_quotation/internal/lib/logger/sl"
+	eb "plata_currency_quotation/internal/service/event-bus"
+)
+
+// SetEventBus - через шину экземпляры сообщают друг другу о записанных курсах, чтобы кэши не расходились
+func (q *QuotationManager) SetEventBus(bus eb.Interface) {
+	q.bus = bus
+
+	bus.SubscribeQuotationUpdated(q.applyEvent)
+}
+
+func (q *QuotationManager) publish(base types.Currency, quote types.Currency, quotation types.QuotationInfo) {
+	if q.bus == nil {
+		return
+	}
+
+	event := eb.QuotationUpdated{Origin: q.id, Base: base, Quote: quote, Quotation: quotation}
+
+	if err := q.bus.PublishQuotationUpdated(event); err != nil {
+		q.logger.Error("failed to publish quotation update", slog.String("pair", asKey(base, quote)), sl.Err(err))
+	}
+}
+
+// applyEvent - события могут прийти не по порядку, более старый курс кэш не перезаписывает. Курс с тем же временем
+// применяется: у ECB и файла время - полночь даты, и поправка курса за день приходит с тем же временем. Ожидающих
+// будим на любое событие, даже если кэш не поменялся: событие значит, что запросы пары завершены
+func (q *QuotationManager) applyEvent(event eb.QuotationUpdated) {
+	if event.Origin == q.id {
+		return
+	}
+
+	key := asKey(event.Base, event.Quote)
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if cached, exists := q.quotations[key]; !exists || !event.Quotation.UpdatedAt.Before(cached.UpdatedAt) {
+		q.quotations[key] = event.Quotation
+	}
+
+	q.notifyWaiters(key)
+}
@@ -1,27 +1,58 @@
 package quotation_manager
 
 import (
+	"cmp"
+	"context"
 	"log/slog"
 	"os"
+	qh "plata_currency_quotation/internal/domain/enity/quotation-history"
 	"plata_currency_quotation/internal/domain/types"
+	"plata_currency_quotation/internal/lib/clock"
 	"plata_currency_quotation/internal/lib/logger/sl"
 	"plata_currency_quotation/internal/persistence"
 	cc "plata_currency_quotation/internal/service/currency-conversion"
+	eb "plata_currency_quotation/internal/service/event-bus"
+	"plata_currency_quotation/internal/service/leader"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 var Instance *QuotationManager
 
+const defaultDebounce = 10 * time.Millisecond
+
 type QuotationManager struct {
-	runInterval     time.Duration
-	mutex           sync.RWMutex
-	quotations      map[string]types.QuotationInfo
-	db              persistence.Interface
-	currencyConvert cc.Interface
-	logger          *slog.Logger
-	runRequired     atomic.Bool
+	runInterval        time.Duration
+	mutex              sync.RWMutex
+	quotations         map[string]types.QuotationInfo
+	db                 persistence.Interface
+	currencyConvert    cc.Interface
+	logger             *slog.Logger
+	debounce           time.Duration
+	clock              clock.Clock
+	ready              atomic.Bool
+	wake               chan struct{}
+	stop               context.CancelFunc
+	done               sync.WaitGroup
+	waitersMutex       sync.Mutex
+	waiters            map[string][]chan struct{}
+	pivot              types.Currency
+	pivotAlways        bool
+	subscriptionsMutex sync.Mutex
+	subscriptions      []subscriptionState
+	// queuedSubscriptions - наступившие пары подписок, которые заберет следующий проход основного цикла
+	queuedSubscriptions [][2]types.Currency
+	leader              leader.Interface
+	id                  string
+	bus                 eb.Interface
+	maxAttempts         int
+	requestTtl          time.Duration
+	reconcileInterval   time.Duration
 }
 
 func New(runInterval time.Duration, db persistence.Interface, currencyConvert cc.Interface) *QuotationManager {
@@ -30,22 +61,79 @@ func New(runInterval time.Duration, db persistence.Interface, currencyConvert cc
 	)
 
 	manager := QuotationManager{
-		runInterval:     runInterval,
-		quotations:      make(map[string]types.QuotationInfo),
-		mutex:           sync.RWMutex{},
-		db:              db,
-		currencyConvert: currencyConvert,
-		logger:          logger,
-		runRequired:     atomic.Bool{},
+		runInterval:       runInterval,
+		quotations:        make(map[string]types.QuotationInfo),
+		mutex:             sync.RWMutex{},
+		db:                db,
+		currencyConvert:   currencyConvert,
+		logger:            logger,
+		debounce:          defaultDebounce,
+		clock:             clock.Real{},
+		wake:              make(chan struct{}, 1),
+		waiters:           make(map[string][]chan struct{}),
+		id:                uuid.NewString(),
+		reconcileInterval: runInterval,
 	}
 
-	manager.runRequired.Store(true)
+	// после старта сначала проверяем запросы, накопившиеся в бд
+	manager.SetRunRequired()
 
 	return &manager
 }
 
+// SetRunRequired будит цикл обработки запросов. Вызовы до следующего прохода схлопываются в один
 func (q *QuotationManager) SetRunRequired() {
-	q.runRequired.Store(true)
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// SetReconcileInterval - как часто реплика, которая не лидер, перечитывает кэш из бд. С шиной между процессами курсы
+// приходят событиями, и бд нужна только как подстраховка от потерянных событий. По умолчанию runInterval
+func (q *QuotationManager) SetReconcileInterval(interval time.Duration) {
+	q.reconcileInterval = interval
+}
+
+// SetDebounce - сколько ждать после пробуждения, чтобы собрать пачку запросов в один поход к провайдеру
+func (q *QuotationManager) SetDebounce(debounce time.Duration) {
+	q.debounce = debounce
+}
+
+func (q *QuotationManager) SetClock(clock clock.Clock) {
+	q.clock = clock
+}
+
+// NotifyOnUpdate возвращает канал, который закроется при следующем обновлении котировки пары.
+// Отписка обязательна, если канал так и не дождались
+func (q *QuotationManager) NotifyOnUpdate(base types.Currency, quote types.Currency) (<-chan struct{}, func()) {
+	key := asKey(base, quote)
+	ch := make(chan struct{})
+
+	q.waitersMutex.Lock()
+	q.waiters[key] = append(q.waiters[key], ch)
+	q.waitersMutex.Unlock()
+
+	unsubscribe := func() {
+		q.waitersMutex.Lock()
+		defer q.waitersMutex.Unlock()
+
+		waiters := q.waiters[key]
+
+		for i, waiter := range waiters {
+			if waiter == ch {
+				q.waiters[key] = append(waiters[:i], waiters[i+1:]...)
+
+				break
+			}
+		}
+
+		if len(q.waiters[key]) == 0 {
+			delete(q.waiters, key)
+		}
+	}
+
+	return ch, unsubscribe
 }
 
 func (q *QuotationManager) GetQuotation(base types.Currency, quote types.Currency) (types.QuotationInfo, bool) {
@@ -57,35 +145,184 @@ func (q *QuotationManager) GetQuotation(base types.Currency, quote types.Currenc
 	return info, exists
 }
 
-func (q *QuotationManager) UpdateQuotation(base types.Currency, quote types.Currency, rate string, updatedAt time.Time) {
+type CachedQuotation struct {
+	Base  types.Currency
+	Quote types.Currency
+	types.QuotationInfo
+}
+
+// Snapshot - копия всех кэшированных котировок, упорядоченная по base, quote
+func (q *QuotationManager) Snapshot() []CachedQuotation {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	result := make([]CachedQuotation, 0, len(q.quotations))
+
+	for key, info := range q.quotations {
+		base, quote, _ := strings.Cut(key, "/")
+
+		result = append(result, CachedQuotation{Base: types.Currency(base), Quote: types.Currency(quote), QuotationInfo: info})
+	}
+
+	slices.SortFunc(result, func(a, b CachedQuotation) int {
+		return cmp.Or(cmp.Compare(a.Base, b.Base), cmp.Compare(a.Quote, b.Quote))
+	})
+
+	return result
+}
+
+func (q *QuotationManager) UpdateQuotation(base types.Currency, quote types.Currency, info types.QuotationInfo) {
 	q.mutex.Lock()
 	defer q.mutex.Unlock()
 
-	q.quotations[asKey(base, quote)] = types.QuotationInfo{
-		Rate:      rate,
-		UpdatedAt: updatedAt,
+	q.quotations[asKey(base, quote)] = info
+
+	q.notifyWaiters(asKey(base, quote))
+}
+
+func (q *QuotationManager) notifyWaiters(key string) {
+	q.waitersMutex.Lock()
+	defer q.waitersMutex.Unlock()
+
+	for _, waiter := range q.waiters[key] {
+		close(waiter)
 	}
+
+	delete(q.waiters, key)
 }
 
-func (q *QuotationManager) Run() {
+// Start сначала прогревает кэш из бд, потом начинает обрабатывать запросы и подписки. Работает до отмены ctx или Stop
+func (q *QuotationManager) Start(ctx context.Context) {
+	ctx, q.stop = context.WithCancel(ctx)
+
+	q.done.Add(1)
+
 	go func() {
-		for {
-			var startedAt = time.Now()
+		defer q.done.Done()
+
+		if !q.warmUpUntilDone(ctx) {
+			return
+		}
+
+		if len(q.subscriptions) > 0 {
+			q.done.Add(1)
+
+			go func() {
+				defer q.done.Done()
+
+				q.runSubscriptions(ctx)
+			}()
+		}
+
+		q.loop(ctx)
+	}()
+}
 
-			var swapped = q.runRequired.CompareAndSwap(true, false)
+// Stop останавливает циклы, отменяет начатые походы к провайдерам и ждет, пока проход допишет полученное в бд и кэш
+func (q *QuotationManager) Stop() {
+	if q.stop == nil {
+		return
+	}
+
+	q.stop()
+	q.done.Wait()
+
+	q.logger.Info("quotation manager is stopped")
+}
 
-			if swapped {
-				q.runRequestsHandler()
+func (q *QuotationManager) loop(ctx context.Context) {
+	var lastRun, lastSync time.Time
+
+	for {
+		if !q.isLeader() {
+			if lastSync.IsZero() || q.clock.Now().Sub(lastSync) >= q.reconcileInterval {
+				q.syncFromDb()
+
+				lastSync = q.clock.Now()
 			}
 
-			executionTime := time.Since(startedAt)
-			sleepDuration := q.runInterval - executionTime
+			// если станем лидером, начинаем с проверки запросов
+			q.SetRunRequired()
 
-			if sleepDuration > 0 {
-				time.Sleep(sleepDuration)
+			if !q.sleep(ctx, q.runInterval) {
+				return
 			}
+
+			continue
 		}
-	}()
+
+		if !q.waitForWork(ctx) {
+			return
+		}
+
+		// пробуждения за время ожидания попадут в этот же проход. runInterval - минимальный интервал
+		// между походами во внешний сервис, даже если разбудили раньше
+		delay := q.debounce
+
+		if !lastRun.IsZero() {
+			delay = max(delay, lastRun.Add(q.runInterval).Sub(q.clock.Now()))
+		}
+
+		if !q.sleep(ctx, delay) {
+			return
+		}
+
+		select {
+		case <-q.wake:
+		default:
+		}
+
+		lastRun = q.clock.Now()
+
+		q.runRequestsHandler(ctx)
+	}
+}
+
+// sleep ждет d по часам менеджера, false - менеджер останавливают
+func (q *QuotationManager) sleep(ctx context.Context, d time.Duration) bool {
+	timer := q.clock.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C():
+		return true
+	}
+}
+
+// SetLeader - при нескольких репликах к провайдерам ходит только лидер, остальные синхронизируют кэш из бд.
+// Без SetLeader экземпляр считается единственным
+func (q *QuotationManager) SetLeader(leader leader.Interface) {
+	q.leader = leader
+}
+
+func (q *QuotationManager) isLeader() bool {
+	return q.leader == nil || q.leader.IsLeader()
+}
+
+func (q *QuotationManager) waitForWork(ctx context.Context) bool {
+	if q.leader == nil {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-q.wake:
+			return true
+		}
+	}
+
+	// запросы, созданные на других репликах, лидера не будят, поэтому бд опрашивается раз в runInterval
+	timer := q.clock.NewTimer(q.runInterval)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-q.wake:
+	case <-timer.C():
+	}
+
+	return true
 }
 
 func asKey(base types.Currency, quote types.Currency) string {
@@ -103,20 +340,44 @@ func groupCurrencyPairs(pairs [][2]types.Currency) map[types.Currency][]types.Cu
 	return grouped
 }
 
-func (q *QuotationManager) runRequestsHandler() {
+// runRequestsHandler - ctx цикла, его отмена обрывает походы к провайдерам
+func (q *QuotationManager) runRequestsHandler(ctx context.Context) {
+	q.expireRequests()
+	q.handleLatestRequests(ctx)
+	q.handleHistoricalRequests(ctx)
+}
+
+func (q *QuotationManager) handleLatestRequests(ctx context.Context) {
 	currencyPairs, err := q.db.QuotationRequestGetUniqUnhandled()
 
 	if err != nil {
+		// подписки обновляются и без бд
 		q.logger.Error("failed to get currency pairs", sl.Err(err))
-
-		return
 	}
 
+	currencyPairs = q.takeQueuedSubscriptions(currencyPairs)
+
 	if len(currencyPairs) == 0 {
 		return
 	}
 
-	groupedPairs := groupCurrencyPairs(currencyPairs)
+	q.refreshLatest(ctx, groupCurrencyPairs(currencyPairs))
+}
+
+// refreshLatest запрашивает последние курсы, пишет их в историю, завершает запросы и обновляет кэш
+func (q *QuotationManager) refreshLatest(ctx context.Context, groupedPairs map[types.Currency][]types.Currency) {
+	// запросы, созданные после этого момента, ждут следующего похода к провайдеру. Сравнивается с created_at,
+	// поэтому по настенным часам, а не q.clock
+	pendingAt := time.Now()
+	fetch := ratesFetcher(func(base types.Currency, quotes []types.Currency) ([]cc.CurrencyRate, error) {
+		return q.currencyConvert.GetLatestRates(ctx, base, quotes)
+	})
+
+	if q.pivot != "" && q.pivotAlways {
+		fetch = q.pivotSnapshot(groupedPairs, fetch)
+	}
+
+	fetch = q.withPivot(fetch)
 	var wg sync.WaitGroup
 
 	for base, quotes := range groupedPairs {
@@ -124,25 +385,128 @@ func (q *QuotationManager) runRequestsHandler() {
 
 		go func() {
 			defer wg.Done()
-			rates, err := q.currencyConvert.GetLatestRates(base, quotes)
+			q.startAttempts(base, quotes, nil, pendingAt)
+
+			rates, err := fetch(base, quotes)
 
 			if err != nil {
 				q.logger.Error("failed to get latest rates", sl.Err(err))
 			}
 
+			q.failMissing(ctx, base, quotes, nil, pendingAt, rates, err)
+
+			roundRates(rates)
+
+			q.appendHistory(base, rates)
+
 			for _, rate := range rates {
-				err = q.db.QuotationRequestUpdateByBaseAndQuote(base, rate.Currency, rate.Rate, rate.Time)
+				err = q.db.QuotationRequestCompletePending(base, rate.Currency, pendingAt, rate.Info())
 
 				if err != nil {
-					q.logger.Error("failed to update quotation requests", sl.Err(err))
+					q.logger.Error("failed to complete quotation requests", sl.Err(err))
+
+					continue
+				}
+
+				q.UpdateQuotation(base, rate.Currency, rate.Info())
+				q.publish(base, rate.Currency, rate.Info())
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+type historicalGroupKey struct {
+	base types.Currency
+	asOf time.Time
+}
+
+func groupHistoricalPairs(pairs []persistence.HistoricalPair) map[historicalGroupKey][]types.Currency {
+	grouped := make(map[historicalGroupKey][]types.Currency)
+
+	for _, pair := range pairs {
+		key := historicalGroupKey{base: pair.BaseCurrency, asOf: pair.AsOf}
+		grouped[key] = append(grouped[key], pair.QuoteCurrency)
+	}
+
+	return grouped
+}
+
+// handleHistoricalRequests - исторические курсы не попадают ни в кэш последних котировок, ни в quotation_history
+func (q *QuotationManager) handleHistoricalRequests(ctx context.Context) {
+	pairs, err := q.db.QuotationRequestGetUniqUnhandledHistorical()
+
+	if err != nil {
+		q.logger.Error("failed to get historical currency pairs", sl.Err(err))
+
+		return
+	}
+
+	if len(pairs) == 0 {
+		return
+	}
+
+	pendingAt := time.Now()
+	var wg sync.WaitGroup
+
+	for key, quotes := range groupHistoricalPairs(pairs) {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			fetch := q.withPivot(func(base types.Currency, quotes []types.Currency) ([]cc.CurrencyRate, error) {
+				return q.currencyConvert.GetRatesOn(ctx, base, quotes, key.asOf)
+			})
+
+			q.startAttempts(key.base, quotes, &key.asOf, pendingAt)
+
+			rates, err := fetch(key.base, quotes)
+
+			if err != nil {
+				q.logger.Error("failed to get historical rates", sl.Err(err))
+			}
+
+			q.failMissing(ctx, key.base, quotes, &key.asOf, pendingAt, rates, err)
+
+			roundRates(rates)
+
+			for _, rate := range rates {
+				pair := persistence.HistoricalPair{BaseCurrency: key.base, QuoteCurrency: rate.Currency, AsOf: key.asOf}
+
+				if err := q.db.QuotationRequestCompleteHistorical(pair, rate.Info()); err != nil {
+					q.logger.Error("failed to complete historical quotation requests", sl.Err(err))
 
 					continue
 				}
 
-				q.UpdateQuotation(base, rate.Currency, rate.Rate, rate.Time)
+				q.notifyWaiters(asKey(key.base, rate.Currency))
 			}
 		}()
 	}
 
 	wg.Wait()
 }
+
+// roundRates - масштаб курса задается котируемой валютой, до записи в бд и кэш
+func roundRates(rates []cc.CurrencyRate) {
+	for i := range rates {
+		rates[i].Rate = types.Rounding.Apply(rates[i].Currency, rates[i].Rate)
+	}
+}
+
+func (q *QuotationManager) appendHistory(base types.Currency, rates []cc.CurrencyRate) {
+	if len(rates) == 0 {
+		return
+	}
+
+	records := make([]qh.QuotationHistory, 0, len(rates))
+
+	for _, rate := range rates {
+		records = append(records, qh.New(base, rate.Currency, rate.Info()))
+	}
+
+	if err := q.db.QuotationHistoryAppend(records); err != nil {
+		q.logger.Error("failed to append quotation history", sl.Err(err))
+	}
+}
@@ -0,0 +1,94 @@
+package quotation_manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	qr "plata_currency_quotation/internal/domain/enity/quotation-request"
+	"plata_currency_quotation/internal/domain/types"
+	"plata_currency_quotation/internal/lib/logger/sl"
+	"plata_currency_quotation/internal/persistence"
+	cc "plata_currency_quotation/internal/service/currency-conversion"
+	"time"
+)
+
+// SetRequestPolicy - после maxAttempts неудачных походов к провайдеру запрос становится Failed, незавершенный запрос
+// старше ttl - Expired. Нулевые значения снимают ограничение
+func (q *QuotationManager) SetRequestPolicy(maxAttempts int, ttl time.Duration) {
+	q.maxAttempts = maxAttempts
+	q.requestTtl = ttl
+}
+
+func (q *QuotationManager) startAttempts(base types.Currency, quotes []types.Currency, asOf *time.Time, pendingAt time.Time) {
+	for _, quote := range quotes {
+		scope := persistence.PendingScope{BaseCurrency: base, QuoteCurrency: quote, AsOf: asOf, PendingAt: pendingAt}
+
+		if err := q.db.QuotationRequestStartAttempt(scope); err != nil {
+			q.logger.Error("failed to start quotation request attempt", sl.Err(err))
+		}
+	}
+}
+
+// failMissing засчитывает неудачную попытку парам, для которых провайдер не отдал курс. Запросы, у которых
+// остались попытки, берутся в следующий проход. Поход, оборванный остановкой менеджера, провайдеру не засчитывается:
+// запросы остаются InProgress, а они тоже незавершенные и будут взяты после перезапуска
+func (q *QuotationManager) failMissing(ctx context.Context, base types.Currency, quotes []types.Currency, asOf *time.Time, pendingAt time.Time, rates []cc.CurrencyRate, fetchErr error) {
+	missing := missingQuotes(quotes, rates)
+
+	if len(missing) == 0 || ctx.Err() != nil {
+		return
+	}
+
+	for _, quote := range missing {
+		scope := persistence.PendingScope{BaseCurrency: base, QuoteCurrency: quote, AsOf: asOf, PendingAt: pendingAt}
+
+		if err := q.db.QuotationRequestFailAttempt(scope, failureOf(base, quote, fetchErr), q.maxAttempts); err != nil {
+			q.logger.Error("failed to save quotation request failure", sl.Err(err))
+		}
+
+		// ожидающие клиенты должны увидеть Failed сразу, а не по таймауту
+		q.notifyWaiters(asKey(base, quote))
+	}
+
+	q.SetRunRequired()
+}
+
+// failureOf - таймаут и недоступность провайдера проверяются раньше отсутствия курса: в объединенной ошибке
+// нескольких провайдеров они объясняют отсутствие курса лучше
+func failureOf(base types.Currency, quote types.Currency, err error) qr.Failure {
+	var netErr net.Error
+
+	switch {
+	case err == nil:
+		return qr.Failure{Code: qr.ErrorRateNotFound, Message: fmt.Sprintf("%s for %s", cc.ErrRateNotFound, asKey(base, quote))}
+	case errors.Is(err, cc.ErrProviderTimeout), errors.As(err, &netErr) && netErr.Timeout():
+		return qr.Failure{Code: qr.ErrorProviderTimeout, Message: err.Error()}
+	case errors.Is(err, cc.ErrCircuitOpen):
+		return qr.Failure{Code: qr.ErrorProviderUnavailable, Message: err.Error()}
+	case errors.Is(err, cc.ErrRateNotFound):
+		return qr.Failure{Code: qr.ErrorRateNotFound, Message: err.Error()}
+	default:
+		return qr.Failure{Code: qr.ErrorProvider, Message: err.Error()}
+	}
+}
+
+func (q *QuotationManager) expireRequests() {
+	if q.requestTtl <= 0 {
+		return
+	}
+
+	// created_at и attempt_started_at пишутся по настенным часам
+	expired, err := q.db.QuotationRequestExpire(time.Now().Add(-q.requestTtl))
+
+	if err != nil {
+		q.logger.Error("failed to expire quotation requests", sl.Err(err))
+
+		return
+	}
+
+	if expired > 0 {
+		q.logger.Info("quotation requests expired", slog.Int64("count", expired))
+	}
+}
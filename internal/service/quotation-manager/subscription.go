@@ -0,0 +1,186 @@
+package quotation_manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"plata_currency_quotation/internal/domain/types"
+	"slices"
+	"strings"
+	"time"
+)
+
+// minSubscriptionInterval - чаще ходить к провайдерам смысла нет, большинство обновляет курсы не чаще раза в минуту
+const minSubscriptionInterval = time.Minute
+
+var ErrInvalidSubscription = errors.New("invalid subscription")
+
+// Subscription - пара, которая обновляется с интервалом Interval без запросов клиентов
+type Subscription struct {
+	Base     types.Currency
+	Quote    types.Currency
+	Interval time.Duration
+}
+
+type subscriptionState struct {
+	Subscription
+	next time.Time
+}
+
+type subscriptionFile struct {
+	Base     types.Currency `json:"base"`
+	Quote    types.Currency `json:"quote"`
+	Interval string         `json:"interval"`
+}
+
+// LoadSubscriptions читает JSON массив вида [{"base": "USD", "quote": "MXN", "interval": "5m"}]
+func LoadSubscriptions(path string) ([]Subscription, error) {
+	content, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subscriptions file: %w", err)
+	}
+
+	var entries []subscriptionFile
+
+	if err := json.Unmarshal(content, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse subscriptions file: %w", err)
+	}
+
+	subscriptions := make([]Subscription, 0, len(entries))
+
+	for i, entry := range entries {
+		interval, err := time.ParseDuration(entry.Interval)
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: record %d: invalid interval %q", ErrInvalidSubscription, i+1, entry.Interval)
+		}
+
+		subscription := Subscription{
+			Base:     types.Currency(strings.ToUpper(string(entry.Base))),
+			Quote:    types.Currency(strings.ToUpper(string(entry.Quote))),
+			Interval: interval,
+		}
+
+		if err := subscription.validate(); err != nil {
+			return nil, fmt.Errorf("record %d: %w", i+1, err)
+		}
+
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, nil
+}
+
+func (s Subscription) validate() error {
+	if !s.Base.IsValid() || !s.Quote.IsValid() {
+		return fmt.Errorf("%w: %s/%s is not enabled", ErrInvalidSubscription, s.Base, s.Quote)
+	}
+
+	if s.Base == s.Quote {
+		return fmt.Errorf("%w: %s/%s has same currencies", ErrInvalidSubscription, s.Base, s.Quote)
+	}
+
+	if s.Interval < minSubscriptionInterval {
+		return fmt.Errorf("%w: %s/%s interval is less than %s", ErrInvalidSubscription, s.Base, s.Quote, minSubscriptionInterval)
+	}
+
+	return nil
+}
+
+// Subscribe добавляет пары, которые обновляются сами по себе. Вызывать до Start, первое обновление - сразу после запуска
+func (q *QuotationManager) Subscribe(subscriptions ...Subscription) {
+	q.subscriptionsMutex.Lock()
+	defer q.subscriptionsMutex.Unlock()
+
+	for _, subscription := range subscriptions {
+		q.subscriptions = append(q.subscriptions, subscriptionState{Subscription: subscription})
+	}
+}
+
+// runSubscriptions только ставит наступившие пары в очередь и будит основной цикл: к провайдеру ходит он один,
+// иначе запросы той же пары получали бы по две попытки за проход
+func (q *QuotationManager) runSubscriptions(ctx context.Context) {
+	for {
+		wait := q.runInterval
+
+		// расписание двигается только у лидера, иначе новый лидер ждал бы следующего интервала
+		if q.isLeader() {
+			var due [][2]types.Currency
+
+			due, wait = q.dueSubscriptions(q.clock.Now())
+
+			if len(due) > 0 {
+				q.queueSubscriptions(due)
+				q.SetRunRequired()
+			}
+		}
+
+		if !q.sleep(ctx, wait) {
+			return
+		}
+	}
+}
+
+func (q *QuotationManager) queueSubscriptions(pairs [][2]types.Currency) {
+	q.subscriptionsMutex.Lock()
+	defer q.subscriptionsMutex.Unlock()
+
+	for _, pair := range pairs {
+		if !slices.Contains(q.queuedSubscriptions, pair) {
+			q.queuedSubscriptions = append(q.queuedSubscriptions, pair)
+		}
+	}
+}
+
+// takeQueuedSubscriptions добавляет к pairs пары из очереди подписок, которых там еще нет, и очищает очередь
+func (q *QuotationManager) takeQueuedSubscriptions(pairs [][2]types.Currency) [][2]types.Currency {
+	q.subscriptionsMutex.Lock()
+	defer q.subscriptionsMutex.Unlock()
+
+	for _, pair := range q.queuedSubscriptions {
+		if !slices.Contains(pairs, pair) {
+			pairs = append(pairs, pair)
+		}
+	}
+
+	q.queuedSubscriptions = nil
+
+	return pairs
+}
+
+// dueSubscriptions - пары, которые пора обновить, и сколько ждать до следующей. Чтобы реже ходить к провайдеру,
+// вместе с наступившей парой обновляются пары той же base, до которых осталось меньше половины их интервала
+func (q *QuotationManager) dueSubscriptions(now time.Time) ([][2]types.Currency, time.Duration) {
+	q.subscriptionsMutex.Lock()
+	defer q.subscriptionsMutex.Unlock()
+
+	dueBases := make(map[types.Currency]bool)
+
+	for _, subscription := range q.subscriptions {
+		if !now.Before(subscription.next) {
+			dueBases[subscription.Base] = true
+		}
+	}
+
+	var due [][2]types.Currency
+	var next time.Time
+
+	for i := range q.subscriptions {
+		subscription := &q.subscriptions[i]
+		left := subscription.next.Sub(now)
+
+		if left <= 0 || (dueBases[subscription.Base] && left < subscription.Interval/2) {
+			due = append(due, [2]types.Currency{subscription.Base, subscription.Quote})
+			subscription.next = now.Add(subscription.Interval)
+		}
+
+		if next.IsZero() || subscription.next.Before(next) {
+			next = subscription.next
+		}
+	}
+
+	return due, next.Sub(now)
+}
@@ -0,0 +1,248 @@
+package quotation_manager
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"plata_currency_quotation/internal/domain/types"
+	"plata_currency_quotation/internal/lib/logger/sl"
+	cc "plata_currency_quotation/internal/service/currency-conversion"
+	"slices"
+	"strings"
+)
+
+const syntheticProvider = "synthetic"
+
+// triangulationScale - знаков после запятой при делении в кросс-курсе и обратном курсе
+const triangulationScale = 16
+
+type ratesFetcher func(base types.Currency, quotes []types.Currency) ([]cc.CurrencyRate, error)
+
+// SetPivot включает вывод курсов через pivot валюту. always = false - только для пар, которые провайдер не отдал
+// напрямую, always = true - все пары выводятся из курсов pivot/*, а за последними курсами ходим одним запросом на тик.
+// Пустой pivot выключает триангуляцию
+func (q *QuotationManager) SetPivot(pivot types.Currency, always bool) {
+	q.pivot = pivot
+	q.pivotAlways = always
+}
+
+// withPivot оборачивает fetch триангуляцией. Пары, которые не удалось вывести в режиме always, запрашиваются напрямую.
+// Пары, которые не отдали ни провайдер, ни триангуляция, выводятся обратным курсом
+func (q *QuotationManager) withPivot(fetch ratesFetcher) ratesFetcher {
+	if q.pivot == "" {
+		return fetch
+	}
+
+	return func(base types.Currency, quotes []types.Currency) ([]cc.CurrencyRate, error) {
+		steps := []ratesFetcher{fetch, q.invert(fetch)}
+
+		if base != q.pivot {
+			steps = []ratesFetcher{fetch, q.triangulate(fetch), q.invert(fetch)}
+
+			if q.pivotAlways {
+				steps[0], steps[1] = steps[1], steps[0]
+			}
+		}
+
+		var rates []cc.CurrencyRate
+		var errs []error
+		missing := quotes
+
+		for _, step := range steps {
+			more, err := step(base, missing)
+			rates = append(rates, more...)
+			errs = append(errs, err)
+			missing = missingQuotes(quotes, rates)
+
+			if len(missing) == 0 {
+				return rates, nil
+			}
+		}
+
+		return rates, errors.Join(errs...)
+	}
+}
+
+// triangulate выводит BASE/QUOTE = (PIVOT/QUOTE) / (PIVOT/BASE), для QUOTE = PIVOT - обратный курс 1 / (PIVOT/BASE).
+// Все ноги берутся одним вызовом fetch(pivot, ...).
+//
+// Точность: ноги используются как их отдал провайдер, до округления. Единственная неточная операция - деление
+// до triangulationScale знаков, его ошибка меньше 1e-16. Дальше курс округляется как любой другой (types.Rounding),
+// то есть итог отличается от точного частного ног не больше чем на половину последнего знака (плюс 1e-16, из-за
+// двойного округления значение ровно посередине может уйти в соседний знак). Относительная погрешность самих ног
+// складывается: синтетический курс не точнее суммы относительных погрешностей PIVOT/BASE и PIVOT/QUOTE
+func (q *QuotationManager) triangulate(fetch ratesFetcher) ratesFetcher {
+	return func(base types.Currency, quotes []types.Currency) ([]cc.CurrencyRate, error) {
+		legQuotes := []types.Currency{base}
+
+		for _, quote := range quotes {
+			if quote != q.pivot && quote != base {
+				legQuotes = append(legQuotes, quote)
+			}
+		}
+
+		legs, err := fetch(q.pivot, legQuotes)
+
+		byCurrency := make(map[types.Currency]cc.CurrencyRate, len(legs))
+
+		for _, leg := range legs {
+			byCurrency[leg.Currency] = leg
+		}
+
+		pivotBase, exists := byCurrency[base]
+
+		if !exists || pivotBase.Rate.IsZero() {
+			return nil, errors.Join(err, fmt.Errorf("%w: no %s/%s leg for triangulation", cc.ErrRateNotFound, q.pivot, base))
+		}
+
+		inverted := syntheticLeg{base: q.pivot, rate: pivotBase, inverted: true}
+		var rates []cc.CurrencyRate
+
+		for _, quote := range quotes {
+			if quote == q.pivot {
+				rates = append(rates, synthetic(quote, types.NewDecimalFromInt(1).Div(pivotBase.Rate, triangulationScale), q.pivot, inverted))
+
+				continue
+			}
+
+			pivotQuote, exists := byCurrency[quote]
+
+			if !exists {
+				continue
+			}
+
+			rates = append(rates, synthetic(quote, pivotQuote.Rate.Div(pivotBase.Rate, triangulationScale), q.pivot,
+				inverted, syntheticLeg{base: q.pivot, rate: pivotQuote}))
+		}
+
+		return rates, err
+	}
+}
+
+// invert выводит BASE/QUOTE = 1 / (QUOTE/BASE) для пар, которые провайдер отдает только в обратную сторону.
+// На каждую QUOTE - свой вызов fetch(quote, base). Точность как у triangulate: одно деление до triangulationScale знаков
+func (q *QuotationManager) invert(fetch ratesFetcher) ratesFetcher {
+	return func(base types.Currency, quotes []types.Currency) ([]cc.CurrencyRate, error) {
+		var rates []cc.CurrencyRate
+		var errs []error
+
+		for _, quote := range quotes {
+			legs, err := fetch(quote, []types.Currency{base})
+			i := slices.IndexFunc(legs, func(leg cc.CurrencyRate) bool { return leg.Currency == base })
+
+			if i < 0 || legs[i].Rate.IsZero() {
+				errs = append(errs, errors.Join(err, fmt.Errorf("%w: no %s/%s for inverse rate", cc.ErrRateNotFound, quote, base)))
+
+				continue
+			}
+
+			rates = append(rates, synthetic(quote, types.NewDecimalFromInt(1).Div(legs[i].Rate, triangulationScale), "",
+				syntheticLeg{base: quote, rate: legs[i], inverted: true}))
+		}
+
+		return rates, errors.Join(errs...)
+	}
+}
+
+// syntheticLeg - курс base/rate.Currency от провайдера, inverted - входит в формулу как 1/rate
+type syntheticLeg struct {
+	base     types.Currency
+	rate     cc.CurrencyRate
+	inverted bool
+}
+
+// synthetic - время курса по самой старой ноге, confidence - минимальный среди ног. Пустой pivot - обратный курс
+func synthetic(quote types.Currency, rate types.Decimal, pivot types.Currency, legs ...syntheticLeg) cc.CurrencyRate {
+	detail := types.RateDetail{
+		Confidence: 1,
+		Sources:    []types.RateSource{},
+		Synthetic:  &types.SyntheticRate{Pivot: pivot},
+	}
+
+	at := legs[0].rate.Time
+
+	for _, leg := range legs {
+		detail.Synthetic.Legs = append(detail.Synthetic.Legs, types.RateLeg{
+			Base:      leg.base,
+			Quote:     leg.rate.Currency,
+			Rate:      leg.rate.Rate,
+			UpdatedAt: leg.rate.Time,
+			Provider:  leg.rate.Provider,
+			Inverted:  leg.inverted,
+		})
+
+		if leg.rate.Time.Before(at) {
+			at = leg.rate.Time
+		}
+
+		if leg.rate.Detail != nil {
+			detail.Confidence = min(detail.Confidence, leg.rate.Detail.Confidence)
+		}
+	}
+
+	return cc.CurrencyRate{
+		Rate:     rate,
+		Time:     at,
+		Currency: quote,
+		Provider: syntheticProvider,
+		Detail:   &detail,
+	}
+}
+
+// pivotSnapshot для режима always: последние курсы pivot/* по всем валютам тика одним запросом.
+// Вызовы с base = pivot отдаются из снимка, остальные идут в fetch
+func (q *QuotationManager) pivotSnapshot(grouped map[types.Currency][]types.Currency, fetch ratesFetcher) ratesFetcher {
+	var currencies []types.Currency
+
+	for base, quotes := range grouped {
+		for _, currency := range append([]types.Currency{base}, quotes...) {
+			if currency != q.pivot && !slices.Contains(currencies, currency) {
+				currencies = append(currencies, currency)
+			}
+		}
+	}
+
+	snapshot, snapshotErr := fetch(q.pivot, currencies)
+
+	if snapshotErr != nil {
+		q.logger.Warn("pivot snapshot is incomplete", slog.String("pivot", string(q.pivot)), sl.Err(snapshotErr))
+	}
+
+	return func(base types.Currency, quotes []types.Currency) ([]cc.CurrencyRate, error) {
+		if base != q.pivot {
+			return fetch(base, quotes)
+		}
+
+		var rates []cc.CurrencyRate
+
+		for _, rate := range snapshot {
+			if slices.Contains(quotes, rate.Currency) {
+				rates = append(rates, rate)
+			}
+		}
+
+		if missing := missingQuotes(quotes, rates); len(missing) > 0 {
+			pairs := make([]string, 0, len(missing))
+
+			for _, quote := range missing {
+				pairs = append(pairs, asKey(base, quote))
+			}
+
+			return rates, fmt.Errorf("%w for %s", cc.ErrRateNotFound, strings.Join(pairs, ", "))
+		}
+
+		return rates, nil
+	}
+}
+
+func missingQuotes(quotes []types.Currency, rates []cc.CurrencyRate) []types.Currency {
+	var missing []types.Currency
+
+	for _, quote := range quotes {
+		if !slices.ContainsFunc(rates, func(rate cc.CurrencyRate) bool { return rate.Currency == quote }) {
+			missing = append(missing, quote)
+		}
+	}
+
+	return missing
+}
@@ -1,11 +1,21 @@
 package quotation_manager
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	qh "plata_currency_quotation/internal/domain/enity/quotation-history"
 	qr "plata_currency_quotation/internal/domain/enity/quotation-request"
 	"plata_currency_quotation/internal/domain/types"
+	"plata_currency_quotation/internal/lib/clock"
 	"plata_currency_quotation/internal/persistence/inmemory"
 	cc "plata_currency_quotation/internal/service/currency-conversion"
+	eb "plata_currency_quotation/internal/service/event-bus"
 	"reflect"
+	"slices"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -31,11 +41,29 @@ func Test_Runtime(t *testing.T) {
 	request3 := createAndAssert(types.MXN, types.EUR)
 	request4 := createAndAssert(types.EUR, types.MXN)
 
+	fake := clock.NewFake(time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC))
+
 	manager := New(time.Duration(50)*time.Millisecond, db, cc.NewMock())
+	manager.SetClock(fake)
+
+	var updates []<-chan struct{}
+
+	for _, pair := range [][2]types.Currency{{types.USD, types.MXN}, {types.MXN, types.EUR}, {types.EUR, types.MXN}} {
+		updated, unsubscribe := manager.NotifyOnUpdate(pair[0], pair[1])
+		defer unsubscribe()
+
+		updates = append(updates, updated)
+	}
 
-	manager.Run()
+	manager.Start(context.Background())
+	defer manager.Stop()
 
-	time.Sleep(time.Duration(200) * time.Millisecond)
+	fake.BlockUntil(1)
+	fake.Advance(defaultDebounce)
+
+	for _, updated := range updates {
+		waitForUpdate(t, updated)
+	}
 
 	var assertUpdated = func(request *qr.QuotationRequest) {
 		requestUpdated, err := db.QuotationRequestGetById(request1.Id)
@@ -55,23 +83,23 @@ func Test_UpdateQuotation(t *testing.T) {
 	manager := New(time.Second, inmemory.New(), cc.NewMock())
 	now := time.Now()
 
-	manager.UpdateQuotation(types.USD, types.EUR, "1.5", now)
+	manager.UpdateQuotation(types.USD, types.EUR, types.QuotationInfo{Rate: types.MustParseDecimal("1.5"), UpdatedAt: now})
 
 	assert.Len(t, manager.quotations, 1)
 
 	quotation := manager.quotations[asKey(types.USD, types.EUR)]
 
 	assert.NotNil(t, quotation)
-	assert.Equal(t, "1.5", quotation.Rate)
+	assert.Equal(t, "1.5", quotation.Rate.String())
 	assert.Equal(t, now, quotation.UpdatedAt)
 
 	now = time.Now()
-	manager.UpdateQuotation(types.USD, types.EUR, "2.5", now)
+	manager.UpdateQuotation(types.USD, types.EUR, types.QuotationInfo{Rate: types.MustParseDecimal("2.5"), UpdatedAt: now})
 
 	quotation = manager.quotations[asKey(types.USD, types.EUR)]
 
 	assert.NotNil(t, quotation)
-	assert.Equal(t, "2.5", quotation.Rate)
+	assert.Equal(t, "2.5", quotation.Rate.String())
 	assert.Equal(t, now, quotation.UpdatedAt)
 }
 
@@ -79,14 +107,14 @@ func Test_GetQuotation(t *testing.T) {
 	manager := New(time.Second, inmemory.New(), cc.NewMock())
 	now := time.Now()
 
-	manager.UpdateQuotation(types.USD, types.EUR, "1.5", now)
+	manager.UpdateQuotation(types.USD, types.EUR, types.QuotationInfo{Rate: types.MustParseDecimal("1.5"), UpdatedAt: now})
 
 	info, exists := manager.GetQuotation(types.USD, types.EUR)
 	if !exists {
 		t.Error("Expected quotation to exist")
 	}
 
-	if info.Rate != "1.5" {
+	if info.Rate.String() != "1.5" {
 		t.Errorf("Expected price 1.5, got %s", info.Rate)
 	}
 
@@ -95,6 +123,31 @@ func Test_GetQuotation(t *testing.T) {
 	}
 }
 
+func Test_NotifyOnUpdate(t *testing.T) {
+	manager := New(time.Second, inmemory.New(), cc.NewMock())
+
+	updated, _ := manager.NotifyOnUpdate(types.USD, types.EUR)
+	other, unsubscribe := manager.NotifyOnUpdate(types.USD, types.MXN)
+
+	manager.UpdateQuotation(types.USD, types.EUR, types.QuotationInfo{Rate: types.MustParseDecimal("1.5"), UpdatedAt: time.Now()})
+
+	select {
+	case <-updated:
+	default:
+		t.Error("Expected USD/EUR waiter to be notified")
+	}
+
+	select {
+	case <-other:
+		t.Error("Expected USD/MXN waiter not to be notified")
+	default:
+	}
+
+	unsubscribe()
+
+	assert.Empty(t, manager.waiters)
+}
+
 func Test_GroupCurrencyPairs(t *testing.T) {
 	pairs := [][2]types.Currency{
 		{types.USD, types.EUR},
@@ -113,3 +166,783 @@ func Test_GroupCurrencyPairs(t *testing.T) {
 		t.Errorf("Expected %v, got %v", expected, grouped)
 	}
 }
+
+func Test_RoundRates(t *testing.T) {
+	rates := []cc.CurrencyRate{
+		{Rate: types.MustParseDecimal("20.1234565"), Currency: types.MXN},
+		{Rate: types.MustParseDecimal("149.5"), Currency: "JPY"},
+	}
+
+	roundRates(rates)
+
+	assert.Equal(t, "20.123456", rates[0].Rate.String())
+	assert.Equal(t, "149.5000", rates[1].Rate.String())
+}
+
+// pivotRates - провайдер, который знает только курсы EUR/*
+func pivotRates(calls *int, at time.Time) ratesFetcher {
+	known := map[types.Currency]string{types.USD: "1.25", types.MXN: "20.00", "GBP": "3"}
+
+	return func(base types.Currency, quotes []types.Currency) ([]cc.CurrencyRate, error) {
+		*calls++
+
+		if base != types.EUR {
+			return nil, cc.ErrRateNotFound
+		}
+
+		var rates []cc.CurrencyRate
+
+		for _, quote := range quotes {
+			if rate, exists := known[quote]; exists {
+				rates = append(rates, cc.CurrencyRate{Rate: types.MustParseDecimal(rate), Time: at, Currency: quote, Provider: "ecb"})
+				at = at.Add(time.Minute)
+			}
+		}
+
+		return rates, nil
+	}
+}
+
+func Test_TriangulateFallback(t *testing.T) {
+	manager := New(time.Second, inmemory.New(), cc.NewMock())
+	manager.SetPivot(types.EUR, false)
+
+	calls := 0
+	at := time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	rates, err := manager.withPivot(pivotRates(&calls, at))(types.USD, []types.Currency{types.MXN, types.EUR})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Len(t, rates, 2)
+
+	assert.Equal(t, types.MXN, rates[0].Currency)
+	assert.True(t, rates[0].Rate.Equal(types.MustParseDecimal("16")))
+	assert.Equal(t, syntheticProvider, rates[0].Provider)
+	assert.Equal(t, at, rates[0].Time)
+	assert.Equal(t, types.EUR, rates[0].Detail.Synthetic.Pivot)
+	assert.Len(t, rates[0].Detail.Synthetic.Legs, 2)
+	assert.True(t, rates[0].Detail.Synthetic.Legs[0].Inverted)
+	assert.Equal(t, types.USD, rates[0].Detail.Synthetic.Legs[0].Quote)
+	assert.False(t, rates[0].Detail.Synthetic.Legs[1].Inverted)
+
+	// обратный курс USD/EUR = 1 / (EUR/USD)
+	assert.Equal(t, types.EUR, rates[1].Currency)
+	assert.True(t, rates[1].Rate.Equal(types.MustParseDecimal("0.8")))
+	assert.Len(t, rates[1].Detail.Synthetic.Legs, 1)
+
+	// непериодическое деление обрезается до triangulationScale знаков, дальше обычное округление
+	rates, err = manager.withPivot(pivotRates(&calls, at))("GBP", []types.Currency{types.EUR})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "0.3333333333333333", rates[0].Rate.String())
+
+	roundRates(rates)
+
+	assert.Equal(t, "0.333333", rates[0].Rate.String())
+}
+
+func Test_TriangulateMissingLeg(t *testing.T) {
+	manager := New(time.Second, inmemory.New(), cc.NewMock())
+	manager.SetPivot(types.EUR, false)
+
+	calls := 0
+
+	rates, err := manager.withPivot(pivotRates(&calls, time.Now()))("JPY", []types.Currency{types.MXN})
+
+	assert.ErrorIs(t, err, cc.ErrRateNotFound)
+	assert.Empty(t, rates)
+}
+
+func Test_TriangulateInverse(t *testing.T) {
+	manager := New(time.Second, inmemory.New(), cc.NewMock())
+	manager.SetPivot(types.EUR, false)
+
+	calls := 0
+	at := time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	// провайдер знает только MXN/JPY, пары без EUR: триангуляции не из чего
+	fetch := func(base types.Currency, quotes []types.Currency) ([]cc.CurrencyRate, error) {
+		calls++
+
+		if base != types.MXN || !slices.Contains(quotes, "JPY") {
+			return nil, cc.ErrRateNotFound
+		}
+
+		return []cc.CurrencyRate{{Rate: types.MustParseDecimal("8"), Time: at, Currency: "JPY", Provider: "ecb"}}, nil
+	}
+
+	rates, err := manager.withPivot(fetch)("JPY", []types.Currency{types.MXN})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Len(t, rates, 1)
+	assert.Equal(t, types.MXN, rates[0].Currency)
+	assert.True(t, rates[0].Rate.Equal(types.MustParseDecimal("0.125")))
+	assert.Equal(t, syntheticProvider, rates[0].Provider)
+	assert.Equal(t, at, rates[0].Time)
+	assert.Empty(t, rates[0].Detail.Synthetic.Pivot)
+	assert.Equal(t, []types.RateLeg{
+		{Base: types.MXN, Quote: "JPY", Rate: types.MustParseDecimal("8"), UpdatedAt: at, Provider: "ecb", Inverted: true},
+	}, rates[0].Detail.Synthetic.Legs)
+}
+
+func Test_TriangulateAlwaysUsesSingleCall(t *testing.T) {
+	manager := New(time.Second, inmemory.New(), cc.NewMock())
+	manager.SetPivot(types.EUR, true)
+
+	calls := 0
+	grouped := map[types.Currency][]types.Currency{
+		types.USD: {types.MXN, types.EUR},
+		types.MXN: {types.USD},
+		types.EUR: {types.USD},
+	}
+
+	fetch := manager.withPivot(manager.pivotSnapshot(grouped, pivotRates(&calls, time.Now())))
+
+	for base, quotes := range grouped {
+		rates, err := fetch(base, quotes)
+
+		assert.NoError(t, err)
+		assert.Len(t, rates, len(quotes))
+	}
+
+	assert.Equal(t, 1, calls)
+}
+
+func Test_Snapshot(t *testing.T) {
+	manager := New(time.Second, inmemory.New(), cc.NewMock())
+	now := time.Now()
+
+	manager.UpdateQuotation(types.USD, types.MXN, types.QuotationInfo{Rate: types.MustParseDecimal("20"), UpdatedAt: now})
+	manager.UpdateQuotation(types.EUR, types.USD, types.QuotationInfo{Rate: types.MustParseDecimal("1.1"), UpdatedAt: now})
+
+	snapshot := manager.Snapshot()
+
+	assert.Len(t, snapshot, 2)
+	assert.Equal(t, types.EUR, snapshot[0].Base)
+	assert.Equal(t, types.USD, snapshot[0].Quote)
+	assert.Equal(t, "1.1", snapshot[0].Rate.String())
+	assert.Equal(t, types.USD, snapshot[1].Base)
+	assert.Equal(t, types.MXN, snapshot[1].Quote)
+}
+
+func Test_DueSubscriptions(t *testing.T) {
+	manager := New(time.Second, inmemory.New(), cc.NewMock())
+	manager.Subscribe(
+		Subscription{Base: types.USD, Quote: types.MXN, Interval: 10 * time.Second},
+		Subscription{Base: types.USD, Quote: types.EUR, Interval: time.Minute},
+		Subscription{Base: types.EUR, Quote: types.MXN, Interval: 30 * time.Second},
+	)
+
+	start := time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC)
+
+	due, wait := manager.dueSubscriptions(start)
+
+	assert.Len(t, due, 3)
+	assert.Equal(t, 10*time.Second, wait)
+
+	due, wait = manager.dueSubscriptions(start.Add(5 * time.Second))
+
+	assert.Empty(t, due)
+	assert.Equal(t, 5*time.Second, wait)
+
+	// USD/EUR еще не наступила, но до нее меньше половины интервала - едет вместе с USD/MXN
+	due, _ = manager.dueSubscriptions(start.Add(40 * time.Second))
+
+	assert.ElementsMatch(t, [][2]types.Currency{{types.USD, types.MXN}, {types.USD, types.EUR}, {types.EUR, types.MXN}}, due)
+
+	due, _ = manager.dueSubscriptions(start.Add(50 * time.Second))
+
+	assert.Equal(t, [][2]types.Currency{{types.USD, types.MXN}}, due)
+}
+
+func Test_SubscriptionsKeepCacheWarm(t *testing.T) {
+	fake := clock.NewFake(time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC))
+
+	manager := New(time.Second, inmemory.New(), cc.NewMock())
+	manager.SetClock(fake)
+	manager.Subscribe(Subscription{Base: types.USD, Quote: types.MXN, Interval: time.Minute})
+
+	updated, unsubscribe := manager.NotifyOnUpdate(types.USD, types.MXN)
+	defer unsubscribe()
+
+	// первое обновление подписки - сразу после старта, без ожидания интервала: подписки ждут следующего
+	// интервала, основной цикл - debounce
+	manager.Start(context.Background())
+	defer manager.Stop()
+
+	fake.BlockUntil(2)
+	fake.Advance(defaultDebounce)
+	waitForUpdate(t, updated)
+
+	_, found := manager.GetQuotation(types.USD, types.MXN)
+
+	assert.True(t, found)
+}
+
+// подписка и запрос той же пары обслуживаются одним проходом: одна попытка у запроса и один поход к провайдеру
+func Test_SubscriptionSharesRunWithRequests(t *testing.T) {
+	db := inmemory.New()
+	provider := &countingProvider{Interface: cc.NewMock()}
+	fake := clock.NewFake(time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC))
+
+	manager := New(time.Second, db, provider)
+	manager.SetClock(fake)
+	manager.SetRequestPolicy(1, 0)
+	manager.Subscribe(Subscription{Base: types.USD, Quote: types.MXN, Interval: time.Minute})
+
+	request := createRequest(t, db, types.USD, types.MXN)
+
+	updated, unsubscribe := manager.NotifyOnUpdate(types.USD, types.MXN)
+	defer unsubscribe()
+
+	manager.Start(context.Background())
+	defer manager.Stop()
+
+	fake.BlockUntil(2)
+	fake.Advance(defaultDebounce)
+	waitForUpdate(t, updated)
+
+	// остался только таймер подписок, основной цикл ждет новых пробуждений
+	fake.BlockUntil(1)
+
+	stored, err := db.QuotationRequestGetById(request.Id)
+
+	assert.NoError(t, err)
+	assert.Equal(t, qr.Ready, stored.Status)
+	assert.Equal(t, 1, stored.Attempts)
+	assert.Equal(t, int32(1), provider.calls.Load())
+}
+
+type switchLeader struct {
+	atomic.Bool
+}
+
+func (s *switchLeader) IsLeader() bool {
+	return s.Load()
+}
+
+func Test_SubscriptionsWaitForLeadership(t *testing.T) {
+	fake := clock.NewFake(time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC))
+	leader := &switchLeader{}
+
+	manager := New(time.Second, inmemory.New(), cc.NewMock())
+	manager.SetClock(fake)
+	manager.SetLeader(leader)
+	manager.Subscribe(Subscription{Base: types.USD, Quote: types.MXN, Interval: time.Minute})
+
+	updated, unsubscribe := manager.NotifyOnUpdate(types.USD, types.MXN)
+	defer unsubscribe()
+
+	manager.Start(context.Background())
+	defer manager.Stop()
+
+	// пока не лидер, расписание не двигается
+	for range 3 {
+		fake.BlockUntil(2)
+		fake.Advance(time.Second)
+	}
+
+	fake.BlockUntil(2)
+
+	manager.subscriptionsMutex.Lock()
+	assert.True(t, manager.subscriptions[0].next.IsZero())
+	manager.subscriptionsMutex.Unlock()
+
+	// новый лидер обновляет подписку на ближайшем тике, а не через интервал подписки
+	leader.Store(true)
+	fake.Advance(time.Second)
+	fake.BlockUntil(2)
+	fake.Advance(defaultDebounce)
+	waitForUpdate(t, updated)
+}
+
+func Test_LoadSubscriptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "subscriptions.json")
+
+	err := os.WriteFile(path, []byte(`[{"base": "usd", "quote": "MXN", "interval": "5m"}]`), 0o600)
+	assert.NoError(t, err)
+
+	subscriptions, err := LoadSubscriptions(path)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Subscription{{Base: types.USD, Quote: types.MXN, Interval: 5 * time.Minute}}, subscriptions)
+
+	for _, content := range []string{
+		`[{"base": "USD", "quote": "USD", "interval": "5m"}]`,
+		`[{"base": "USD", "quote": "JPY", "interval": "5m"}]`,
+		`[{"base": "USD", "quote": "MXN", "interval": "30s"}]`,
+		`[{"base": "USD", "quote": "MXN", "interval": "often"}]`,
+	} {
+		err = os.WriteFile(path, []byte(content), 0o600)
+		assert.NoError(t, err)
+
+		_, err = LoadSubscriptions(path)
+
+		assert.ErrorIs(t, err, ErrInvalidSubscription, content)
+	}
+}
+
+func Test_WarmUp(t *testing.T) {
+	db := inmemory.New()
+	old := time.Now().Add(-time.Hour)
+
+	request, err := qr.New(types.USD, types.MXN, uuid.New())
+	assert.NoError(t, err)
+	assert.NoError(t, db.QuotationRequestCreateOrGetByIdempotencyKey(&request))
+
+	request, err = qr.New(types.EUR, types.MXN, uuid.New())
+	assert.NoError(t, err)
+	assert.NoError(t, db.QuotationRequestCreateOrGetByIdempotencyKey(&request))
+
+	err = db.QuotationRequestCompletePending(types.USD, types.MXN, time.Now(), types.QuotationInfo{Rate: types.MustParseDecimal("20"), UpdatedAt: old, Provider: "ecb"})
+	assert.NoError(t, err)
+
+	// в истории курс свежее, чем в запросах
+	err = db.QuotationHistoryAppend([]qh.QuotationHistory{
+		qh.New(types.USD, types.MXN, types.QuotationInfo{Rate: types.MustParseDecimal("19"), UpdatedAt: old.Add(-time.Minute)}),
+		qh.New(types.USD, types.MXN, types.QuotationInfo{Rate: types.MustParseDecimal("21"), UpdatedAt: old.Add(time.Minute)}),
+		qh.New(types.USD, types.EUR, types.QuotationInfo{Rate: types.MustParseDecimal("0.9"), UpdatedAt: old}),
+	})
+	assert.NoError(t, err)
+
+	manager := New(time.Hour, db, cc.NewMock())
+
+	assert.False(t, manager.Ready())
+	assert.NoError(t, manager.WarmUp())
+
+	quotation, found := manager.GetQuotation(types.USD, types.MXN)
+
+	assert.True(t, found)
+	assert.Equal(t, "21", quotation.Rate.String())
+
+	_, found = manager.GetQuotation(types.USD, types.EUR)
+
+	assert.True(t, found)
+
+	// незавершенный запрос кэш не заполняет
+	_, found = manager.GetQuotation(types.EUR, types.MXN)
+
+	assert.False(t, found)
+
+	fake := clock.NewFake(time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC))
+	manager.SetClock(fake)
+
+	manager.Start(context.Background())
+	defer manager.Stop()
+
+	// цикл обработки запросов заводит таймер только после прогрева
+	fake.BlockUntil(1)
+
+	assert.True(t, manager.Ready())
+}
+
+func Test_SyncFromDbWakesOnlyChangedPairs(t *testing.T) {
+	db := inmemory.New()
+	at := time.Now().Add(-time.Hour)
+
+	err := db.QuotationHistoryAppend([]qh.QuotationHistory{
+		qh.New(types.USD, types.MXN, types.QuotationInfo{Rate: types.MustParseDecimal("20"), UpdatedAt: at}),
+		qh.New(types.USD, types.EUR, types.QuotationInfo{Rate: types.MustParseDecimal("0.9"), UpdatedAt: at}),
+	})
+	assert.NoError(t, err)
+
+	manager := New(time.Hour, db, cc.NewMock())
+	assert.NoError(t, manager.WarmUp())
+
+	changed, unsubscribeChanged := manager.NotifyOnUpdate(types.USD, types.MXN)
+	defer unsubscribeChanged()
+	unchanged, unsubscribeUnchanged := manager.NotifyOnUpdate(types.USD, types.EUR)
+	defer unsubscribeUnchanged()
+
+	err = db.QuotationHistoryAppend([]qh.QuotationHistory{
+		qh.New(types.USD, types.MXN, types.QuotationInfo{Rate: types.MustParseDecimal("21"), UpdatedAt: at.Add(time.Minute)}),
+	})
+	assert.NoError(t, err)
+
+	manager.syncFromDb()
+
+	select {
+	case <-changed:
+	default:
+		t.Fatal("waiter of the changed pair is not notified")
+	}
+
+	select {
+	case <-unchanged:
+		t.Fatal("waiter of the unchanged pair is notified")
+	default:
+	}
+}
+
+type staticLeader bool
+
+func (s staticLeader) IsLeader() bool {
+	return bool(s)
+}
+
+func Test_FollowerSyncsFromDb(t *testing.T) {
+	db := inmemory.New()
+
+	request, err := qr.New(types.USD, types.MXN, uuid.New())
+	assert.NoError(t, err)
+	assert.NoError(t, db.QuotationRequestCreateOrGetByIdempotencyKey(&request))
+
+	followerClock := clock.NewFake(time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC))
+
+	follower := New(time.Duration(10)*time.Millisecond, db, cc.NewMock())
+	follower.SetClock(followerClock)
+	follower.SetLeader(staticLeader(false))
+	follower.Start(context.Background())
+	defer follower.Stop()
+
+	followerClock.BlockUntil(1)
+	followerClock.Advance(time.Duration(10) * time.Millisecond)
+	followerClock.BlockUntil(1)
+
+	// к провайдеру ходит только лидер
+	stored, err := db.QuotationRequestGetById(request.Id)
+
+	assert.NoError(t, err)
+	assert.Nil(t, stored.CompletedAt)
+
+	updated, unsubscribe := follower.NotifyOnUpdate(types.USD, types.MXN)
+	defer unsubscribe()
+
+	leaderClock := clock.NewFake(time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC))
+
+	// без SetLeader экземпляр сам себе лидер, и цикл не заводит таймер опроса бд, который мешал бы BlockUntil
+	leader := New(time.Duration(10)*time.Millisecond, db, cc.NewMock())
+	leader.SetClock(leaderClock)
+
+	completed, unsubscribeLeader := leader.NotifyOnUpdate(types.USD, types.MXN)
+	defer unsubscribeLeader()
+
+	leader.Start(context.Background())
+	defer leader.Stop()
+
+	leaderClock.BlockUntil(1)
+	leaderClock.Advance(defaultDebounce)
+	waitForUpdate(t, completed)
+
+	// курс в бд, реплика подтянет его на следующем проходе
+	followerClock.Advance(time.Duration(10) * time.Millisecond)
+	waitForUpdate(t, updated)
+
+	_, found := follower.GetQuotation(types.USD, types.MXN)
+
+	assert.True(t, found)
+}
+
+func Test_EventBusPropagatesUpdates(t *testing.T) {
+	db := inmemory.New()
+	bus := eb.NewInMemory()
+
+	request, err := qr.New(types.USD, types.MXN, uuid.New())
+	assert.NoError(t, err)
+	assert.NoError(t, db.QuotationRequestCreateOrGetByIdempotencyKey(&request))
+
+	// реплика не лидер и не синхронизируется с бд сама, курс может прийти только через шину
+	replica := New(time.Hour, db, cc.NewMock())
+	replica.SetLeader(staticLeader(false))
+	replica.SetEventBus(bus)
+
+	leader := New(time.Duration(10)*time.Millisecond, db, cc.NewMock())
+	leader.SetLeader(staticLeader(true))
+	leader.SetEventBus(bus)
+
+	updated, unsubscribe := replica.NotifyOnUpdate(types.USD, types.MXN)
+	defer unsubscribe()
+
+	leader.Start(context.Background())
+	defer leader.Stop()
+
+	select {
+	case <-updated:
+	case <-time.After(time.Second):
+		t.Fatal("replica was not notified")
+	}
+
+	fromLeader, _ := leader.GetQuotation(types.USD, types.MXN)
+	fromReplica, found := replica.GetQuotation(types.USD, types.MXN)
+
+	assert.True(t, found)
+	assert.True(t, fromLeader.Rate.Equal(fromReplica.Rate))
+}
+
+func Test_EventBusIgnoresOutdatedEvents(t *testing.T) {
+	manager := New(time.Hour, inmemory.New(), cc.NewMock())
+	manager.SetEventBus(eb.NewInMemory())
+
+	now := time.Now()
+	fresh := types.QuotationInfo{Rate: types.NewDecimalFromInt(20), UpdatedAt: now}
+
+	manager.UpdateQuotation(types.USD, types.MXN, fresh)
+
+	manager.applyEvent(eb.QuotationUpdated{
+		Origin:    "other",
+		Base:      types.USD,
+		Quote:     types.MXN,
+		Quotation: types.QuotationInfo{Rate: types.NewDecimalFromInt(19), UpdatedAt: now.Add(-time.Minute)},
+	})
+
+	cached, _ := manager.GetQuotation(types.USD, types.MXN)
+	assert.True(t, cached.Rate.Equal(fresh.Rate))
+
+	manager.applyEvent(eb.QuotationUpdated{
+		Origin:    "other",
+		Base:      types.USD,
+		Quote:     types.MXN,
+		Quotation: types.QuotationInfo{Rate: types.NewDecimalFromInt(21), UpdatedAt: now.Add(time.Minute)},
+	})
+
+	cached, _ = manager.GetQuotation(types.USD, types.MXN)
+	assert.True(t, cached.Rate.Equal(types.NewDecimalFromInt(21)))
+}
+
+func Test_EventBusAppliesEqualTimestamp(t *testing.T) {
+	manager := New(time.Hour, inmemory.New(), cc.NewMock())
+	manager.SetEventBus(eb.NewInMemory())
+
+	// время ECB - полночь даты, поправка курса за день приходит с тем же временем
+	midnight := time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	manager.UpdateQuotation(types.USD, types.MXN, types.QuotationInfo{Rate: types.NewDecimalFromInt(20), UpdatedAt: midnight, Provider: "ecb"})
+
+	updated, unsubscribe := manager.NotifyOnUpdate(types.USD, types.MXN)
+	defer unsubscribe()
+
+	manager.applyEvent(eb.QuotationUpdated{
+		Origin:    "other",
+		Base:      types.USD,
+		Quote:     types.MXN,
+		Quotation: types.QuotationInfo{Rate: types.NewDecimalFromInt(21), UpdatedAt: midnight, Provider: "file"},
+	})
+
+	cached, _ := manager.GetQuotation(types.USD, types.MXN)
+	assert.True(t, cached.Rate.Equal(types.NewDecimalFromInt(21)))
+	assert.Equal(t, "file", cached.Provider)
+
+	select {
+	case <-updated:
+	default:
+		t.Fatal("waiter is not notified")
+	}
+}
+
+func Test_FollowerReconcilesOnInterval(t *testing.T) {
+	db := inmemory.New()
+	at := time.Now().Add(-time.Hour)
+	fake := clock.NewFake(time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC))
+
+	follower := New(time.Second, db, cc.NewMock())
+	follower.SetClock(fake)
+	follower.SetLeader(staticLeader(false))
+	follower.SetReconcileInterval(time.Hour)
+
+	follower.Start(context.Background())
+	defer follower.Stop()
+
+	fake.BlockUntil(1)
+
+	err := db.QuotationHistoryAppend([]qh.QuotationHistory{
+		qh.New(types.USD, types.MXN, types.QuotationInfo{Rate: types.MustParseDecimal("20"), UpdatedAt: at}),
+	})
+	assert.NoError(t, err)
+
+	// курсы приходят по шине, бд перечитывается только раз в reconcileInterval
+	fake.Advance(time.Second)
+	fake.BlockUntil(1)
+
+	_, found := follower.GetQuotation(types.USD, types.MXN)
+	assert.False(t, found)
+
+	fake.Advance(time.Hour)
+	fake.BlockUntil(1)
+
+	_, found = follower.GetQuotation(types.USD, types.MXN)
+	assert.True(t, found)
+}
+
+// countingProvider считает походы за последними курсами и может держать их, пока не закрыт release
+type countingProvider struct {
+	cc.Interface
+	calls   atomic.Int32
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (c *countingProvider) GetLatestRates(ctx context.Context, base types.Currency, quotes []types.Currency) ([]cc.CurrencyRate, error) {
+	c.calls.Add(1)
+
+	if c.release != nil {
+		close(c.entered)
+		<-c.release
+	}
+
+	return c.Interface.GetLatestRates(ctx, base, quotes)
+}
+
+func createRequest(t *testing.T, db *inmemory.Db, base types.Currency, quote types.Currency) qr.QuotationRequest {
+	request, err := qr.New(base, quote, uuid.New())
+	assert.NoError(t, err)
+	assert.NoError(t, db.QuotationRequestCreateOrGetByIdempotencyKey(&request))
+
+	return request
+}
+
+func waitForUpdate(t *testing.T, updated <-chan struct{}) {
+	select {
+	case <-updated:
+	case <-time.After(time.Second):
+		t.Fatal("quotation was not updated")
+	}
+}
+
+func Test_WakeUpCoalescesBursts(t *testing.T) {
+	db := inmemory.New()
+	provider := &countingProvider{Interface: cc.NewMock()}
+	fake := clock.NewFake(time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC))
+
+	manager := New(time.Minute, db, provider)
+	manager.SetClock(fake)
+
+	createRequest(t, db, types.USD, types.MXN)
+
+	updated, unsubscribe := manager.NotifyOnUpdate(types.USD, types.MXN)
+	defer unsubscribe()
+
+	manager.Start(context.Background())
+	defer manager.Stop()
+
+	// запросы, накопившиеся до старта, обрабатываются после debounce, без ожидания runInterval
+	fake.BlockUntil(1)
+	fake.Advance(defaultDebounce)
+	waitForUpdate(t, updated)
+
+	assert.Equal(t, int32(1), provider.calls.Load())
+
+	createRequest(t, db, types.EUR, types.MXN)
+
+	updated, unsubscribe = manager.NotifyOnUpdate(types.EUR, types.MXN)
+	defer unsubscribe()
+
+	for range 3 {
+		manager.SetRunRequired()
+	}
+
+	// пачка пробуждений ждет, пока не пройдет runInterval с прошлого похода к провайдеру
+	fake.BlockUntil(1)
+	fake.Advance(time.Minute / 2)
+	fake.BlockUntil(1)
+
+	assert.Equal(t, int32(1), provider.calls.Load())
+
+	fake.Advance(time.Minute / 2)
+	waitForUpdate(t, updated)
+
+	// все пробуждения схлопнулись в один проход, дальше цикл ждет новых без таймеров
+	fake.BlockUntil(0)
+
+	assert.Equal(t, int32(2), provider.calls.Load())
+}
+
+func Test_StopWaitsForInFlightRun(t *testing.T) {
+	db := inmemory.New()
+	provider := &countingProvider{Interface: cc.NewMock(), entered: make(chan struct{}), release: make(chan struct{})}
+	fake := clock.NewFake(time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC))
+
+	manager := New(time.Minute, db, provider)
+	manager.SetClock(fake)
+
+	request := createRequest(t, db, types.USD, types.MXN)
+
+	manager.Start(context.Background())
+
+	fake.BlockUntil(1)
+	fake.Advance(defaultDebounce)
+	<-provider.entered
+
+	stopped := make(chan struct{})
+
+	go func() {
+		manager.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		t.Fatal("stop returned before in-flight run finished")
+	default:
+	}
+
+	close(provider.release)
+	<-stopped
+
+	stored, err := db.QuotationRequestGetById(request.Id)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, stored.CompletedAt)
+}
+
+type failingProvider struct {
+	cc.Interface
+	err error
+}
+
+func (f failingProvider) GetLatestRates(_ context.Context, _ types.Currency, _ []types.Currency) ([]cc.CurrencyRate, error) {
+	return nil, f.err
+}
+
+func Test_RequestFailsAfterMaxAttempts(t *testing.T) {
+	db := inmemory.New()
+	provider := failingProvider{Interface: cc.NewMock(), err: fmt.Errorf("%w for USD/MXN", cc.ErrRateNotFound)}
+	fake := clock.NewFake(time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC))
+
+	manager := New(time.Minute, db, provider)
+	manager.SetClock(fake)
+	manager.SetRequestPolicy(2, 0)
+
+	request := createRequest(t, db, types.USD, types.MXN)
+
+	updated, unsubscribe := manager.NotifyOnUpdate(types.USD, types.MXN)
+	defer unsubscribe()
+
+	manager.Start(context.Background())
+	defer manager.Stop()
+
+	fake.BlockUntil(1)
+	fake.Advance(defaultDebounce)
+	waitForUpdate(t, updated)
+
+	stored, err := db.QuotationRequestGetById(request.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, qr.Pending, stored.Status)
+	assert.Equal(t, 1, stored.Attempts)
+
+	updated, unsubscribe = manager.NotifyOnUpdate(types.USD, types.MXN)
+	defer unsubscribe()
+
+	// повтор не раньше runInterval после неудачной попытки
+	fake.BlockUntil(1)
+	fake.Advance(time.Minute)
+	waitForUpdate(t, updated)
+
+	stored, err = db.QuotationRequestGetById(request.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, qr.Failed, stored.Status)
+	assert.Equal(t, 2, stored.Attempts)
+	assert.Equal(t, &qr.Failure{Code: qr.ErrorRateNotFound, Message: "rate not found for USD/MXN"}, stored.LastError())
+}
+
+func Test_FailureOf(t *testing.T) {
+	circuitOpen := fmt.Errorf("%w: frankfurter", cc.ErrCircuitOpen)
+	notFound := fmt.Errorf("%w for USD/MXN", cc.ErrRateNotFound)
+
+	assert.Equal(t, qr.ErrorRateNotFound, failureOf(types.USD, types.MXN, nil).Code)
+	assert.Equal(t, qr.ErrorRateNotFound, failureOf(types.USD, types.MXN, notFound).Code)
+	assert.Equal(t, qr.ErrorProviderUnavailable, failureOf(types.USD, types.MXN, errors.Join(notFound, circuitOpen)).Code)
+	assert.Equal(t, qr.ErrorProviderTimeout, failureOf(types.USD, types.MXN, cc.ErrProviderTimeout).Code)
+	assert.Equal(t, qr.ErrorProvider, failureOf(types.USD, types.MXN, errors.New("unexpected status 418")).Code)
+}
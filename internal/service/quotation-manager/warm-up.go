@@ -0,0 +1,89 @@
+package quotation_manager
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"plata_currency_quotation/internal/lib/logger/sl"
+	"slices"
+)
+
+// Ready - кэш прогрет после старта, до этого last-requested может ошибочно отвечать 404
+func (q *QuotationManager) Ready() bool {
+	return q.ready.Load()
+}
+
+// WarmUp заполняет кэш последними курсами из бд: завершенные запросы и история. По каждой паре берется
+// более свежий курс, уже обновленные в кэше пары не перезаписываются
+func (q *QuotationManager) WarmUp() error {
+	_, err := q.loadFromDb()
+
+	return err
+}
+
+// loadFromDb - WarmUp, который возвращает ключи пар, чей курс в кэше поменялся
+func (q *QuotationManager) loadFromDb() ([]string, error) {
+	requests, requestsErr := q.db.QuotationRequestGetLatestCompleted()
+	history, historyErr := q.db.QuotationHistoryGetLatest()
+
+	if err := errors.Join(requestsErr, historyErr); err != nil {
+		return nil, err
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	var changed []string
+
+	for _, quotation := range append(requests, history...) {
+		key := asKey(quotation.BaseCurrency, quotation.QuoteCurrency)
+
+		if cached, exists := q.quotations[key]; exists && !quotation.UpdatedAt.After(cached.UpdatedAt) {
+			continue
+		}
+
+		q.quotations[key] = quotation.QuotationInfo
+
+		if !slices.Contains(changed, key) {
+			changed = append(changed, key)
+		}
+	}
+
+	return changed, nil
+}
+
+// syncFromDb - для реплики, которая не лидер: курсы в бд пишет лидер, подтягиваем их в кэш и будим ожидающих
+// только по парам, чей курс поменялся, чтобы они перечитали свои запросы
+func (q *QuotationManager) syncFromDb() {
+	changed, err := q.loadFromDb()
+
+	if err != nil {
+		q.logger.Error("failed to sync quotation cache", sl.Err(err))
+
+		return
+	}
+
+	for _, key := range changed {
+		q.notifyWaiters(key)
+	}
+}
+
+// warmUpUntilDone повторяет прогрев с интервалом runInterval, пока бд не ответит. false - менеджер остановили раньше
+func (q *QuotationManager) warmUpUntilDone(ctx context.Context) bool {
+	for {
+		err := q.WarmUp()
+
+		if err == nil {
+			q.ready.Store(true)
+			q.logger.Info("quotation cache is warmed up", slog.Int("pairs", len(q.Snapshot())))
+
+			return true
+		}
+
+		q.logger.Error("failed to warm up quotation cache", sl.Err(err))
+
+		if !q.sleep(ctx, q.runInterval) {
+			return false
+		}
+	}
+}
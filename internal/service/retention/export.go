@@ -0,0 +1,106 @@
+package retention
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	qr "plata_currency_quotation/internal/domain/enity/quotation-request"
+	"plata_currency_quotation/internal/domain/types"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// record - строка NDJSON экспорта. Секрет вебхука не выгружается
+type record struct {
+	Id             uuid.UUID         `json:"id"`
+	IdempotencyKey uuid.UUID         `json:"idempotencyKey"`
+	BatchId        *uuid.UUID        `json:"batchId,omitempty"`
+	BaseCurrency   types.Currency    `json:"baseCurrency"`
+	QuoteCurrency  types.Currency    `json:"quoteCurrency"`
+	AsOf           string            `json:"asOf,omitempty"`
+	Status         qr.Status         `json:"status"`
+	Attempts       int               `json:"attempts"`
+	Rate           *types.Decimal    `json:"rate,omitempty"`
+	Provider       *string           `json:"provider,omitempty"`
+	Detail         *types.RateDetail `json:"detail,omitempty"`
+	ErrorCode      *string           `json:"errorCode,omitempty"`
+	ErrorMessage   *string           `json:"errorMessage,omitempty"`
+	CallbackUrl    *string           `json:"callbackUrl,omitempty"`
+	CreatedAt      time.Time         `json:"createdAt"`
+	CompletedAt    *time.Time        `json:"completedAt,omitempty"`
+}
+
+func toRecord(request qr.QuotationRequest) record {
+	r := record{
+		Id:             request.Id,
+		IdempotencyKey: request.IdempotencyKey,
+		BatchId:        request.BatchId,
+		BaseCurrency:   request.BaseCurrency,
+		QuoteCurrency:  request.QuoteCurrency,
+		Status:         request.Status,
+		Attempts:       request.Attempts,
+		Rate:           request.Rate,
+		Provider:       request.Provider,
+		Detail:         request.Detail,
+		ErrorCode:      request.ErrorCode,
+		ErrorMessage:   request.ErrorMessage,
+		CallbackUrl:    request.CallbackUrl,
+		CreatedAt:      request.CreatedAt,
+		CompletedAt:    request.CompletedAt,
+	}
+
+	if request.AsOf != nil {
+		r.AsOf = request.AsOf.Format(time.DateOnly)
+	}
+
+	return r
+}
+
+// exporter пишет один файл на запуск, создает его при первой пачке. Пачка дописывается и сбрасывается на диск до
+// коммита удаления, так что при сбое коммита строки могут попасть в экспорт дважды - дубли отсеиваются по id
+type exporter struct {
+	path string
+	file *os.File
+}
+
+func newExporter(dir string, start time.Time) *exporter {
+	name := fmt.Sprintf("quotation-requests-%s.ndjson", start.UTC().Format("20060102T150405Z"))
+
+	return &exporter{path: filepath.Join(dir, name)}
+}
+
+func (e *exporter) write(requests []qr.QuotationRequest) error {
+	if e.file == nil {
+		file, err := os.OpenFile(e.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+
+		if err != nil {
+			return fmt.Errorf("failed to open export file: %w", err)
+		}
+
+		e.file = file
+	}
+
+	encoder := json.NewEncoder(e.file)
+
+	for _, request := range requests {
+		if err := encoder.Encode(toRecord(request)); err != nil {
+			return fmt.Errorf("failed to write export file: %w", err)
+		}
+	}
+
+	if err := e.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync export file: %w", err)
+	}
+
+	return nil
+}
+
+func (e *exporter) close() error {
+	if e.file == nil {
+		return nil
+	}
+
+	return e.file.Close()
+}
@@ -0,0 +1,200 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	qr "plata_currency_quotation/internal/domain/enity/quotation-request"
+	"plata_currency_quotation/internal/lib/logger/sl"
+	"plata_currency_quotation/internal/persistence"
+	"plata_currency_quotation/internal/service/leader"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var Instance *Job
+
+var (
+	purgedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "retention_purged_requests_total",
+			Help: "Total number of quotation requests removed by retention, by final status",
+		},
+		[]string{"status"},
+	)
+
+	runsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "retention_runs_total",
+			Help: "Total number of retention runs by result",
+		},
+		[]string{"result"},
+	)
+
+	runDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "retention_run_duration_seconds",
+			Help:    "Duration of retention runs in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+	)
+
+	lastSuccess = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "retention_last_success_timestamp_seconds",
+			Help: "Unix time of the last retention run that finished without error",
+		},
+	)
+)
+
+type Policy struct {
+	// Age - запросы в конечном статусе, созданные раньше, удаляются
+	Age time.Duration
+	// BatchSize - сколько запросов удаляется одной транзакцией
+	BatchSize int
+	// ExportDir - пусто: запросы просто удаляются, иначе перед удалением дописываются NDJSON файлом в эту директорию
+	ExportDir string
+}
+
+// Job удаляет старые запросы пачками. Каждая пачка - своя короткая транзакция, строки берутся FOR UPDATE SKIP
+// LOCKED, так что менеджер котировок и API не ждут, пока пройдет вся таблица
+type Job struct {
+	interval time.Duration
+	policy   Policy
+	db       persistence.QuotationRequestPersistentOperations
+	leader   leader.Interface
+	logger   *slog.Logger
+	stop     context.CancelFunc
+	done     sync.WaitGroup
+}
+
+func New(interval time.Duration, policy Policy, db persistence.QuotationRequestPersistentOperations) *Job {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})).With(
+		"component", "service/retention",
+	)
+
+	return &Job{
+		interval: interval,
+		policy:   policy,
+		db:       db,
+		logger:   logger,
+	}
+}
+
+func (j *Job) SetupMetrics(reg *prometheus.Registry) {
+	reg.MustRegister(purgedTotal)
+	reg.MustRegister(runsTotal)
+	reg.MustRegister(runDuration)
+	reg.MustRegister(lastSuccess)
+}
+
+// SetLeader - при нескольких репликах чистит только лидер, иначе реплики делили бы одни и те же строки
+func (j *Job) SetLeader(leader leader.Interface) {
+	j.leader = leader
+}
+
+func (j *Job) Start(ctx context.Context) {
+	ctx, j.stop = context.WithCancel(ctx)
+
+	j.done.Add(1)
+
+	go func() {
+		defer j.done.Done()
+
+		for {
+			if j.leader == nil || j.leader.IsLeader() {
+				if _, err := j.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+					j.logger.Error("retention run failed", sl.Err(err))
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(j.interval):
+			}
+		}
+	}()
+}
+
+// Stop ждет окончания текущей пачки, оставшиеся удалятся при следующем запуске
+func (j *Job) Stop() {
+	if j.stop == nil {
+		return
+	}
+
+	j.stop()
+	j.done.Wait()
+}
+
+// Run - один проход: пачки по BatchSize, пока есть что удалять. Возвращает, сколько запросов удалено
+func (j *Job) Run(ctx context.Context) (int, error) {
+	start := time.Now()
+	createdBefore := start.Add(-j.policy.Age)
+
+	var archive func([]qr.QuotationRequest) error
+	var export *exporter
+
+	if j.policy.ExportDir != "" {
+		export = newExporter(j.policy.ExportDir, start)
+		archive = export.write
+	}
+
+	purged, err := j.purge(ctx, createdBefore, archive)
+
+	if export != nil {
+		err = errors.Join(err, export.close())
+	}
+
+	runDuration.Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		runsTotal.WithLabelValues("failed").Inc()
+
+		return purged, err
+	}
+
+	runsTotal.WithLabelValues("succeeded").Inc()
+	lastSuccess.SetToCurrentTime()
+
+	if purged > 0 {
+		j.logger.Info(
+			"quotation requests purged",
+			slog.Int("count", purged),
+			slog.Time("created_before", createdBefore),
+			slog.Duration("took", time.Since(start)),
+		)
+	}
+
+	return purged, nil
+}
+
+func (j *Job) purge(ctx context.Context, createdBefore time.Time, archive func([]qr.QuotationRequest) error) (int, error) {
+	total := 0
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		purged, err := j.db.QuotationRequestPurge(createdBefore, j.policy.BatchSize, archive)
+
+		if err != nil {
+			return total, err
+		}
+
+		for _, request := range purged {
+			purgedTotal.WithLabelValues(string(request.Status)).Inc()
+		}
+
+		total += len(purged)
+
+		// пропущенные SKIP LOCKED строки дождутся следующего запуска
+		if len(purged) < j.policy.BatchSize {
+			return total, nil
+		}
+	}
+}
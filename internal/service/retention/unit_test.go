@@ -0,0 +1,125 @@
+package retention
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	qr "plata_currency_quotation/internal/domain/enity/quotation-request"
+	"plata_currency_quotation/internal/domain/types"
+	"plata_currency_quotation/internal/persistence"
+	"plata_currency_quotation/internal/persistence/inmemory"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func createFinished(t *testing.T, db *inmemory.Db, count int, status qr.Status, createdAt time.Time) {
+	for range count {
+		request, err := qr.New(types.USD, types.EUR, uuid.New())
+		assert.NoError(t, err)
+
+		request.Status = status
+		request.CreatedAt = createdAt
+
+		if status == qr.Ready {
+			rate := types.MustParseDecimal("0.91")
+			request.Rate, request.CompletedAt = &rate, &createdAt
+		}
+
+		assert.NoError(t, db.QuotationRequestCreateOrGetByIdempotencyKey(&request))
+	}
+}
+
+func Test_RunPurgesInBatches(t *testing.T) {
+	db := inmemory.New()
+
+	createFinished(t, db, 4, qr.Ready, time.Now().Add(-72*time.Hour))
+	createFinished(t, db, 1, qr.Expired, time.Now().Add(-72*time.Hour))
+	createFinished(t, db, 2, qr.Ready, time.Now().Add(-time.Hour))
+
+	readyBefore := testutil.ToFloat64(purgedTotal.WithLabelValues(string(qr.Ready)))
+	expiredBefore := testutil.ToFloat64(purgedTotal.WithLabelValues(string(qr.Expired)))
+
+	job := New(time.Hour, Policy{Age: 48 * time.Hour, BatchSize: 2}, db)
+
+	purged, err := job.Run(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 5, purged)
+
+	assert.Equal(t, 4.0, testutil.ToFloat64(purgedTotal.WithLabelValues(string(qr.Ready)))-readyBefore)
+	assert.Equal(t, 1.0, testutil.ToFloat64(purgedTotal.WithLabelValues(string(qr.Expired)))-expiredBefore)
+
+	left, err := db.QuotationRequestList(persistence.QuotationRequestFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, left, 2)
+
+	purged, err = job.Run(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, purged)
+}
+
+func Test_RunExportsBeforeDelete(t *testing.T) {
+	db := inmemory.New()
+	dir := t.TempDir()
+
+	createFinished(t, db, 3, qr.Ready, time.Now().Add(-72*time.Hour))
+
+	job := New(time.Hour, Policy{Age: 48 * time.Hour, BatchSize: 2, ExportDir: dir}, db)
+
+	purged, err := job.Run(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, purged)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.ndjson"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	file, err := os.Open(files[0])
+	assert.NoError(t, err)
+
+	defer func() { _ = file.Close() }()
+
+	var records []record
+
+	for scanner := bufio.NewScanner(file); scanner.Scan(); {
+		var r record
+
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &r))
+
+		records = append(records, r)
+	}
+
+	assert.Len(t, records, 3)
+	assert.Equal(t, qr.Ready, records[0].Status)
+	assert.Equal(t, "0.91", records[0].Rate.String())
+
+	// без удаленных запросов файл не создается
+	purged, err = job.Run(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, purged)
+
+	files, err = filepath.Glob(filepath.Join(dir, "*.ndjson"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func Test_RunFailsWhenExportFails(t *testing.T) {
+	db := inmemory.New()
+
+	createFinished(t, db, 2, qr.Failed, time.Now().Add(-72*time.Hour))
+
+	job := New(time.Hour, Policy{Age: 48 * time.Hour, BatchSize: 10, ExportDir: filepath.Join(t.TempDir(), "missing")}, db)
+
+	purged, err := job.Run(context.Background())
+	assert.Error(t, err)
+	assert.Zero(t, purged)
+
+	left, err := db.QuotationRequestList(persistence.QuotationRequestFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, left, 2)
+}
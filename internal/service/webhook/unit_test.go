@@ -0,0 +1,281 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	qr "plata_currency_quotation/internal/domain/enity/quotation-request"
+	wd "plata_currency_quotation/internal/domain/enity/webhook-delivery"
+	"plata_currency_quotation/internal/domain/types"
+	"plata_currency_quotation/internal/persistence"
+	"plata_currency_quotation/internal/persistence/inmemory"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func createCompletedRequest(t *testing.T, db *inmemory.Db, url string, secret string) qr.QuotationRequest {
+	request, err := qr.New(types.USD, types.EUR, uuid.New())
+	assert.NoError(t, err)
+
+	request.CallbackUrl = &url
+	request.CallbackSecret = &secret
+
+	err = db.QuotationRequestCreateOrGetByIdempotencyKey(&request)
+	assert.NoError(t, err)
+
+	err = db.QuotationRequestCompletePending(types.USD, types.EUR, time.Now(), types.QuotationInfo{Rate: types.MustParseDecimal("0.91"), UpdatedAt: time.UnixMilli(1694613600000)})
+	assert.NoError(t, err)
+
+	return request
+}
+
+func Test_DeliverSigned(t *testing.T) {
+	received := make(chan Payload, 1)
+	secret := "top-secret"
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		timestamp, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		assert.NoError(t, err)
+
+		assert.NotEmpty(t, r.Header.Get(HeaderId))
+		assert.Equal(t, Sign(secret, timestamp, body), r.Header.Get(HeaderSignature))
+
+		var payload Payload
+		assert.NoError(t, json.Unmarshal(body, &payload))
+
+		received <- payload
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	db := inmemory.New()
+	request := createCompletedRequest(t, db, receiver.URL, secret)
+
+	dispatcher := New(time.Second, time.Second, Policy{MaxAttempts: 3, InitialBackoff: time.Second, MaxBackoff: time.Minute}, db)
+
+	dispatcher.dispatchDue(context.Background())
+
+	select {
+	case payload := <-received:
+		assert.Equal(t, request.Id, payload.RequestId)
+		assert.Equal(t, types.USD, payload.BaseCurrency)
+		assert.Equal(t, types.EUR, payload.QuoteCurrency)
+		assert.Equal(t, qr.Ready, payload.Status)
+		assert.Equal(t, "0.91", payload.Rate.String())
+		assert.Nil(t, payload.Error)
+		assert.Equal(t, int64(1694613600000), payload.UpdatedAt)
+	default:
+		t.Fatal("Expected webhook to be delivered")
+	}
+
+	due, err := db.WebhookDeliveryClaimDue(time.Now().Add(time.Hour), 10, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, due)
+}
+
+func Test_RetryThenDeadLetter(t *testing.T) {
+	var calls atomic.Int32
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	db := inmemory.New()
+	createCompletedRequest(t, db, receiver.URL, "secret")
+
+	dispatcher := New(time.Second, time.Second, Policy{MaxAttempts: 3, InitialBackoff: time.Hour, MaxBackoff: 2 * time.Hour}, db)
+
+	dispatcher.dispatchDue(context.Background())
+
+	assert.Equal(t, int32(1), calls.Load())
+
+	due, err := db.WebhookDeliveryClaimDue(time.Now(), 10, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, due, "retry should be postponed by backoff")
+
+	due, err = db.WebhookDeliveryClaimDue(time.Now().Add(time.Hour), 10, 0)
+	assert.NoError(t, err)
+	assert.Len(t, due, 1)
+	assert.Equal(t, 1, due[0].Attempts)
+	assert.NotNil(t, due[0].LastError)
+
+	for range 2 {
+		due, err := db.WebhookDeliveryClaimDue(time.Now().Add(3*time.Hour), 10, 0)
+		assert.NoError(t, err)
+		assert.Len(t, due, 1)
+
+		delivery := due[0]
+		delivery.NextAttemptAt = time.Now()
+		assert.NoError(t, db.WebhookDeliveryUpdate(&delivery))
+
+		dispatcher.dispatchDue(context.Background())
+	}
+
+	assert.Equal(t, int32(3), calls.Load())
+
+	due, err = db.WebhookDeliveryClaimDue(time.Now().Add(24*time.Hour), 10, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, due, "delivery should be in dead letter")
+}
+
+func Test_ConcurrentDispatchersDeliverOnce(t *testing.T) {
+	var calls atomic.Int32
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	db := inmemory.New()
+	createCompletedRequest(t, db, receiver.URL, "secret")
+
+	policy := Policy{MaxAttempts: 3, InitialBackoff: time.Second, MaxBackoff: time.Minute}
+
+	var done sync.WaitGroup
+
+	for range 4 {
+		dispatcher := New(time.Second, time.Second, policy, db)
+
+		done.Go(func() { dispatcher.dispatchDue(context.Background()) })
+	}
+
+	done.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func Test_StopInterruptsDelivery(t *testing.T) {
+	entered := make(chan struct{})
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// пока тело не дочитано, сервер не замечает закрытия соединения клиентом
+		_, _ = io.Copy(io.Discard, r.Body)
+
+		close(entered)
+		<-r.Context().Done()
+	}))
+	defer receiver.Close()
+
+	db := inmemory.New()
+	createCompletedRequest(t, db, receiver.URL, "secret")
+
+	// таймаут запроса больше времени теста: вернуть Stop может только отмена
+	dispatcher := New(time.Hour, time.Hour, Policy{MaxAttempts: 3, InitialBackoff: time.Hour, MaxBackoff: time.Hour}, db)
+	dispatcher.Start(context.Background())
+
+	<-entered
+
+	stopped := make(chan struct{})
+
+	go func() {
+		dispatcher.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("stop waited for in-flight delivery")
+	}
+
+	// доставка вернулась в очередь без засчитанной попытки
+	due, err := db.WebhookDeliveryClaimDue(time.Now(), 10, 0)
+	assert.NoError(t, err)
+	assert.Len(t, due, 1)
+	assert.Equal(t, 0, due[0].Attempts)
+	assert.Equal(t, wd.Pending, due[0].Status)
+}
+
+func Test_DeliverFailedAndExpired(t *testing.T) {
+	received := make(chan Payload, 2)
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload Payload
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+
+		received <- payload
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	db := inmemory.New()
+	url, secret := receiver.URL, "secret"
+
+	failed, err := qr.New(types.USD, types.EUR, uuid.New())
+	assert.NoError(t, err)
+	failed.CallbackUrl, failed.CallbackSecret = &url, &secret
+	assert.NoError(t, db.QuotationRequestCreateOrGetByIdempotencyKey(&failed))
+
+	scope := persistence.PendingScope{BaseCurrency: types.USD, QuoteCurrency: types.EUR, PendingAt: time.Now()}
+	assert.NoError(t, db.QuotationRequestStartAttempt(scope))
+	assert.NoError(t, db.QuotationRequestFailAttempt(scope, qr.Failure{Code: qr.ErrorRateNotFound, Message: "no rate"}, 1))
+
+	expired, err := qr.New(types.USD, types.MXN, uuid.New())
+	assert.NoError(t, err)
+	expired.CallbackUrl, expired.CallbackSecret = &url, &secret
+	assert.NoError(t, db.QuotationRequestCreateOrGetByIdempotencyKey(&expired))
+
+	count, err := db.QuotationRequestExpire(time.Now().Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	dispatcher := New(time.Second, time.Second, Policy{MaxAttempts: 3, InitialBackoff: time.Second, MaxBackoff: time.Minute}, db)
+
+	dispatcher.dispatchDue(context.Background())
+
+	close(received)
+
+	payloads := make(map[uuid.UUID]Payload)
+
+	for payload := range received {
+		payloads[payload.RequestId] = payload
+	}
+
+	assert.Len(t, payloads, 2)
+
+	assert.Equal(t, qr.Failed, payloads[failed.Id].Status)
+	assert.Nil(t, payloads[failed.Id].Rate)
+	assert.Equal(t, &PayloadError{Code: qr.ErrorRateNotFound, Message: "no rate"}, payloads[failed.Id].Error)
+
+	assert.Equal(t, qr.Expired, payloads[expired.Id].Status)
+	assert.Equal(t, qr.ErrorExpired, payloads[expired.Id].Error.Code)
+}
+
+func Test_Backoff(t *testing.T) {
+	policy := Policy{MaxAttempts: 10, InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}
+
+	assert.Equal(t, time.Second, policy.Backoff(1))
+	assert.Equal(t, 2*time.Second, policy.Backoff(2))
+	assert.Equal(t, 8*time.Second, policy.Backoff(4))
+	assert.Equal(t, 10*time.Second, policy.Backoff(5))
+	assert.Equal(t, 10*time.Second, policy.Backoff(50))
+}
+
+func Test_MarkFailedDeadLetter(t *testing.T) {
+	delivery := wd.New(uuid.New(), "http://localhost", "secret", time.Now())
+
+	delivery.MarkFailed("boom", time.Now(), 2, time.Second)
+	assert.Equal(t, wd.Pending, delivery.Status)
+
+	delivery.MarkFailed("boom", time.Now(), 2, time.Second)
+	assert.Equal(t, wd.DeadLetter, delivery.Status)
+	assert.Equal(t, 2, delivery.Attempts)
+}
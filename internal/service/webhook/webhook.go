@@ -0,0 +1,340 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	qr "plata_currency_quotation/internal/domain/enity/quotation-request"
+	wd "plata_currency_quotation/internal/domain/enity/webhook-delivery"
+	"plata_currency_quotation/internal/domain/types"
+	"plata_currency_quotation/internal/lib/logger/sl"
+	"plata_currency_quotation/internal/persistence"
+	"plata_currency_quotation/internal/service/leader"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	HeaderSignature = "X-Webhook-Signature"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderId        = "X-Webhook-Id"
+
+	batchSize = 100
+	// claimSize - сколько доставок забирается за раз. Они уходят последовательно, поэтому аренда - claimSize таймаутов,
+	// и упавший диспетчер задерживает чужие доставки не дольше нее
+	claimSize = 10
+)
+
+var Instance *Dispatcher
+
+var (
+	deliveriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_deliveries_total",
+			Help: "Total number of webhook delivery attempts by result",
+		},
+		[]string{"result"},
+	)
+
+	deliveryDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "webhook_delivery_duration_seconds",
+			Help:    "Duration of webhook delivery attempts in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+	)
+)
+
+type Policy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// Backoff - задержка перед следующей попыткой после attempts неудачных
+func (p Policy) Backoff(attempts int) time.Duration {
+	backoff := p.InitialBackoff
+
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+
+		if backoff >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+
+	return min(backoff, p.MaxBackoff)
+}
+
+// Payload - rate и updatedAt только для Ready, error - для Failed и Expired
+type Payload struct {
+	RequestId     uuid.UUID      `json:"requestId"`
+	BaseCurrency  types.Currency `json:"baseCurrency"`
+	QuoteCurrency types.Currency `json:"quoteCurrency"`
+	Status        qr.Status      `json:"status"`
+	Rate          *types.Decimal `json:"rate,omitempty"`
+	// Unix timestamp in milliseconds
+	UpdatedAt int64         `json:"updatedAt,omitempty"`
+	Error     *PayloadError `json:"error,omitempty"`
+}
+
+type PayloadError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type Dispatcher struct {
+	interval time.Duration
+	policy   Policy
+	db       persistence.Interface
+	client   *http.Client
+	logger   *slog.Logger
+	leader   leader.Interface
+	stop     context.CancelFunc
+	done     sync.WaitGroup
+}
+
+func New(interval time.Duration, requestTimeout time.Duration, policy Policy, db persistence.Interface) *Dispatcher {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})).With(
+		"component", "service/webhook",
+	)
+
+	return &Dispatcher{
+		interval: interval,
+		policy:   policy,
+		db:       db,
+		client: &http.Client{
+			Timeout: requestTimeout,
+		},
+		logger: logger,
+	}
+}
+
+func (d *Dispatcher) SetupMetrics(reg *prometheus.Registry) {
+	reg.MustRegister(deliveriesTotal)
+	reg.MustRegister(deliveryDuration)
+}
+
+// SetLeader - при нескольких репликах вебхуки отправляет только лидер, иначе одна доставка уйдет несколько раз
+func (d *Dispatcher) SetLeader(leader leader.Interface) {
+	d.leader = leader
+}
+
+func (d *Dispatcher) Start(ctx context.Context) {
+	ctx, d.stop = context.WithCancel(ctx)
+
+	d.done.Add(1)
+
+	go func() {
+		defer d.done.Done()
+
+		for {
+			if d.leader == nil || d.leader.IsLeader() {
+				d.dispatchDue(ctx)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(d.interval):
+			}
+		}
+	}()
+}
+
+// Stop обрывает начатую доставку и ждет, пока неотправленные доставки пачки вернутся в очередь
+func (d *Dispatcher) Stop() {
+	if d.stop == nil {
+		return
+	}
+
+	d.stop()
+	d.done.Wait()
+}
+
+// Sign - hex(HMAC-SHA256(secret, "<timestamp>.<body>")), timestamp в секундах, передается в HeaderTimestamp
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// dispatchDue отправляет до batchSize доставок, забирая их по claimSize
+func (d *Dispatcher) dispatchDue(ctx context.Context) {
+	for sent := 0; sent < batchSize && ctx.Err() == nil; {
+		deliveries, err := d.db.WebhookDeliveryClaimDue(time.Now(), claimSize, claimSize*d.client.Timeout)
+
+		if err != nil {
+			d.logger.Error("failed to get due webhook deliveries", sl.Err(err))
+
+			return
+		}
+
+		d.deliverClaimed(ctx, deliveries)
+
+		if len(deliveries) < claimSize {
+			return
+		}
+
+		sent += len(deliveries)
+	}
+}
+
+func (d *Dispatcher) deliverClaimed(ctx context.Context, deliveries []wd.WebhookDelivery) {
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		err := d.deliver(ctx, delivery)
+
+		// оборванная остановкой доставка попыткой не считается, она и остаток пачки уходят в очередь сразу,
+		// а не по истечении аренды
+		if ctx.Err() != nil {
+			d.release(deliveries[i:])
+
+			return
+		}
+
+		if err != nil {
+			delivery.MarkFailed(err.Error(), time.Now(), d.policy.MaxAttempts, d.policy.Backoff(delivery.Attempts+1))
+
+			if delivery.Status == wd.DeadLetter {
+				deliveriesTotal.WithLabelValues("dead_letter").Inc()
+
+				d.logger.Error(
+					"webhook delivery moved to dead letter",
+					slog.String("delivery_id", delivery.Id.String()),
+					sl.Err(err),
+				)
+			} else {
+				deliveriesTotal.WithLabelValues("failed").Inc()
+
+				d.logger.Warn(
+					"webhook delivery failed, will retry",
+					slog.String("delivery_id", delivery.Id.String()),
+					slog.Time("next_attempt_at", delivery.NextAttemptAt),
+					sl.Err(err),
+				)
+			}
+		} else {
+			delivery.MarkDelivered(time.Now())
+
+			deliveriesTotal.WithLabelValues("delivered").Inc()
+		}
+
+		if err := d.db.WebhookDeliveryUpdate(delivery); err != nil {
+			d.logger.Error("failed to update webhook delivery", sl.Err(err))
+		}
+	}
+}
+
+func (d *Dispatcher) release(deliveries []wd.WebhookDelivery) {
+	now := time.Now()
+
+	for i := range deliveries {
+		deliveries[i].NextAttemptAt = now
+
+		if err := d.db.WebhookDeliveryUpdate(&deliveries[i]); err != nil {
+			d.logger.Error("failed to release webhook delivery", sl.Err(err))
+		}
+	}
+}
+
+func newPayload(request *qr.QuotationRequest) (Payload, error) {
+	if request == nil || !request.Status.IsFinished() {
+		return Payload{}, errors.New("request is not finished")
+	}
+
+	payload := Payload{
+		RequestId:     request.Id,
+		BaseCurrency:  request.BaseCurrency,
+		QuoteCurrency: request.QuoteCurrency,
+		Status:        request.Status,
+	}
+
+	if request.Status == qr.Ready {
+		if request.CompletedAt == nil || request.Rate == nil {
+			return Payload{}, errors.New("ready request has no rate")
+		}
+
+		payload.Rate = request.Rate
+		payload.UpdatedAt = request.CompletedAt.UnixMilli()
+
+		return payload, nil
+	}
+
+	if failure := request.LastError(); failure != nil {
+		payload.Error = &PayloadError{Code: failure.Code, Message: failure.Message}
+	}
+
+	return payload, nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery *wd.WebhookDelivery) error {
+	request, err := d.db.QuotationRequestGetById(delivery.RequestId)
+
+	if err != nil {
+		return fmt.Errorf("failed to get quotation request: %w", err)
+	}
+
+	payload, err := newPayload(request)
+
+	if err != nil {
+		return fmt.Errorf("quotation request %s: %w", delivery.RequestId, err)
+	}
+
+	body, err := json.Marshal(payload)
+
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(body))
+
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	timestamp := time.Now().Unix()
+
+	httpRequest.Header.Set("Content-Type", "application/json")
+	httpRequest.Header.Set(HeaderId, delivery.Id.String())
+	httpRequest.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	httpRequest.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, body))
+
+	start := time.Now()
+	resp, err := d.client.Do(httpRequest)
+	deliveryDuration.Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+
+	defer func() {
+		err := resp.Body.Close()
+
+		if err != nil {
+			d.logger.Error("failed to close response body", sl.Err(err))
+		}
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("receiver responded with status: %d", resp.StatusCode)
+	}
+
+	return nil
+}
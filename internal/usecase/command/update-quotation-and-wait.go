@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	qr "plata_currency_quotation/internal/domain/enity/quotation-request"
+	"plata_currency_quotation/internal/domain/types"
+	qm "plata_currency_quotation/internal/service/quotation-manager"
+	qry "plata_currency_quotation/internal/usecase/query"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type UpdateQuotationAndWait struct {
+	UpdateQuotation
+	Wait time.Duration
+}
+
+// WaitResult - если запрос не успел выполниться за Wait, Ready = false и заполнен только Id.
+// Для Failed и Expired заполнены Status и Error
+type WaitResult struct {
+	Id        uuid.UUID
+	Ready     bool
+	Status    qr.Status
+	Error     *qr.Failure
+	Rate      types.Decimal
+	UpdatedAt int64
+	Detail    *types.RateDetail
+}
+
+func (u UpdateQuotationAndWait) Execute(ctx context.Context, log *slog.Logger) (WaitResult, error) {
+	request, err := u.UpdateQuotation.save(log)
+
+	if err != nil {
+		return WaitResult{}, err
+	}
+
+	result := Result{Id: request.Id}
+
+	ctx, cancel := context.WithTimeout(ctx, u.Wait)
+	defer cancel()
+
+	for {
+		// подписываемся до проверки, иначе можно пропустить обновление между проверкой и ожиданием
+		// пара сохраненного запроса, а не из тела: повторный ключ идемпотентности мог прийти с другой парой
+		updated, unsubscribe := qm.Instance.NotifyOnUpdate(request.BaseCurrency, request.QuoteCurrency)
+
+		query := qry.GetQuotationByRequestId{Id: result.Id}
+
+		quotation, err := query.Run(ctx, log)
+
+		switch {
+		case err == nil && quotation.Status != qr.Ready:
+			unsubscribe()
+
+			return WaitResult{Id: result.Id, Status: quotation.Status, Error: quotation.Error}, nil
+		case err == nil:
+			unsubscribe()
+
+			return WaitResult{
+				Id:        result.Id,
+				Ready:     true,
+				Status:    qr.Ready,
+				Rate:      quotation.Rate,
+				UpdatedAt: quotation.UpdatedAt,
+				Detail:    quotation.Detail,
+			}, nil
+		case !errors.Is(err, qry.ErrRequestNotReady):
+			unsubscribe()
+
+			return WaitResult{}, err
+		}
+
+		select {
+		case <-updated:
+		case <-ctx.Done():
+			unsubscribe()
+
+			return WaitResult{Id: result.Id}, nil
+		}
+	}
+}
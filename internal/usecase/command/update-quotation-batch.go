@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	qb "plata_currency_quotation/internal/domain/enity/quotation-batch"
+	"plata_currency_quotation/internal/domain/types"
+	"plata_currency_quotation/internal/lib/logger/sl"
+	"plata_currency_quotation/internal/persistence"
+	qm "plata_currency_quotation/internal/service/quotation-manager"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type UpdateQuotationBatch struct {
+	IdempotencyKey uuid.UUID
+	Pairs          [][2]types.Currency
+	// AsOf - дата исторического курса для всех пар, nil - последний
+	AsOf *time.Time
+}
+
+func (u UpdateQuotationBatch) Execute(_ context.Context, log *slog.Logger) (Result, error) {
+	if len(u.Pairs) == 0 {
+		return Result{}, qb.ErrEmptyBatch
+	}
+
+	batch := qb.New(u.IdempotencyKey)
+
+	for _, pair := range u.Pairs {
+		single := UpdateQuotation{
+			BaseCurrency:   pair[0],
+			QuoteCurrency:  pair[1],
+			IdempotencyKey: qb.RequestIdempotencyKey(u.IdempotencyKey, pair[0], pair[1]),
+			AsOf:           u.AsOf,
+		}
+
+		request, err := single.newRequest(log)
+
+		if err != nil {
+			return Result{}, err
+		}
+
+		if err := batch.Add(request); err != nil {
+			return Result{}, err
+		}
+	}
+
+	if err := persistence.Instance.QuotationBatchCreateOrGetByIdempotencyKey(&batch); err != nil {
+		log.Error("failed to save quotation batch in db", sl.Err(err))
+
+		return Result{}, err
+	}
+
+	if batch.Completed() < len(batch.Requests) {
+		qm.Instance.SetRunRequired()
+	}
+
+	return Result{
+		Id: batch.Id,
+	}, nil
+}
@@ -8,6 +8,7 @@ import (
 	"plata_currency_quotation/internal/lib/logger/sl"
 	"plata_currency_quotation/internal/persistence"
 	qm "plata_currency_quotation/internal/service/quotation-manager"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -16,6 +17,10 @@ type UpdateQuotation struct {
 	BaseCurrency   types.Currency
 	QuoteCurrency  types.Currency
 	IdempotencyKey uuid.UUID
+	CallbackUrl    string
+	CallbackSecret string
+	// AsOf - дата исторического курса, nil - последний
+	AsOf *time.Time
 }
 
 type Result struct {
@@ -23,23 +28,76 @@ type Result struct {
 }
 
 func (u UpdateQuotation) Execute(_ context.Context, log *slog.Logger) (Result, error) {
-	quotationRequest, err := qr.New(u.BaseCurrency, u.QuoteCurrency, u.IdempotencyKey)
+	quotationRequest, err := u.save(log)
 
 	if err != nil {
 		return Result{}, err
 	}
 
+	return Result{
+		Id: quotationRequest.Id,
+	}, nil
+}
+
+// save - по уже известному ключу идемпотентности возвращается сохраненный запрос, его пара может отличаться от u
+func (u UpdateQuotation) save(log *slog.Logger) (qr.QuotationRequest, error) {
+	quotationRequest, err := u.newRequest(log)
+
+	if err != nil {
+		return qr.QuotationRequest{}, err
+	}
+
+	if u.CallbackUrl != "" {
+		quotationRequest.CallbackUrl = &u.CallbackUrl
+		quotationRequest.CallbackSecret = &u.CallbackSecret
+	}
+
 	err = persistence.Instance.QuotationRequestCreateOrGetByIdempotencyKey(&quotationRequest)
 
 	if err != nil {
 		log.Error("failed to save quotation request in db", sl.Err(err))
 
-		return Result{}, err
+		return qr.QuotationRequest{}, err
 	}
 
-	qm.Instance.SetRunRequired()
+	if !quotationRequest.Status.IsFinished() {
+		qm.Instance.SetRunRequired()
+	}
 
-	return Result{
-		Id: quotationRequest.Id,
-	}, nil
+	return quotationRequest, nil
+}
+
+func (u UpdateQuotation) newRequest(log *slog.Logger) (qr.QuotationRequest, error) {
+	if u.AsOf == nil {
+		return qr.New(u.BaseCurrency, u.QuoteCurrency, u.IdempotencyKey)
+	}
+
+	quotationRequest, err := qr.NewHistorical(u.BaseCurrency, u.QuoteCurrency, u.IdempotencyKey, *u.AsOf)
+
+	if err != nil {
+		return qr.QuotationRequest{}, err
+	}
+
+	// исторический курс не меняется, поэтому любой завершенный запрос на ту же дату - постоянный кэш
+	completed, err := persistence.Instance.QuotationRequestGetCompletedHistorical(persistence.HistoricalPair{
+		BaseCurrency:  quotationRequest.BaseCurrency,
+		QuoteCurrency: quotationRequest.QuoteCurrency,
+		AsOf:          *quotationRequest.AsOf,
+	})
+
+	if err != nil {
+		log.Error("failed to get completed historical quotation request", sl.Err(err))
+
+		return qr.QuotationRequest{}, err
+	}
+
+	if completed != nil {
+		quotationRequest.Status = qr.Ready
+		quotationRequest.Rate = completed.Rate
+		quotationRequest.CompletedAt = completed.CompletedAt
+		quotationRequest.Provider = completed.Provider
+		quotationRequest.Detail = completed.Detail
+	}
+
+	return quotationRequest, nil
 }
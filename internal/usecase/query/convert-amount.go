@@ -0,0 +1,56 @@
+package qry
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	quotation_request "plata_currency_quotation/internal/domain/enity/quotation-request"
+	"plata_currency_quotation/internal/domain/types"
+	qm "plata_currency_quotation/internal/service/quotation-manager"
+	"time"
+)
+
+var ErrNegativeAmount = errors.New("amount must not be negative")
+
+var ErrQuotationTooOld = errors.New("cached quotation is older than allowed")
+
+type ConvertAmount struct {
+	From   types.Currency
+	To     types.Currency
+	Amount types.Decimal
+	// MaxAge - максимальный возраст кэшированного курса
+	MaxAge time.Duration
+}
+
+type ConvertAmountResponse struct {
+	// Result округлен до minor units валюты To
+	Result    types.Decimal
+	Rate      types.Decimal
+	UpdatedAt time.Time
+}
+
+func (q *ConvertAmount) Run(_ context.Context, _ *slog.Logger) (ConvertAmountResponse, error) {
+	if q.From == q.To {
+		return ConvertAmountResponse{}, quotation_request.ErrSameCurrency
+	}
+
+	if q.Amount.Sign() < 0 {
+		return ConvertAmountResponse{}, ErrNegativeAmount
+	}
+
+	quotation, found := qm.Instance.GetQuotation(q.From, q.To)
+
+	if !found {
+		return ConvertAmountResponse{}, ErrNoQuotationData
+	}
+
+	if time.Since(quotation.UpdatedAt) > q.MaxAge {
+		return ConvertAmountResponse{}, ErrQuotationTooOld
+	}
+
+	return ConvertAmountResponse{
+		Result:    types.Rounding.ApplyAmount(q.To, q.Amount.Mul(quotation.Rate)),
+		Rate:      quotation.Rate,
+		UpdatedAt: quotation.UpdatedAt,
+	}, nil
+}
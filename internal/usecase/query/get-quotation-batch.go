@@ -0,0 +1,34 @@
+package qry
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	qb "plata_currency_quotation/internal/domain/enity/quotation-batch"
+	"plata_currency_quotation/internal/lib/logger/sl"
+	"plata_currency_quotation/internal/persistence"
+
+	"github.com/google/uuid"
+)
+
+var ErrNoBatchWithSuchId = errors.New("no batch with such id")
+
+type GetQuotationBatch struct {
+	Id uuid.UUID
+}
+
+func (q *GetQuotationBatch) Run(_ context.Context, log *slog.Logger) (*qb.QuotationBatch, error) {
+	batch, err := persistence.Instance.QuotationBatchGetById(q.Id)
+
+	if err != nil {
+		log.Error("failed to get quotation batch", sl.Err(err))
+
+		return nil, err
+	}
+
+	if batch == nil {
+		return nil, ErrNoBatchWithSuchId
+	}
+
+	return batch, nil
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	qr "plata_currency_quotation/internal/domain/enity/quotation-request"
+	"plata_currency_quotation/internal/domain/types"
 	"plata_currency_quotation/internal/lib/logger/sl"
 	"plata_currency_quotation/internal/persistence"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -18,9 +21,16 @@ type GetQuotationByRequestId struct {
 	Id uuid.UUID
 }
 
+// GetQuotationByRequestIdResponse - для Failed и Expired заполнены только Status, Attempts и Error
 type GetQuotationByRequestIdResponse struct {
-	Rate      string
+	Status    qr.Status
+	Attempts  int
+	Error     *qr.Failure
+	Rate      types.Decimal
 	UpdatedAt int64
+	AsOf      *time.Time
+	Provider  string
+	Detail    *types.RateDetail
 }
 
 func (q *GetQuotationByRequestId) Run(_ context.Context, log *slog.Logger) (GetQuotationByRequestIdResponse, error) {
@@ -36,12 +46,30 @@ func (q *GetQuotationByRequestId) Run(_ context.Context, log *slog.Logger) (GetQ
 		return GetQuotationByRequestIdResponse{}, ErrNoRequestWithSuchId
 	}
 
-	if quotationRequest.CompletedAt == nil {
+	switch quotationRequest.Status {
+	case qr.Pending, qr.InProgress:
 		return GetQuotationByRequestIdResponse{}, ErrRequestNotReady
+	case qr.Failed, qr.Expired:
+		return GetQuotationByRequestIdResponse{
+			Status:   quotationRequest.Status,
+			Attempts: quotationRequest.Attempts,
+			Error:    quotationRequest.LastError(),
+		}, nil
+	}
+
+	var provider string
+
+	if quotationRequest.Provider != nil {
+		provider = *quotationRequest.Provider
 	}
 
 	return GetQuotationByRequestIdResponse{
+		Status:    quotationRequest.Status,
+		Attempts:  quotationRequest.Attempts,
 		Rate:      *quotationRequest.Rate,
 		UpdatedAt: quotationRequest.CompletedAt.UnixMilli(),
+		AsOf:      quotationRequest.AsOf,
+		Provider:  provider,
+		Detail:    quotationRequest.Detail,
 	}, nil
 }
@@ -0,0 +1,115 @@
+package qry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	quotation_request "plata_currency_quotation/internal/domain/enity/quotation-request"
+	"plata_currency_quotation/internal/domain/types"
+	"plata_currency_quotation/internal/lib/logger/sl"
+	"plata_currency_quotation/internal/persistence"
+	"time"
+)
+
+const MaxHistoryRecords = 50000
+
+var ErrInvalidHistoryRange = errors.New("from should be before to")
+
+var ErrHistoryRangeTooLarge = fmt.Errorf("more than %d records in range", MaxHistoryRecords)
+
+type GetQuotationHistory struct {
+	Base  types.Currency
+	Quote types.Currency
+	From  time.Time
+	To    time.Time
+	// Interval - если 0, возвращаются сырые точки, иначе OHLC бакеты такой длины
+	Interval time.Duration
+}
+
+type HistoryPoint struct {
+	Rate      types.Decimal
+	Timestamp time.Time
+}
+
+type HistoryBucket struct {
+	Start time.Time
+	Open  types.Decimal
+	High  types.Decimal
+	Low   types.Decimal
+	Close types.Decimal
+	Count int
+}
+
+type GetQuotationHistoryResponse struct {
+	Points  []HistoryPoint
+	Buckets []HistoryBucket
+}
+
+func (q *GetQuotationHistory) Run(_ context.Context, log *slog.Logger) (GetQuotationHistoryResponse, error) {
+	if q.Quote == q.Base {
+		return GetQuotationHistoryResponse{}, quotation_request.ErrSameCurrency
+	}
+
+	if !q.From.Before(q.To) {
+		return GetQuotationHistoryResponse{}, ErrInvalidHistoryRange
+	}
+
+	records, err := persistence.Instance.QuotationHistoryGetRange(q.Base, q.Quote, q.From, q.To, MaxHistoryRecords+1)
+
+	if err != nil {
+		log.Error("failed to get quotation history", sl.Err(err))
+
+		return GetQuotationHistoryResponse{}, err
+	}
+
+	if len(records) > MaxHistoryRecords {
+		return GetQuotationHistoryResponse{}, ErrHistoryRangeTooLarge
+	}
+
+	points := make([]HistoryPoint, 0, len(records))
+
+	for _, record := range records {
+		points = append(points, HistoryPoint{Rate: record.Rate, Timestamp: record.FetchedAt})
+	}
+
+	if q.Interval == 0 {
+		return GetQuotationHistoryResponse{Points: points}, nil
+	}
+
+	return GetQuotationHistoryResponse{Buckets: aggregateOhlc(points, q.Interval)}, nil
+}
+
+// aggregateOhlc ожидает точки, отсортированные по времени. Бакеты выровнены по UTC, пустые не возвращаются
+func aggregateOhlc(points []HistoryPoint, interval time.Duration) []HistoryBucket {
+	buckets := make([]HistoryBucket, 0)
+
+	for _, point := range points {
+		start := point.Timestamp.UTC().Truncate(interval)
+
+		if len(buckets) == 0 || !buckets[len(buckets)-1].Start.Equal(start) {
+			buckets = append(buckets, HistoryBucket{
+				Start: start,
+				Open:  point.Rate,
+				High:  point.Rate,
+				Low:   point.Rate,
+				Close: point.Rate,
+			})
+		}
+
+		bucket := &buckets[len(buckets)-1]
+
+		if point.Rate.Cmp(bucket.High) > 0 {
+			bucket.High = point.Rate
+		}
+
+		if point.Rate.Cmp(bucket.Low) < 0 {
+			bucket.Low = point.Rate
+		}
+
+		bucket.Close = point.Rate
+		bucket.Count++
+	}
+
+	return buckets
+}
@@ -0,0 +1,78 @@
+package qry
+
+import (
+	"context"
+	"log/slog"
+	"plata_currency_quotation/internal/domain/types"
+	qm "plata_currency_quotation/internal/service/quotation-manager"
+	"time"
+)
+
+type SnapshotEntry struct {
+	Base  types.Currency
+	Quote types.Currency
+	types.QuotationInfo
+	// Stale - котировка старше MaxAge
+	Stale bool
+}
+
+// GetQuotationSnapshot - все кэшированные котировки. Пустые Base и Quote - без фильтра
+type GetQuotationSnapshot struct {
+	Base   types.Currency
+	Quote  types.Currency
+	MaxAge time.Duration
+}
+
+func (q *GetQuotationSnapshot) Run(_ context.Context, _ *slog.Logger) []SnapshotEntry {
+	now := time.Now()
+	result := make([]SnapshotEntry, 0)
+
+	for _, cached := range qm.Instance.Snapshot() {
+		if (q.Base != "" && cached.Base != q.Base) || (q.Quote != "" && cached.Quote != q.Quote) {
+			continue
+		}
+
+		result = append(result, toSnapshotEntry(cached.Base, cached.Quote, cached.QuotationInfo, q.MaxAge, now))
+	}
+
+	return result
+}
+
+// GetQuotations - котировки по явному списку пар, ненайденные пары возвращаются отдельно
+type GetQuotations struct {
+	Pairs  [][2]types.Currency
+	MaxAge time.Duration
+}
+
+type GetQuotationsResponse struct {
+	Found   []SnapshotEntry
+	Missing [][2]types.Currency
+}
+
+func (q *GetQuotations) Run(_ context.Context, _ *slog.Logger) GetQuotationsResponse {
+	now := time.Now()
+	result := GetQuotationsResponse{Found: make([]SnapshotEntry, 0), Missing: make([][2]types.Currency, 0)}
+
+	for _, pair := range q.Pairs {
+		quotation, found := qm.Instance.GetQuotation(pair[0], pair[1])
+
+		if !found {
+			result.Missing = append(result.Missing, pair)
+
+			continue
+		}
+
+		result.Found = append(result.Found, toSnapshotEntry(pair[0], pair[1], quotation, q.MaxAge, now))
+	}
+
+	return result
+}
+
+func toSnapshotEntry(base types.Currency, quote types.Currency, info types.QuotationInfo, maxAge time.Duration, now time.Time) SnapshotEntry {
+	return SnapshotEntry{
+		Base:          base,
+		Quote:         quote,
+		QuotationInfo: info,
+		Stale:         now.Sub(info.UpdatedAt) > maxAge,
+	}
+}
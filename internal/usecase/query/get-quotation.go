@@ -17,7 +17,7 @@ type GetQuotation struct {
 }
 
 type GetQuotationResponse struct {
-	Rate      string
+	Rate      types.Decimal
 	UpdatedAt int64
 }
 
@@ -4,7 +4,10 @@ import (
 	"context"
 	"log/slog"
 	"os"
+	qh "plata_currency_quotation/internal/domain/enity/quotation-history"
+	qr "plata_currency_quotation/internal/domain/enity/quotation-request"
 	"plata_currency_quotation/internal/domain/types"
+	"plata_currency_quotation/internal/lib/clock"
 	"plata_currency_quotation/internal/persistence"
 	"plata_currency_quotation/internal/persistence/inmemory"
 	cc "plata_currency_quotation/internal/service/currency-conversion"
@@ -18,6 +21,37 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// runOnce запускает qm.Instance на фейковых часах, проматывает debounce и ждет, пока обновятся курсы pairs
+func runOnce(t *testing.T, pairs ...[2]types.Currency) {
+	t.Helper()
+
+	fake := clock.NewFake(time.Now())
+	qm.Instance.SetClock(fake)
+
+	var updates []<-chan struct{}
+
+	for _, pair := range pairs {
+		updated, unsubscribe := qm.Instance.NotifyOnUpdate(pair[0], pair[1])
+		defer unsubscribe()
+
+		updates = append(updates, updated)
+	}
+
+	qm.Instance.Start(context.Background())
+	t.Cleanup(qm.Instance.Stop)
+
+	fake.BlockUntil(1)
+	fake.Advance(time.Second)
+
+	for _, updated := range updates {
+		select {
+		case <-updated:
+		case <-time.After(time.Second):
+			t.Fatal("quotation was not updated")
+		}
+	}
+}
+
 func Test_RequestQuotationUpdate(t *testing.T) {
 	persistence.Instance = inmemory.New()
 	cc.Instance = cc.NewMock()
@@ -53,8 +87,7 @@ func Test_RequestQuotationUpdate(t *testing.T) {
 	assert.Nil(t, quotationRequest.CompletedAt)
 	assert.Nil(t, quotationRequest.Rate)
 
-	qm.Instance.Run()
-	time.Sleep(time.Duration(100) * time.Millisecond)
+	runOnce(t, [2]types.Currency{baseCurrency, quoteCurrency})
 
 	quotationRequest, err = persistence.Instance.QuotationRequestGetById(result.Id)
 
@@ -143,8 +176,7 @@ func Test_GetQuotationByRequestId(t *testing.T) {
 		assert.Equal(t, err, qry.ErrRequestNotReady)
 	}
 
-	qm.Instance.Run()
-	time.Sleep(time.Duration(100) * time.Millisecond)
+	runOnce(t, [2]types.Currency{types.USD, types.MXN})
 
 	{
 		query := qry.GetQuotationByRequestId{Id: id}
@@ -157,7 +189,7 @@ func Test_GetQuotationByRequestId(t *testing.T) {
 		assert.NoError(t, err)
 		assert.NotNil(t, result)
 		assert.NotEqual(t, result.UpdatedAt, 0)
-		assert.NotEqual(t, result.Rate, "")
+		assert.False(t, result.Rate.IsZero())
 	}
 }
 
@@ -197,8 +229,7 @@ func Test_GetQuotationByCurrency(t *testing.T) {
 		assert.NotEqual(t, result.Id, uuid.Nil)
 	}
 
-	qm.Instance.Run()
-	time.Sleep(time.Duration(100) * time.Millisecond)
+	runOnce(t, [2]types.Currency{baseCurrency, quoteCurrency})
 
 	{
 		query := qry.GetQuotation{Base: baseCurrency, Quote: quoteCurrency}
@@ -210,6 +241,427 @@ func Test_GetQuotationByCurrency(t *testing.T) {
 
 		assert.NoError(t, err)
 		assert.NotEqual(t, result.UpdatedAt, 0)
-		assert.NotEqual(t, result.Rate, "")
+		assert.False(t, result.Rate.IsZero())
+	}
+}
+
+func Test_RequestQuotationUpdateAndWait(t *testing.T) {
+	persistence.Instance = inmemory.New()
+	cc.Instance = cc.NewMock()
+
+	qm.Instance = qm.New(
+		time.Duration(10)*time.Millisecond,
+		persistence.Instance,
+		cc.Instance,
+	)
+
+	qm.Instance.Start(context.Background())
+	defer qm.Instance.Stop()
+
+	command := cmd.UpdateQuotationAndWait{
+		UpdateQuotation: cmd.UpdateQuotation{BaseCurrency: types.USD, QuoteCurrency: types.MXN, IdempotencyKey: uuid.New()},
+		Wait:            time.Second,
+	}
+
+	result, err := command.Execute(
+		context.Background(),
+		slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	)
+
+	assert.NoError(t, err)
+	assert.NotEqual(t, result.Id, uuid.Nil)
+	assert.True(t, result.Ready)
+	assert.False(t, result.Rate.IsZero())
+	assert.NotEqual(t, result.UpdatedAt, 0)
+}
+
+func Test_RequestQuotationUpdateAndWaitExistingKey(t *testing.T) {
+	persistence.Instance = inmemory.New()
+	cc.Instance = cc.NewMock()
+
+	qm.Instance = qm.New(
+		time.Duration(10)*time.Millisecond,
+		persistence.Instance,
+		cc.Instance,
+	)
+
+	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	idempotencyKey := uuid.New()
+
+	created, err := cmd.UpdateQuotation{BaseCurrency: types.USD, QuoteCurrency: types.MXN, IdempotencyKey: idempotencyKey}.
+		Execute(context.Background(), log)
+	assert.NoError(t, err)
+
+	qm.Instance.Start(context.Background())
+	defer qm.Instance.Stop()
+
+	// тот же ключ с другой парой: ждать нужно обновления USD/MXN сохраненного запроса
+	command := cmd.UpdateQuotationAndWait{
+		UpdateQuotation: cmd.UpdateQuotation{BaseCurrency: types.EUR, QuoteCurrency: types.USD, IdempotencyKey: idempotencyKey},
+		Wait:            time.Second,
+	}
+
+	result, err := command.Execute(context.Background(), log)
+
+	assert.NoError(t, err)
+	assert.Equal(t, created.Id, result.Id)
+	assert.True(t, result.Ready)
+}
+
+func Test_RequestQuotationUpdateAndWaitDeadline(t *testing.T) {
+	persistence.Instance = inmemory.New()
+	cc.Instance = cc.NewMock()
+
+	qm.Instance = qm.New(
+		time.Duration(10)*time.Millisecond,
+		persistence.Instance,
+		cc.Instance,
+	)
+
+	command := cmd.UpdateQuotationAndWait{
+		UpdateQuotation: cmd.UpdateQuotation{BaseCurrency: types.USD, QuoteCurrency: types.MXN, IdempotencyKey: uuid.New()},
+		Wait:            time.Duration(30) * time.Millisecond,
 	}
+
+	result, err := command.Execute(
+		context.Background(),
+		slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	)
+
+	assert.NoError(t, err)
+	assert.NotEqual(t, result.Id, uuid.Nil)
+	assert.False(t, result.Ready)
+
+	query := qry.GetQuotationByRequestId{Id: result.Id}
+
+	_, err = query.Run(
+		context.Background(),
+		slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	)
+
+	assert.Equal(t, err, qry.ErrRequestNotReady)
+}
+
+func Test_GetQuotationHistory(t *testing.T) {
+	persistence.Instance = inmemory.New()
+
+	start := time.Date(2025, 3, 31, 10, 0, 0, 0, time.UTC)
+
+	err := persistence.Instance.QuotationHistoryAppend([]qh.QuotationHistory{
+		qh.New(types.USD, types.MXN, types.QuotationInfo{Rate: types.MustParseDecimal("20.1"), UpdatedAt: start.Add(5 * time.Minute)}),
+		qh.New(types.USD, types.MXN, types.QuotationInfo{Rate: types.MustParseDecimal("20.5"), UpdatedAt: start.Add(20 * time.Minute)}),
+		qh.New(types.USD, types.MXN, types.QuotationInfo{Rate: types.MustParseDecimal("19.9"), UpdatedAt: start.Add(40 * time.Minute)}),
+		qh.New(types.USD, types.MXN, types.QuotationInfo{Rate: types.MustParseDecimal("20.2"), UpdatedAt: start.Add(50 * time.Minute)}),
+		qh.New(types.USD, types.MXN, types.QuotationInfo{Rate: types.MustParseDecimal("20.3"), UpdatedAt: start.Add(70 * time.Minute)}),
+		qh.New(types.USD, types.EUR, types.QuotationInfo{Rate: types.MustParseDecimal("0.9"), UpdatedAt: start.Add(10 * time.Minute)}),
+	})
+	assert.NoError(t, err)
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	{
+		query := qry.GetQuotationHistory{Base: types.USD, Quote: types.MXN, From: start, To: start.Add(time.Hour)}
+
+		result, err := query.Run(context.Background(), logger)
+
+		assert.NoError(t, err)
+		assert.Nil(t, result.Buckets)
+		assert.Len(t, result.Points, 4)
+		assert.Equal(t, "20.1", result.Points[0].Rate.String())
+		assert.Equal(t, "20.2", result.Points[3].Rate.String())
+	}
+
+	{
+		query := qry.GetQuotationHistory{Base: types.USD, Quote: types.MXN, From: start, To: start.Add(2 * time.Hour), Interval: time.Hour}
+
+		result, err := query.Run(context.Background(), logger)
+
+		assert.NoError(t, err)
+		assert.Nil(t, result.Points)
+		assert.Equal(t, []qry.HistoryBucket{
+			{Start: start, Open: types.MustParseDecimal("20.1"), High: types.MustParseDecimal("20.5"), Low: types.MustParseDecimal("19.9"), Close: types.MustParseDecimal("20.2"), Count: 4},
+			{Start: start.Add(time.Hour), Open: types.MustParseDecimal("20.3"), High: types.MustParseDecimal("20.3"), Low: types.MustParseDecimal("20.3"), Close: types.MustParseDecimal("20.3"), Count: 1},
+		}, result.Buckets)
+	}
+
+	{
+		query := qry.GetQuotationHistory{Base: types.USD, Quote: types.MXN, From: start, To: start}
+
+		_, err := query.Run(context.Background(), logger)
+
+		assert.ErrorIs(t, err, qry.ErrInvalidHistoryRange)
+	}
+}
+
+func Test_RunRecordsHistory(t *testing.T) {
+	persistence.Instance = inmemory.New()
+	cc.Instance = cc.NewMock()
+
+	qm.Instance = qm.New(
+		time.Duration(10)*time.Millisecond,
+		persistence.Instance,
+		cc.Instance,
+	)
+
+	command := cmd.UpdateQuotation{BaseCurrency: types.USD, QuoteCurrency: types.EUR, IdempotencyKey: uuid.New()}
+
+	_, err := command.Execute(
+		context.Background(),
+		slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	)
+	assert.NoError(t, err)
+
+	runOnce(t, [2]types.Currency{types.USD, types.EUR})
+
+	records, err := persistence.Instance.QuotationHistoryGetRange(types.USD, types.EUR, time.Now().Add(-time.Minute), time.Now(), 10)
+
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+}
+
+func Test_HistoricalRequestIsCachedPermanently(t *testing.T) {
+	persistence.Instance = inmemory.New()
+	cc.Instance = cc.NewMock()
+
+	qm.Instance = qm.New(
+		time.Duration(10)*time.Millisecond,
+		persistence.Instance,
+		cc.Instance,
+	)
+
+	qm.Instance.Start(context.Background())
+	defer qm.Instance.Stop()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	asOf := time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	first := cmd.UpdateQuotationAndWait{
+		UpdateQuotation: cmd.UpdateQuotation{BaseCurrency: types.EUR, QuoteCurrency: types.MXN, IdempotencyKey: uuid.New(), AsOf: &asOf},
+		Wait:            time.Second,
+	}
+
+	firstResult, err := first.Execute(context.Background(), logger)
+
+	assert.NoError(t, err)
+	assert.True(t, firstResult.Ready)
+	assert.Equal(t, asOf.UnixMilli(), firstResult.UpdatedAt)
+
+	second := cmd.UpdateQuotation{BaseCurrency: types.EUR, QuoteCurrency: types.MXN, IdempotencyKey: uuid.New(), AsOf: &asOf}
+
+	secondResult, err := second.Execute(context.Background(), logger)
+	assert.NoError(t, err)
+	assert.NotEqual(t, firstResult.Id, secondResult.Id)
+
+	query := qry.GetQuotationByRequestId{Id: secondResult.Id}
+
+	quotation, err := query.Run(context.Background(), logger)
+
+	assert.NoError(t, err, "historical rate should be served from cache without waiting for manager")
+	assert.Equal(t, firstResult.Rate, quotation.Rate)
+	assert.Equal(t, asOf, *quotation.AsOf)
+
+	_, found := qm.Instance.GetQuotation(types.EUR, types.MXN)
+	assert.False(t, found, "historical rate should not replace latest quotation")
+}
+
+func Test_HistoricalRequestInFuture(t *testing.T) {
+	persistence.Instance = inmemory.New()
+
+	asOf := time.Now().AddDate(0, 0, 2)
+
+	command := cmd.UpdateQuotation{BaseCurrency: types.EUR, QuoteCurrency: types.MXN, IdempotencyKey: uuid.New(), AsOf: &asOf}
+
+	_, err := command.Execute(
+		context.Background(),
+		slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	)
+
+	assert.ErrorIs(t, err, qr.ErrAsOfInFuture)
+}
+
+func Test_ConsensusDetailIsStored(t *testing.T) {
+	persistence.Instance = inmemory.New()
+	// моки отдают случайные курсы, поэтому допуск такой, чтобы ни один не отбрасывался
+	cc.Instance = cc.NewConsensus(1e6, 2, time.Second,
+		cc.NamedProvider{Name: "first", Provider: cc.NewMock()},
+		cc.NamedProvider{Name: "second", Provider: cc.NewMock()},
+	)
+
+	qm.Instance = qm.New(
+		time.Duration(10)*time.Millisecond,
+		persistence.Instance,
+		cc.Instance,
+	)
+
+	qm.Instance.Start(context.Background())
+	defer qm.Instance.Stop()
+
+	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	command := cmd.UpdateQuotationAndWait{
+		UpdateQuotation: cmd.UpdateQuotation{BaseCurrency: types.USD, QuoteCurrency: types.MXN, IdempotencyKey: uuid.New()},
+		Wait:            time.Second,
+	}
+
+	result, err := command.Execute(context.Background(), log)
+
+	assert.NoError(t, err)
+	assert.True(t, result.Ready)
+	assert.NotNil(t, result.Detail)
+	assert.Len(t, result.Detail.Sources, 2)
+
+	query := qry.GetQuotation{Base: types.USD, Quote: types.MXN}
+	quotation, err := query.Run(context.Background(), log)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "consensus", quotation.Provider)
+	assert.Equal(t, result.Detail, quotation.Detail)
+}
+
+func Test_ConvertAmount(t *testing.T) {
+	persistence.Instance = inmemory.New()
+	cc.Instance = cc.NewMock()
+
+	qm.Instance = qm.New(
+		time.Duration(10)*time.Millisecond,
+		persistence.Instance,
+		cc.Instance,
+	)
+
+	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	qm.Instance.UpdateQuotation(types.USD, types.MXN, types.QuotationInfo{
+		Rate:      types.MustParseDecimal("20.125000"),
+		UpdatedAt: time.Now(),
+	})
+
+	query := qry.ConvertAmount{From: types.USD, To: types.MXN, Amount: types.MustParseDecimal("1234.56"), MaxAge: time.Hour}
+	result, err := query.Run(context.Background(), log)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "24845.52", result.Result.String())
+	assert.Equal(t, "20.125000", result.Rate.String())
+
+	query = qry.ConvertAmount{From: types.MXN, To: types.USD, Amount: types.MustParseDecimal("1"), MaxAge: time.Hour}
+	_, err = query.Run(context.Background(), log)
+
+	assert.ErrorIs(t, err, qry.ErrNoQuotationData)
+
+	query = qry.ConvertAmount{From: types.USD, To: types.MXN, Amount: types.MustParseDecimal("-1"), MaxAge: time.Hour}
+	_, err = query.Run(context.Background(), log)
+
+	assert.ErrorIs(t, err, qry.ErrNegativeAmount)
+
+	qm.Instance.UpdateQuotation(types.USD, types.MXN, types.QuotationInfo{
+		Rate:      types.MustParseDecimal("20.125000"),
+		UpdatedAt: time.Now().Add(-2 * time.Hour),
+	})
+
+	query = qry.ConvertAmount{From: types.USD, To: types.MXN, Amount: types.MustParseDecimal("1"), MaxAge: time.Hour}
+	_, err = query.Run(context.Background(), log)
+
+	assert.ErrorIs(t, err, qry.ErrQuotationTooOld)
+}
+
+func Test_RequestQuotationBatch(t *testing.T) {
+	persistence.Instance = inmemory.New()
+	cc.Instance = cc.NewMock()
+
+	qm.Instance = qm.New(
+		time.Duration(10)*time.Millisecond,
+		persistence.Instance,
+		cc.Instance,
+	)
+
+	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	command := cmd.UpdateQuotationBatch{
+		IdempotencyKey: uuid.New(),
+		Pairs:          [][2]types.Currency{{types.USD, types.MXN}, {types.USD, types.EUR}, {types.EUR, types.MXN}},
+	}
+
+	result, err := command.Execute(context.Background(), log)
+
+	assert.NoError(t, err)
+
+	repeated, err := command.Execute(context.Background(), log)
+
+	assert.NoError(t, err)
+	assert.Equal(t, result.Id, repeated.Id)
+
+	query := qry.GetQuotationBatch{Id: result.Id}
+	batch, err := query.Run(context.Background(), log)
+
+	assert.NoError(t, err)
+	assert.Len(t, batch.Requests, 3)
+	assert.Equal(t, 0, batch.Completed())
+
+	runOnce(t, [2]types.Currency{types.USD, types.MXN}, [2]types.Currency{types.USD, types.EUR}, [2]types.Currency{types.EUR, types.MXN})
+
+	batch, err = query.Run(context.Background(), log)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, batch.Completed())
+
+	query = qry.GetQuotationBatch{Id: uuid.New()}
+	_, err = query.Run(context.Background(), log)
+
+	assert.ErrorIs(t, err, qry.ErrNoBatchWithSuchId)
+
+	// пачка с ошибкой в одной паре не создается целиком
+	command = cmd.UpdateQuotationBatch{
+		IdempotencyKey: uuid.New(),
+		Pairs:          [][2]types.Currency{{types.USD, types.MXN}, {types.USD, types.USD}},
+	}
+
+	_, err = command.Execute(context.Background(), log)
+
+	assert.ErrorIs(t, err, qr.ErrSameCurrency)
+}
+
+func Test_GetQuotationSnapshot(t *testing.T) {
+	persistence.Instance = inmemory.New()
+	cc.Instance = cc.NewMock()
+
+	qm.Instance = qm.New(
+		time.Duration(10)*time.Millisecond,
+		persistence.Instance,
+		cc.Instance,
+	)
+
+	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	qm.Instance.UpdateQuotation(types.USD, types.MXN, types.QuotationInfo{Rate: types.MustParseDecimal("20"), UpdatedAt: time.Now()})
+	qm.Instance.UpdateQuotation(types.USD, types.EUR, types.QuotationInfo{Rate: types.MustParseDecimal("0.9"), UpdatedAt: time.Now().Add(-2 * time.Hour)})
+	qm.Instance.UpdateQuotation(types.EUR, types.MXN, types.QuotationInfo{Rate: types.MustParseDecimal("21"), UpdatedAt: time.Now()})
+
+	query := qry.GetQuotationSnapshot{MaxAge: time.Hour}
+	entries := query.Run(context.Background(), log)
+
+	assert.Len(t, entries, 3)
+
+	query = qry.GetQuotationSnapshot{Base: types.USD, MaxAge: time.Hour}
+	entries = query.Run(context.Background(), log)
+
+	assert.Len(t, entries, 2)
+	assert.Equal(t, types.EUR, entries[0].Quote)
+	assert.True(t, entries[0].Stale)
+	assert.Equal(t, types.MXN, entries[1].Quote)
+	assert.False(t, entries[1].Stale)
+
+	query = qry.GetQuotationSnapshot{Quote: types.MXN, MaxAge: time.Hour}
+	entries = query.Run(context.Background(), log)
+
+	assert.Len(t, entries, 2)
+
+	byPairs := qry.GetQuotations{
+		Pairs:  [][2]types.Currency{{types.USD, types.MXN}, {types.MXN, types.USD}},
+		MaxAge: time.Hour,
+	}
+
+	result := byPairs.Run(context.Background(), log)
+
+	assert.Len(t, result.Found, 1)
+	assert.Equal(t, "20", result.Found[0].Rate.String())
+	assert.Equal(t, [][2]types.Currency{{types.MXN, types.USD}}, result.Missing)
 }